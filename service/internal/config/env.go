@@ -113,5 +113,12 @@ type Log struct {
 // }
 
 type Job struct {
-	JobQueueName string `mapstructure:"JOB_QUEUE_NAME" default:"studio_workflow_job_queue"`
+	JobQueueName          string `mapstructure:"JOB_QUEUE_NAME" default:"studio_workflow_job_queue"`
+	RecoverGraceSecond    int    `mapstructure:"JOB_RECOVER_GRACE_SECOND" default:"120"`    // 调度启动后等待 edge 重连的时间，超时仍离线的运行中任务置为失败
+	ActionTimeoutSecond   int    `mapstructure:"JOB_ACTION_TIMEOUT_SECOND" default:"20"`    // 节点和模板均未配置超时时间时的默认值
+	QueryTimeoutSecond    int    `mapstructure:"JOB_QUERY_TIMEOUT_SECOND" default:"20"`     // 等待设备空闲的超时时间
+	NodePoolSize          int    `mapstructure:"JOB_NODE_POOL_SIZE" default:"5"`            // 单个任务并发运行的节点数
+	DeviceLockLeaseSecond int    `mapstructure:"JOB_DEVICE_LOCK_LEASE_SECOND" default:"30"` // 设备锁租约时长，持有期间自动续期
+	LabMaxTasks           int    `mapstructure:"JOB_LAB_MAX_TASKS" default:"3"`             // 单个实验室同时运行的工作流任务数，超出的任务在实验室任务队列中排队
+	MessageExpireSecond   int    `mapstructure:"JOB_MESSAGE_EXPIRE_SECOND" default:"3600"`  // 全局工作流队列消息无实例认领的过期时间，过期后转入死信队列
 }
@@ -124,6 +124,33 @@ func _() {
 	_ = x[UnknownWorkflowNodeTypeErr-30032]
 	_ = x[ExecWorkflowNodeScriptErr-30033]
 	_ = x[EdgeNotStartedErr-30034]
+	_ = x[JobInterruptedErr-30035]
+	_ = x[WorkflowNodeBranchConfigErr-30036]
+	_ = x[WorkflowNodeMapConfigErr-30037]
+	_ = x[WorkflowNodeMapItemsErr-30038]
+	_ = x[WorkflowNodeSubflowConfigErr-30039]
+	_ = x[DeviceLockErr-30040]
+	_ = x[WorkflowParamErr-30041]
+	_ = x[WorkflowTriggerErr-30042]
+	_ = x[WorkflowTriggerNotFoundErr-30043]
+	_ = x[TriggerTokenErr-30044]
+	_ = x[TriggerSignatureErr-30045]
+	_ = x[QueryTaskStatusTimeoutErr-30046]
+	_ = x[WorkflowNodeManualConfigErr-30047]
+	_ = x[ManualApprovalNotFoundErr-30048]
+	_ = x[ManualApprovalFinishedErr-30049]
+	_ = x[ManualInputErr-30050]
+	_ = x[ManualRejectedErr-30051]
+	_ = x[WorkflowNodeTimerConfigErr-30052]
+	_ = x[WorkflowNodeWaitConfigErr-30053]
+	_ = x[EdgeExpressionErr-30054]
+	_ = x[HandleTypeMismatchErr-30055]
+	_ = x[WorkflowGoalUnmappedErr-30056]
+	_ = x[LabOfflineErr-30057]
+	_ = x[WorkflowSnapshotErr-30058]
+	_ = x[WorkflowSubflowLabErr-30059]
+	_ = x[DeviceLeaseLostErr-30060]
+	_ = x[JobStatusUnknownErr-30061]
 }
 
 const (
@@ -136,7 +163,7 @@ const (
 	_ErrCode_name_6 = "notify action already registrynotify subscribe channel failnotify send message error"
 	_ErrCode_name_7 = "rpc request http errorrpc request http code errorrpc request http code resp errorcreate lab user errorquery lab user errorbhor batch query user error"
 	_ErrCode_name_8 = "can not get workflow uuidworkflow not existupsert workflow edge errorpermission deniedbatch save nodes errorbatch save workflow edge errorworkflow node not found errorworkflow not found errorformat csv data error"
-	_ErrCode_name_9 = "workflow task already exist errorcan not found edge sessionworkflow has circular errorconnect closed when node running errormarshal node data errorjob run fail errorcan not found workflow task errorworkflow task status errorworkflow task finishedworkflow node no device name errorworkflow node no action name errorworkflow node no action type errorquery job status key note exists errorcallback job status key note exists errorjob timeout errorjob retry timeout errorcallback job status timeout errorjob is canceledcan not get workflow task errorworkflow task not in pending statuscan not found workflow handle errorcan not found parent node job errorparam data key invalidate errorparam data value invalidate errordata not map any type errorvalue slice out index errorvalue not exist errorset lab heart errortarget data not map any type errormarshal target data errortarget param invalidate errorworkflow script empty errorunknown workflow node type errorexec workflow script erroredge not started error"
+	_ErrCode_name_9 = "workflow task already exist errorcan not found edge sessionworkflow has circular errorconnect closed when node running errormarshal node data errorjob run fail errorcan not found workflow task errorworkflow task status errorworkflow task finishedworkflow node no device name errorworkflow node no action name errorworkflow node no action type errorquery job status key note exists errorcallback job status key note exists errorjob timeout errorjob retry timeout errorcallback job status timeout errorjob is canceledcan not get workflow task errorworkflow task not in pending statuscan not found workflow handle errorcan not found parent node job errorparam data key invalidate errorparam data value invalidate errordata not map any type errorvalue slice out index errorvalue not exist errorset lab heart errortarget data not map any type errormarshal target data errortarget param invalidate errorworkflow script empty errorunknown workflow node type errorexec workflow script erroredge not started errorjob interrupted by edge disconnect errorworkflow branch node config errorworkflow map node config errorworkflow map node items not a list errorworkflow sub workflow node config errordevice lock errorworkflow run param errorworkflow trigger config errorcan not found workflow trigger errorinvalid or revoked trigger token errortrigger signature mismatch errorquery task live status timeout errorworkflow manual node config errorcan not found manual approval errormanual approval already handled errormanual approval form input errormanual approval rejected errorworkflow timer node config errorworkflow wait condition node config errorworkflow edge expression errorworkflow handle type mismatch errorworkflow node required goal field unmapped errorlaboratory offline errorworkflow task snapshot errorsub workflow not exist in current lab errordevice lock lease lost errorjob status unknown, edge not reply error"
 )
 
 var (
@@ -149,7 +176,7 @@ var (
 	_ErrCode_index_6 = [...]uint8{0, 30, 59, 84}
 	_ErrCode_index_7 = [...]uint8{0, 22, 49, 81, 102, 122, 149}
 	_ErrCode_index_8 = [...]uint8{0, 25, 43, 69, 86, 108, 138, 167, 191, 212}
-	_ErrCode_index_9 = [...]uint16{0, 33, 59, 86, 124, 147, 165, 198, 224, 246, 280, 314, 348, 386, 427, 444, 467, 500, 515, 546, 581, 616, 651, 682, 715, 742, 769, 790, 809, 843, 868, 897, 924, 956, 982, 1004}
+	_ErrCode_index_9 = [...]uint16{0, 33, 59, 86, 124, 147, 165, 198, 224, 246, 280, 314, 348, 386, 427, 444, 467, 500, 515, 546, 581, 616, 651, 682, 715, 742, 769, 790, 809, 843, 868, 897, 924, 956, 982, 1004, 1044, 1077, 1107, 1147, 1186, 1203, 1227, 1256, 1292, 1330, 1362, 1398, 1431, 1466, 1503, 1535, 1565, 1597, 1638, 1668, 1703, 1751, 1775, 1803, 1846, 1874, 1914}
 )
 
 func (i ErrCode) String() string {
@@ -180,7 +207,7 @@ func (i ErrCode) String() string {
 	case 28000 <= i && i <= 28008:
 		i -= 28000
 		return _ErrCode_name_8[_ErrCode_index_8[i]:_ErrCode_index_8[i+1]]
-	case 30000 <= i && i <= 30034:
+	case 30000 <= i && i <= 30061:
 		i -= 30000
 		return _ErrCode_name_9[_ErrCode_index_9[i]:_ErrCode_index_9[i+1]]
 	default:
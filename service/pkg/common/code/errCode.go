@@ -204,4 +204,31 @@ const (
 	UnknownWorkflowNodeTypeErr                             // unknown workflow node type error
 	ExecWorkflowNodeScriptErr                              // exec workflow script error
 	EdgeNotStartedErr                                      // edge not started error
+	JobInterruptedErr                                      // job interrupted by edge disconnect error
+	WorkflowNodeBranchConfigErr                            // workflow branch node config error
+	WorkflowNodeMapConfigErr                               // workflow map node config error
+	WorkflowNodeMapItemsErr                                // workflow map node items not a list error
+	WorkflowNodeSubflowConfigErr                           // workflow sub workflow node config error
+	DeviceLockErr                                          // device lock error
+	WorkflowParamErr                                       // workflow run param error
+	WorkflowTriggerErr                                     // workflow trigger config error
+	WorkflowTriggerNotFoundErr                             // can not found workflow trigger error
+	TriggerTokenErr                                        // invalid or revoked trigger token error
+	TriggerSignatureErr                                    // trigger signature mismatch error
+	QueryTaskStatusTimeoutErr                              // query task live status timeout error
+	WorkflowNodeManualConfigErr                            // workflow manual node config error
+	ManualApprovalNotFoundErr                              // can not found manual approval error
+	ManualApprovalFinishedErr                              // manual approval already handled error
+	ManualInputErr                                         // manual approval form input error
+	ManualRejectedErr                                      // manual approval rejected error
+	WorkflowNodeTimerConfigErr                             // workflow timer node config error
+	WorkflowNodeWaitConfigErr                              // workflow wait condition node config error
+	EdgeExpressionErr                                      // workflow edge expression error
+	HandleTypeMismatchErr                                  // workflow handle type mismatch error
+	WorkflowGoalUnmappedErr                                // workflow node required goal field unmapped error
+	LabOfflineErr                                          // laboratory offline error
+	WorkflowSnapshotErr                                    // workflow task snapshot error
+	WorkflowSubflowLabErr                                  // sub workflow not exist in current lab error
+	DeviceLeaseLostErr                                     // device lock lease lost error
+	JobStatusUnknownErr                                    // job status unknown, edge not reply error
 )
@@ -17,3 +17,9 @@ const (
 const (
 	MaxMessageSize = 10 * 1024 * 1024 // 10M
 )
+
+// webhook 触发
+const (
+	TriggerTokenHeader     = "X-Trigger-Token"     // 工作流触发 token
+	TriggerSignatureHeader = "X-Trigger-Signature" // 请求体的 HMAC-SHA256 签名，格式 sha256=<hex>
+)
@@ -0,0 +1,363 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+func (n *literalNode) eval(_ map[string]any) (any, error) {
+	return n.value, nil
+}
+
+func (n *identNode) eval(env map[string]any) (any, error) {
+	return env[n.name], nil
+}
+
+func (n *listNode) eval(env map[string]any) (any, error) {
+	items := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+
+	return items, nil
+}
+
+func (n *objectNode) eval(env map[string]any) (any, error) {
+	obj := make(map[string]any, len(n.keys))
+	for i, key := range n.keys {
+		value, err := n.values[i].eval(env)
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = value
+	}
+
+	return obj, nil
+}
+
+// 访问 null 的成员得到 null，便于配合 default 使用
+func (n *memberNode) eval(env map[string]any) (any, error) {
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := target.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		return value[n.field], nil
+	default:
+		return nil, fmt.Errorf("can not get field %s of %s", n.field, typeName(target))
+	}
+}
+
+func (n *indexNode) eval(env map[string]any) (any, error) {
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := n.index.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := target.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("object key must be string, got %s", typeName(index))
+		}
+		return value[key], nil
+	case []any:
+		i, err := listIndex(index, len(value))
+		if err != nil {
+			return nil, err
+		}
+		return value[i], nil
+	case string:
+		runes := []rune(value)
+		i, err := listIndex(index, len(runes))
+		if err != nil {
+			return nil, err
+		}
+		return string(runes[i]), nil
+	default:
+		return nil, fmt.Errorf("can not index %s", typeName(target))
+	}
+}
+
+func listIndex(index any, length int) (int, error) {
+	f, ok := index.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, fmt.Errorf("index must be integer, got %v", index)
+	}
+
+	i := int(f)
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return 0, fmt.Errorf("index %d out of range, length %d", int(f), length)
+	}
+
+	return i, nil
+}
+
+func (n *callNode) eval(env map[string]any) (any, error) {
+	args := make([]any, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	res, err := n.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+
+	if s, ok := res.(string); ok && len(s) > maxValueLen {
+		return nil, fmt.Errorf("%s: result too long", n.name)
+	}
+
+	return res, nil
+}
+
+func (n *unaryNode) eval(env map[string]any) (any, error) {
+	operand, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "!" {
+		return !truthy(operand), nil
+	}
+
+	f, ok := operand.(float64)
+	if !ok {
+		return nil, fmt.Errorf("can not negate %s", typeName(operand))
+	}
+	return -f, nil
+}
+
+func (n *ternaryNode) eval(env map[string]any) (any, error) {
+	cond, err := n.cond.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if truthy(cond) {
+		return n.then.eval(env)
+	}
+	return n.otherwise.eval(env)
+}
+
+func (n *binaryNode) eval(env map[string]any) (any, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// 逻辑运算短路
+	switch n.op {
+	case "&&":
+		if !truthy(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		return truthy(right), nil
+	case "||":
+		if truthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		return truthy(right), nil
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		return compare(n.op, left, right)
+	case "+":
+		return add(left, right)
+	default:
+		return arithmetic(n.op, left, right)
+	}
+}
+
+func compare(op string, left, right any) (bool, error) {
+	var c int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false, fmt.Errorf("can not compare number with %s", typeName(right))
+		}
+		switch {
+		case l < r:
+			c = -1
+		case l > r:
+			c = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false, fmt.Errorf("can not compare string with %s", typeName(right))
+		}
+		switch {
+		case l < r:
+			c = -1
+		case l > r:
+			c = 1
+		}
+	default:
+		return false, fmt.Errorf("can not compare %s", typeName(left))
+	}
+
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// 数字相加，字符串拼接，列表合并
+func add(left, right any) (any, error) {
+	switch l := left.(type) {
+	case float64:
+		if r, ok := right.(float64); ok {
+			return l + r, nil
+		}
+	case []any:
+		if r, ok := right.([]any); ok {
+			items := make([]any, 0, len(l)+len(r))
+			return append(append(items, l...), r...), nil
+		}
+	}
+
+	_, ls := left.(string)
+	_, rs := right.(string)
+	if ls || rs {
+		res := toString(left) + toString(right)
+		if len(res) > maxValueLen {
+			return nil, fmt.Errorf("string too long")
+		}
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("can not add %s and %s", typeName(left), typeName(right))
+}
+
+func arithmetic(op string, left, right any) (any, error) {
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s needs numbers, got %s and %s", op, typeName(left), typeName(right))
+	}
+
+	switch op {
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	default:
+		return nil, fmt.Errorf("unknown operator %s", op)
+	}
+}
+
+// null、false、0、空字符串、空列表和空对象为假
+func truthy(v any) bool {
+	switch value := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	case string:
+		return value != ""
+	case []any:
+		return len(value) > 0
+	case map[string]any:
+		return len(value) > 0
+	default:
+		return true
+	}
+}
+
+func toString(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
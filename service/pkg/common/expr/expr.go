@@ -0,0 +1,144 @@
+// Package expr 工作流边上的数据转换表达式
+//
+// 表达式在 Go 中解释执行，只能读取传入的变量和调用内置函数，没有赋值、循环和外部访问。
+// 支持数字、字符串、布尔、null、列表和对象字面量，成员访问 a.b、下标 a[0]（负数从末尾计数），
+// 算术 + - * / %，比较 == != < <= > >=，逻辑 && || !，三元 cond ? a : b，以及 funcs.go 中的内置函数。
+//
+// 示例:
+//
+//	convert(value, "mL", "uL")
+//	round(value * 1.8 + 32, 1)
+//	format("%s-%d", upstream.prepare.name, int(value))
+//	value[-1]
+//	{"volume": upstream.a.volume + upstream.b.volume, "unit": "mL"}
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const (
+	maxSourceLen = 4096 // 表达式最大长度
+	maxDepth     = 64   // 最大嵌套深度
+	maxValueLen  = 1 << 20
+)
+
+// 编译后的表达式，可并发执行
+type Program struct {
+	source string
+	root   node
+	idents []string
+}
+
+// 编译表达式，vars 不为空时只允许引用其中的变量
+func Compile(source string, vars ...string) (*Program, error) {
+	if len(source) > maxSourceLen {
+		return nil, fmt.Errorf("expression longer than %d", maxSourceLen)
+	}
+
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens: tokens,
+		idents: make(map[string]struct{}),
+	}
+	if len(vars) > 0 {
+		p.vars = make(map[string]struct{}, len(vars))
+		for _, v := range vars {
+			p.vars[v] = struct{}{}
+		}
+	}
+
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+
+	idents := make([]string, 0, len(p.idents))
+	for name := range p.idents {
+		idents = append(idents, name)
+	}
+	sort.Strings(idents)
+
+	return &Program{
+		source: source,
+		root:   root,
+		idents: idents,
+	}, nil
+}
+
+func (p *Program) String() string {
+	return p.source
+}
+
+// 表达式引用的变量名
+func (p *Program) Idents() []string {
+	return p.idents
+}
+
+// 执行表达式，env 中的值会先转换为 json 对应的类型
+func (p *Program) Eval(env map[string]any) (res any, err error) {
+	values := make(map[string]any, len(env))
+	for k, v := range env {
+		if values[k], err = normalize(v); err != nil {
+			return nil, fmt.Errorf("variable %s: %w", k, err)
+		}
+	}
+
+	return p.root.eval(values)
+}
+
+// 转换为 nil、bool、float64、string、[]any、map[string]any
+func normalize(v any) (any, error) {
+	switch value := v.(type) {
+	case nil, bool, float64, string:
+		return value, nil
+	case int:
+		return float64(value), nil
+	case int32:
+		return float64(value), nil
+	case int64:
+		return float64(value), nil
+	case float32:
+		return float64(value), nil
+	case []any:
+		items := make([]any, len(value))
+		for i, item := range value {
+			var err error
+			if items[i], err = normalize(item); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case map[string]any:
+		obj := make(map[string]any, len(value))
+		for k, item := range value {
+			var err error
+			if obj[k], err = normalize(item); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	default:
+		// 其他类型经 json 转换
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		var res any
+		if err := json.Unmarshal(data, &res); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
@@ -0,0 +1,45 @@
+// notlint:revive
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEval(t *testing.T) {
+	env := map[string]any{
+		"value":    1.5,
+		"upstream": map[string]any{"prepare": map[string]any{"name": "A", "list": []any{1, 2, 3}}},
+	}
+
+	cases := map[string]any{
+		`convert(value, "mL", "uL")`:                  1500.0,
+		`round(convert(100, "C", "F"), 1)`:            212.0,
+		`format("%s-%03d", upstream.prepare.name, 7)`: "A-007",
+		`upstream.prepare.list[-1] * 2`:               6.0,
+		`default(upstream.missing.name, "x")`:         "x",
+		`value > 1 ? "big" : "small"`:                 "big",
+		`{"v": sum(upstream.prepare.list)}`:           map[string]any{"v": 6.0},
+	}
+
+	for source, want := range cases {
+		p, err := Compile(source, "value", "upstream")
+		assert.NoError(t, err, source)
+		res, err := p.Eval(env)
+		assert.NoError(t, err, source)
+		assert.Equal(t, want, res, source)
+	}
+}
+
+func TestCompileErr(t *testing.T) {
+	for _, source := range []string{`foo + 1`, `value +`, `unknown(value)`, `len(value, 1)`, `"abc`} {
+		_, err := Compile(source, "value")
+		assert.Error(t, err, source)
+	}
+
+	p, err := Compile(`convert(value, "mL", "kg")`, "value")
+	assert.NoError(t, err)
+	_, err = p.Eval(map[string]any{"value": 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,454 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type builtin struct {
+	minArgs int
+	maxArgs int // -1 不限制
+	call    func(args []any) (any, error)
+}
+
+var builtins map[string]*builtin
+
+func init() {
+	builtins = map[string]*builtin{
+		// 数值
+		"abs":   {1, 1, numberFunc(math.Abs)},
+		"floor": {1, 1, numberFunc(math.Floor)},
+		"ceil":  {1, 1, numberFunc(math.Ceil)},
+		"sqrt":  {1, 1, numberFunc(math.Sqrt)},
+		"round": {1, 2, fnRound},
+		"pow":   {2, 2, fnPow},
+		"min":   {1, -1, fnMin},
+		"max":   {1, -1, fnMax},
+		"sum":   {1, 1, fnSum},
+		"avg":   {1, 1, fnAvg},
+
+		// 类型转换
+		"int":    {1, 1, fnInt},
+		"float":  {1, 1, fnFloat},
+		"string": {1, 1, func(args []any) (any, error) { return toString(args[0]), nil }},
+
+		// 字符串
+		"upper":    {1, 1, stringFunc(strings.ToUpper)},
+		"lower":    {1, 1, stringFunc(strings.ToLower)},
+		"trim":     {1, 1, stringFunc(strings.TrimSpace)},
+		"format":   {1, -1, fnFormat},
+		"concat":   {1, -1, fnConcat},
+		"split":    {2, 2, fnSplit},
+		"join":     {2, 2, fnJoin},
+		"contains": {2, 2, fnContains},
+
+		// 列表和对象
+		"len":     {1, 1, fnLen},
+		"first":   {1, 1, fnFirst},
+		"last":    {1, 1, fnLast},
+		"slice":   {2, 3, fnSlice},
+		"keys":    {1, 1, fnKeys},
+		"default": {2, 2, fnDefault},
+
+		// 单位换算
+		"convert": {3, 3, fnConvert},
+	}
+}
+
+func numberArg(args []any, i int) (float64, error) {
+	f, ok := args[i].(float64)
+	if !ok {
+		return 0, fmt.Errorf("argument %d must be number, got %s", i+1, typeName(args[i]))
+	}
+	return f, nil
+}
+
+func stringArg(args []any, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("argument %d must be string, got %s", i+1, typeName(args[i]))
+	}
+	return s, nil
+}
+
+func listArg(args []any, i int) ([]any, error) {
+	l, ok := args[i].([]any)
+	if !ok {
+		return nil, fmt.Errorf("argument %d must be list, got %s", i+1, typeName(args[i]))
+	}
+	return l, nil
+}
+
+func numberFunc(f func(float64) float64) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		x, err := numberArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return f(x), nil
+	}
+}
+
+func stringFunc(f func(string) string) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return f(s), nil
+	}
+}
+
+func fnRound(args []any) (any, error) {
+	x, err := numberArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	digits := 0.0
+	if len(args) > 1 {
+		if digits, err = numberArg(args, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	scale := math.Pow(10, math.Trunc(digits))
+	return math.Round(x*scale) / scale, nil
+}
+
+func fnPow(args []any) (any, error) {
+	x, err := numberArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := numberArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return math.Pow(x, y), nil
+}
+
+// 参数为单个列表时对列表元素计算
+func numbers(args []any) ([]float64, error) {
+	if len(args) == 1 {
+		if l, ok := args[0].([]any); ok {
+			args = l
+		}
+	}
+
+	nums := make([]float64, 0, len(args))
+	for i := range args {
+		f, err := numberArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, f)
+	}
+
+	return nums, nil
+}
+
+func fnMin(args []any) (any, error) {
+	nums, err := numbers(args)
+	if err != nil || len(nums) == 0 {
+		return nil, err
+	}
+
+	res := nums[0]
+	for _, n := range nums[1:] {
+		res = math.Min(res, n)
+	}
+	return res, nil
+}
+
+func fnMax(args []any) (any, error) {
+	nums, err := numbers(args)
+	if err != nil || len(nums) == 0 {
+		return nil, err
+	}
+
+	res := nums[0]
+	for _, n := range nums[1:] {
+		res = math.Max(res, n)
+	}
+	return res, nil
+}
+
+func fnSum(args []any) (any, error) {
+	if _, err := listArg(args, 0); err != nil {
+		return nil, err
+	}
+
+	nums, err := numbers(args)
+	if err != nil {
+		return nil, err
+	}
+
+	res := 0.0
+	for _, n := range nums {
+		res += n
+	}
+	return res, nil
+}
+
+func fnAvg(args []any) (any, error) {
+	sum, err := fnSum(args)
+	if err != nil {
+		return nil, err
+	}
+
+	l := args[0].([]any)
+	if len(l) == 0 {
+		return nil, nil
+	}
+	return sum.(float64) / float64(len(l)), nil
+}
+
+func fnInt(args []any) (any, error) {
+	f, err := fnFloat(args)
+	if err != nil {
+		return nil, err
+	}
+	return math.Trunc(f.(float64)), nil
+}
+
+func fnFloat(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("can not parse %q as number", v)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("can not convert %s to number", typeName(v))
+	}
+}
+
+// 整数值的数字按 %d 格式化时转换为整数
+func fnFormat(args []any) (any, error) {
+	format, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]any, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		if f, ok := arg.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			values = append(values, formatNumber(f))
+			continue
+		}
+		values = append(values, arg)
+	}
+
+	return fmt.Sprintf(format, values...), nil
+}
+
+// 同时支持 %d 和 %f 等格式
+type formatNumber float64
+
+func (n formatNumber) Format(s fmt.State, verb rune) {
+	format := "%" + flags(s)
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'b', 'c':
+		fmt.Fprintf(s, format+string(verb), int64(n))
+	default:
+		fmt.Fprintf(s, format+string(verb), float64(n))
+	}
+}
+
+func flags(s fmt.State) string {
+	var sb strings.Builder
+	for _, flag := range "+-# 0" {
+		if s.Flag(int(flag)) {
+			sb.WriteRune(flag)
+		}
+	}
+	if width, ok := s.Width(); ok {
+		sb.WriteString(strconv.Itoa(width))
+	}
+	if precision, ok := s.Precision(); ok {
+		sb.WriteString("." + strconv.Itoa(precision))
+	}
+	return sb.String()
+}
+
+func fnConcat(args []any) (any, error) {
+	var sb strings.Builder
+	for _, arg := range args {
+		sb.WriteString(toString(arg))
+	}
+	return sb.String(), nil
+}
+
+func fnSplit(args []any) (any, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sep, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(s, sep)
+	items := make([]any, 0, len(parts))
+	for _, part := range parts {
+		items = append(items, part)
+	}
+	return items, nil
+}
+
+func fnJoin(args []any) (any, error) {
+	l, err := listArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sep, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]string, 0, len(l))
+	for _, item := range l {
+		parts = append(parts, toString(item))
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func fnContains(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case string:
+		sub, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Contains(v, sub), nil
+	case []any:
+		for _, item := range v {
+			if reflect.DeepEqual(item, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]any:
+		key, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := v[key]
+		return ok, nil
+	default:
+		return nil, fmt.Errorf("can not search in %s", typeName(v))
+	}
+}
+
+func fnLen(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case string:
+		return float64(len([]rune(v))), nil
+	case []any:
+		return float64(len(v)), nil
+	case map[string]any:
+		return float64(len(v)), nil
+	case nil:
+		return 0.0, nil
+	default:
+		return nil, fmt.Errorf("can not get length of %s", typeName(v))
+	}
+}
+
+func fnFirst(args []any) (any, error) {
+	l, err := listArg(args, 0)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[0], nil
+}
+
+func fnLast(args []any) (any, error) {
+	l, err := listArg(args, 0)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[len(l)-1], nil
+}
+
+// slice(list, start[, end])，负数从末尾计数，越界时截断
+func fnSlice(args []any) (any, error) {
+	l, err := listArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := func(i int) (int, error) {
+		f, err := numberArg(args, i)
+		if err != nil {
+			return 0, err
+		}
+		n := int(f)
+		if n < 0 {
+			n += len(l)
+		}
+		return max(0, min(n, len(l))), nil
+	}
+
+	start, err := bound(1)
+	if err != nil {
+		return nil, err
+	}
+
+	end := len(l)
+	if len(args) > 2 {
+		if end, err = bound(2); err != nil {
+			return nil, err
+		}
+	}
+
+	if start >= end {
+		return []any{}, nil
+	}
+	return append([]any{}, l[start:end]...), nil
+}
+
+func fnKeys(args []any) (any, error) {
+	obj, ok := args[0].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("argument 1 must be object, got %s", typeName(args[0]))
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := make([]any, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, k)
+	}
+	return items, nil
+}
+
+// 第一个参数为 null 时返回默认值
+func fnDefault(args []any) (any, error) {
+	if args[0] == nil {
+		return args[1], nil
+	}
+	return args[0], nil
+}
@@ -0,0 +1,126 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 单位换算到同一量纲的基准单位的系数
+type unit struct {
+	dimension string
+	factor    float64
+}
+
+var units = map[string]unit{
+	// 体积，基准 L
+	"l":  {"volume", 1},
+	"ml": {"volume", 1e-3},
+	"ul": {"volume", 1e-6},
+	"µl": {"volume", 1e-6},
+	"nl": {"volume", 1e-9},
+
+	// 质量，基准 g
+	"kg": {"mass", 1e3},
+	"g":  {"mass", 1},
+	"mg": {"mass", 1e-3},
+	"ug": {"mass", 1e-6},
+	"µg": {"mass", 1e-6},
+	"ng": {"mass", 1e-9},
+
+	// 长度，基准 m
+	"m":  {"length", 1},
+	"cm": {"length", 1e-2},
+	"mm": {"length", 1e-3},
+	"um": {"length", 1e-6},
+	"µm": {"length", 1e-6},
+	"nm": {"length", 1e-9},
+
+	// 时间，基准 s
+	"h":   {"time", 3600},
+	"min": {"time", 60},
+	"s":   {"time", 1},
+	"ms":  {"time", 1e-3},
+
+	// 物质的量，基准 mol
+	"mol":  {"amount", 1},
+	"mmol": {"amount", 1e-3},
+	"umol": {"amount", 1e-6},
+	"µmol": {"amount", 1e-6},
+
+	// 转速，基准 rpm
+	"rpm": {"speed", 1},
+	"rps": {"speed", 60},
+}
+
+// convert(value, from, to)，温度支持 C、F、K
+func fnConvert(args []any) (any, error) {
+	x, err := numberArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := stringArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to = normalizeUnit(from), normalizeUnit(to)
+	if celsius, ok := toCelsius(x, from); ok {
+		if res, ok := fromCelsius(celsius, to); ok {
+			return res, nil
+		}
+		return nil, fmt.Errorf("can not convert %s to %s", args[1], args[2])
+	}
+
+	fu, ok := units[from]
+	if !ok {
+		return nil, fmt.Errorf("unknown unit %s", args[1])
+	}
+
+	tu, ok := units[to]
+	if !ok {
+		return nil, fmt.Errorf("unknown unit %s", args[2])
+	}
+
+	if fu.dimension != tu.dimension {
+		return nil, fmt.Errorf("can not convert %s to %s", args[1], args[2])
+	}
+
+	return x * fu.factor / tu.factor, nil
+}
+
+func normalizeUnit(u string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	return strings.TrimPrefix(u, "°")
+}
+
+func toCelsius(x float64, u string) (float64, bool) {
+	switch u {
+	case "c":
+		return x, true
+	case "f":
+		return (x - 32) * 5 / 9, true
+	case "k":
+		return x - 273.15, true
+	default:
+		return 0, false
+	}
+}
+
+func fromCelsius(x float64, u string) (float64, bool) {
+	switch u {
+	case "c":
+		return x, true
+	case "f":
+		return x*9/5 + 32, true
+	case "k":
+		return x + 273.15, true
+	default:
+		return 0, false
+	}
+}
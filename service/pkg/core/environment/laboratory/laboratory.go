@@ -333,6 +333,7 @@ func (l *lab) createWorkflowNodeTemplate(ctx context.Context, res []*environment
 				Handles:        action.Handles,
 				Header:         actionName,
 				Footer:         item.SelfDB.Name,
+				TimeoutSecond:  action.Timeout,
 			})
 		}
 		return actions, true, nil
@@ -82,6 +82,7 @@ type RegAction struct {
 	Schema      datatypes.JSON                         `json:"schema" swaggertype:"object"`
 	Type        string                                 `json:"type"`
 	Handles     datatypes.JSONType[model.ActionHandle] `json:"handles" swaggertype:"object"`
+	Timeout     int                                    `json:"timeout"` // 动作默认超时时间，单位秒
 }
 
 type RegClass struct {
@@ -18,6 +18,7 @@ type Action string
 const (
 	MaterialModify Action = "material-modify"
 	WorkflowRun    Action = "workflow-run"
+	WorkflowStatus Action = "workflow-status" // 任务运行时状态查询的回复
 )
 
 type SendMsg struct {
@@ -29,6 +29,7 @@ import (
 	eStore "github.com/scienceol/studio/service/pkg/repo/environment"
 	mStore "github.com/scienceol/studio/service/pkg/repo/material"
 	s "github.com/scienceol/studio/service/pkg/repo/sandbox"
+	wfl "github.com/scienceol/studio/service/pkg/repo/workflow"
 	"github.com/scienceol/studio/service/pkg/utils"
 	"github.com/scienceol/studio/service/pkg/web/views/labstatus"
 )
@@ -53,6 +54,11 @@ type control struct {
 	sandbox       repo.Sandbox                  // 脚本运行沙箱
 	labStore      repo.LaboratoryRepo           // 实验室存储
 	materialStore repo.MaterialRepo             // 物料调度
+	workflowStore repo.WorkflowRepo             // 工作流存储
+	consumer      *redis.MessageConsumer        // 按实验室亲和消费全局工作流队列
+	cancel        context.CancelFunc            // 停止队列消费
+	simulations   sync.Map                      // 本实例运行的仿真任务，key 为任务 uuid
+	wait          sync.WaitGroup
 }
 
 func NewControl(ctx context.Context) schedule.Control {
@@ -67,14 +73,22 @@ func NewControl(ctx context.Context) schedule.Control {
 			wsClient:      wsClient,
 			scheduleName:  scheduleName,
 			rClient:       redis.GetClient(),
+			consumer:      redis.NewMessageConsumer(redis.GetClient(), scheduleName),
 			labMap:        haxmap.New[int64, edge.Edge](),
 			labStore:      eStore.New(),
 			materialStore: mStore.NewMaterialImpl(),
+			workflowStore: wfl.New(),
 			boardEvent:    events.NewEvents(),
 			sandbox:       s.NewSandbox(),
 		}
 		ctl.pools, _ = ants.NewPool(poolSize)
 		ctl.initWebSocket(ctx)
+
+		consumerCtx, cancel := context.WithCancel(ctx)
+		ctl.cancel = cancel
+		ctl.startJobConsumer(consumerCtx)
+		ctl.startRecovery(consumerCtx)
+		ctl.startTrigger(consumerCtx)
 	})
 
 	return ctl
@@ -164,6 +178,7 @@ func (i *control) initWebSocket(ctx context.Context) {
 			ID:        labID,
 			LabUserID: labUserID,
 			Session:   s,
+			Sandbox:   i.sandbox,
 		}
 
 		edgeImpl, err := edgeImpl.NewEdge(sessionCtx, labInfo)
@@ -177,6 +192,11 @@ func (i *control) initWebSocket(ctx context.Context) {
 		}
 
 		i.labMap.Set(labID, edgeImpl)
+
+		// 登记实验室连接在本实例，全局队列中该实验室的消息由本实例消费
+		if err := i.consumer.AddUser(sessionCtx, labUUID.String()); err != nil {
+			logger.Errorf(sessionCtx, "schedule control add lab to consumer uuid: %s, err: %+v", labUUID, err)
+		}
 	})
 
 	// edge websocket 断开
@@ -187,6 +207,7 @@ func (i *control) initWebSocket(ctx context.Context) {
 		ctx := s.MustGet("ctx").(*gin.Context)
 		if edgeImpl, ok := i.labMap.GetAndDel(labID); ok && edgeImpl != nil {
 			edgeImpl.Close(ctx)
+			i.removeConsumerLab(ctx, labUUID)
 		}
 
 		// 更新数据库：设置实验室为离线状态
@@ -211,6 +232,7 @@ func (i *control) initWebSocket(ctx context.Context) {
 		ctx := s.MustGet("ctx").(*gin.Context)
 		if edgeImpl, ok := i.labMap.GetAndDel(labID); ok && edgeImpl != nil {
 			edgeImpl.Close(ctx)
+			i.removeConsumerLab(ctx, labUUID)
 		}
 
 		// 更新数据库：设置实验室为离线状态
@@ -280,6 +302,11 @@ func (i *control) initWebSocket(ctx context.Context) {
 
 // 关闭清理资源
 func (i *control) Close(ctx context.Context) {
+	if i.cancel != nil {
+		i.cancel()
+	}
+	i.wait.Wait()
+
 	if i.wsClient != nil {
 		if err := i.wsClient.CloseWithMsg([]byte("reboot")); err != nil {
 			logger.Errorf(ctx, "Close fail CloseWithMsg err: %+v", err)
@@ -291,7 +318,18 @@ func (i *control) Close(ctx context.Context) {
 		return true
 	})
 
+	// 本实例不再认领任何实验室的消息
+	if err := i.consumer.Cleanup(ctx); err != nil {
+		logger.Errorf(ctx, "Close fail cleanup consumer err: %+v", err)
+	}
+
 	if i.pools != nil {
 		i.pools.Release()
 	}
 }
+
+func (i *control) removeConsumerLab(ctx context.Context, labUUID uuid.UUID) {
+	if err := i.consumer.RemoveUser(context.Background(), labUUID.String()); err != nil {
+		logger.Errorf(ctx, "schedule control remove lab from consumer uuid: %s, err: %+v", labUUID, err)
+	}
+}
@@ -0,0 +1,326 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/scienceol/studio/service/internal/config"
+	"github.com/scienceol/studio/service/pkg/core/notify"
+	"github.com/scienceol/studio/service/pkg/core/schedule/edge"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+/*
+	消费 api 服务投递的全局工作流队列，按实验室路由到对应的 lab 队列
+1. 多个调度实例共享全局队列，每个实例只认领连接在本实例的实验室和本实例运行的仿真任务的消息
+2. 实验室离线的消息由任意实例处理，启动任务直接置为失败，仿真任务在认领的实例运行
+3. 长时间无实例认领的消息转入死信队列，未启动的任务置为失败
+*/
+
+// 全局队列无可认领消息时的等待间隔
+const jobIdlePeriod = 200 * time.Millisecond
+
+func (i *control) startJobConsumer(ctx context.Context) {
+	queueName := config.Global().Job.JobQueueName
+	i.consumer.SetMessageExpireTimeout(config.Global().Job.MessageExpireSecond)
+	i.wait.Add(1)
+	utils.SafelyGo(func() {
+		defer i.wait.Done()
+		for {
+			consumed := false
+			if err := utils.SafelyRun(func() {
+				consumed = i.consumer.Message(ctx, queueName, func(msg []byte) {
+					i.onJobMessage(ctx, string(msg))
+				}, func(msg []byte) {
+					i.onExpiredMessage(ctx, string(msg))
+				})
+			}); err != nil {
+				logger.Errorf(ctx, "control.onJobMessage err: %+v", err)
+			}
+
+			if consumed {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				logger.Infof(ctx, "control.startJobConsumer exit")
+				return
+			case <-time.After(jobIdlePeriod):
+			}
+		}
+	}, func(err error) {
+		logger.Errorf(ctx, "control.startJobConsumer SafelyGo err: %+v", err)
+	})
+}
+
+// 过期消息已转入死信队列，未启动的任务置为失败
+func (i *control) onExpiredMessage(ctx context.Context, msg string) {
+	logger.Warnf(ctx, "control.onExpiredMessage dead letter msg: %s", msg)
+	info := &engine.WorkflowInfo{}
+	if err := json.Unmarshal([]byte(msg), info); err != nil || info.TaskUUID.IsNil() {
+		return
+	}
+
+	switch info.Action {
+	case engine.StartJob, engine.SimulateJob:
+		i.failTask(ctx, info, "dispatch timeout, no schedule claimed the task")
+	default:
+	}
+}
+
+func (i *control) onJobMessage(ctx context.Context, msg string) {
+	logger.Infof(ctx, "schedule control onJobMessage msg: %s", msg)
+	info := &engine.WorkflowInfo{}
+	if err := json.Unmarshal([]byte(msg), info); err != nil {
+		logger.Errorf(ctx, "control.onJobMessage unmarshal err: %+v, msg: %s", err, msg)
+		return
+	}
+
+	if info.LabUUID.IsNil() || info.TaskUUID.IsNil() {
+		logger.Warnf(ctx, "control.onJobMessage param err: %+v", info)
+		return
+	}
+
+	switch info.Action {
+	case engine.StartJob:
+		i.routeStartJob(ctx, info)
+	case engine.SimulateJob:
+		i.runSimulation(ctx, info)
+	case engine.StopJob:
+		i.routeControlJob(ctx, info, edge.StopJob)
+	case engine.PauseJob:
+		i.routeControlJob(ctx, info, edge.PauseJob)
+	case engine.ContinueJob:
+		i.routeControlJob(ctx, info, edge.ContinueJob)
+	case engine.StatusJob:
+		i.routeStatusJob(ctx, info)
+	case engine.ManualJob:
+		i.routeManualJob(ctx, info)
+	default:
+		logger.Errorf(ctx, "control.onJobMessage unknown action: %s", info.Action)
+	}
+}
+
+// 启动任务: 实验室在线则投递到实验室任务队列，否则直接置为失败
+func (i *control) routeStartJob(ctx context.Context, info *engine.WorkflowInfo) {
+	if !i.isLabOnline(ctx, info) {
+		i.failTask(ctx, info, "lab offline")
+		return
+	}
+
+	data := edge.ApiData[*engine.WorkflowInfo]{
+		ApiMsg: edge.ApiMsg{
+			Action: edge.StartWorkflow,
+		},
+		Data: info,
+	}
+
+	dataB, _ := json.Marshal(data)
+	if err := i.rClient.LPush(ctx, utils.LabTaskName(info.LabUUID), dataB).Err(); err != nil {
+		logger.Errorf(ctx, "control.routeStartJob push lab task uuid: %s, err: %+v", info.TaskUUID, err)
+		i.failTask(ctx, info, "dispatch task fail")
+	}
+}
+
+// 停止、暂停、继续任务: 实验室离线时任务未在运行，无需转发
+func (i *control) routeControlJob(ctx context.Context, info *engine.WorkflowInfo, action edge.ApiControlAction) {
+	if i.controlSimulation(ctx, info.TaskUUID, info.Action) {
+		return
+	}
+
+	if !i.isLabOnline(ctx, info) {
+		return
+	}
+
+	data := edge.ApiControlData[edge.StopJobReq]{
+		ApiControlMsg: edge.ApiControlMsg{
+			Action: action,
+		},
+		Data: edge.StopJobReq{
+			UUID:   info.TaskUUID,
+			UserID: info.UserID,
+		},
+	}
+
+	dataB, _ := json.Marshal(data)
+	if err := i.rClient.LPush(ctx, utils.LabControlName(info.LabUUID), dataB).Err(); err != nil {
+		logger.Errorf(ctx, "control.routeControlJob push lab control action: %s, uuid: %s, err: %+v", action, info.TaskUUID, err)
+	}
+}
+
+// 查询任务实时状态: 仿真任务由本实例回复，实验室离线时任务未在运行，直接回复
+func (i *control) routeStatusJob(ctx context.Context, info *engine.WorkflowInfo) {
+	if value, ok := i.simulations.Load(info.TaskUUID); ok {
+		engine.ReplyStatus(ctx, i.boardEvent, info, value.(engine.Task))
+		return
+	}
+
+	if !i.isLabOnline(ctx, info) {
+		engine.ReplyStatus(ctx, i.boardEvent, info, nil)
+		return
+	}
+
+	data := edge.ApiControlData[edge.StatusJobReq]{
+		ApiControlMsg: edge.ApiControlMsg{
+			Action: edge.StatusJob,
+		},
+		Data: edge.StatusJobReq{
+			UUID:        info.TaskUUID,
+			RequestUUID: info.RequestUUID,
+		},
+	}
+
+	dataB, _ := json.Marshal(data)
+	if err := i.rClient.LPush(ctx, utils.LabControlName(info.LabUUID), dataB).Err(); err != nil {
+		logger.Errorf(ctx, "control.routeStatusJob push lab control uuid: %s, err: %+v", info.TaskUUID, err)
+	}
+}
+
+// 人工节点处理结果: 仿真任务由本实例处理，实验室离线时任务未在运行，待恢复后重新处理
+func (i *control) routeManualJob(ctx context.Context, info *engine.WorkflowInfo) {
+	if info.Manual == nil {
+		logger.Warnf(ctx, "control.routeManualJob empty decision uuid: %s", info.TaskUUID)
+		return
+	}
+
+	if value, ok := i.simulations.Load(info.TaskUUID); ok {
+		if err := value.(engine.Task).OnManualDecision(ctx, info.Manual); err != nil {
+			logger.Errorf(ctx, "control.routeManualJob simulation uuid: %s, err: %+v", info.TaskUUID, err)
+		}
+		return
+	}
+
+	if !i.isLabOnline(ctx, info) {
+		logger.Warnf(ctx, "control.routeManualJob lab offline uuid: %s", info.TaskUUID)
+		return
+	}
+
+	data := edge.ApiControlData[edge.ManualJobReq]{
+		ApiControlMsg: edge.ApiControlMsg{
+			Action: edge.ManualJob,
+		},
+		Data: edge.ManualJobReq{
+			UUID:     info.TaskUUID,
+			Decision: info.Manual,
+		},
+	}
+
+	dataB, _ := json.Marshal(data)
+	if err := i.rClient.LPush(ctx, utils.LabControlName(info.LabUUID), dataB).Err(); err != nil {
+		logger.Errorf(ctx, "control.routeManualJob push lab control uuid: %s, err: %+v", info.TaskUUID, err)
+	}
+}
+
+// 调度启动后等待 edge 重连，仍离线的实验室其运行中任务无法恢复，置为失败
+func (i *control) startRecovery(ctx context.Context) {
+	grace := time.Duration(config.Global().Job.RecoverGraceSecond) * time.Second
+	i.wait.Add(1)
+	utils.SafelyGo(func() {
+		defer i.wait.Done()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(grace):
+		}
+
+		i.failOfflineTasks(ctx)
+	}, func(err error) {
+		logger.Errorf(ctx, "control.startRecovery SafelyGo err: %+v", err)
+	})
+}
+
+func (i *control) failOfflineTasks(ctx context.Context) {
+	tasks := make([]*model.WorkflowTask, 0, 1)
+	if err := i.workflowStore.FindDatas(ctx, &tasks, map[string]any{
+		"status": []model.WorkflowTaskStatus{
+			model.WorkflowTaskStatusRunnig,
+			model.WorkflowTaskStatusPaused,
+		},
+		"simulated":      false,
+		"parent_task_id": 0, // 子工作流任务随父任务处理
+	}, "id", "uuid", "lab_id", "workflow_id", "user_id"); err != nil {
+		logger.Errorf(ctx, "control.failOfflineTasks find tasks err: %+v", err)
+		return
+	}
+
+	if len(tasks) == 0 {
+		return
+	}
+
+	labIDs := utils.FilterUniqSlice(tasks, func(t *model.WorkflowTask) (int64, bool) {
+		return t.LabID, true
+	})
+	workflowIDs := utils.FilterUniqSlice(tasks, func(t *model.WorkflowTask) (int64, bool) {
+		return t.WorkflowID, true
+	})
+	labMap := i.workflowStore.ID2UUID(ctx, &model.Laboratory{}, labIDs...)
+	workflowMap := i.workflowStore.ID2UUID(ctx, &model.Workflow{}, workflowIDs...)
+
+	for _, task := range tasks {
+		info := &engine.WorkflowInfo{
+			TaskUUID:     task.UUID,
+			WorkflowUUID: workflowMap[task.WorkflowID],
+			LabUUID:      labMap[task.LabID],
+			UserID:       task.UserID,
+		}
+
+		if info.LabUUID.IsNil() || i.isLabOnline(ctx, info) {
+			continue
+		}
+
+		logger.Warnf(ctx, "control.failOfflineTasks lab offline task uuid: %s", task.UUID)
+		i.failTask(ctx, info, "lab offline, task interrupted")
+	}
+}
+
+func (i *control) isLabOnline(ctx context.Context, info *engine.WorkflowInfo) bool {
+	count, err := i.rClient.Exists(ctx, utils.LabHeartName(info.LabUUID)).Result()
+	if err != nil {
+		logger.Errorf(ctx, "control.isLabOnline lab uuid: %s, err: %+v", info.LabUUID, err)
+		return false
+	}
+
+	return count > 0
+}
+
+func (i *control) failTask(ctx context.Context, info *engine.WorkflowInfo, msg string) {
+	now := time.Now()
+	data := &model.WorkflowTask{
+		Status:       model.WorkflowTaskStatusFailed,
+		FinishedTime: now,
+	}
+	data.UpdatedAt = now
+	if err := i.workflowStore.UpdateData(ctx, data, map[string]any{
+		"uuid": info.TaskUUID,
+		"status": []model.WorkflowTaskStatus{
+			model.WorkflowTaskStatusPending,
+			model.WorkflowTaskStatusRunnig,
+			model.WorkflowTaskStatusPaused,
+		},
+	}, "status", "updated_at", "finished_time"); err != nil {
+		logger.Errorf(ctx, "control.failTask update task uuid: %s, err: %+v", info.TaskUUID, err)
+	}
+
+	if err := i.boardEvent.Broadcast(ctx, &notify.SendMsg{
+		Channel:      notify.WorkflowRun,
+		TaskUUID:     info.TaskUUID,
+		LabUUID:      info.LabUUID,
+		WorkflowUUID: info.WorkflowUUID,
+		UserID:       info.UserID,
+		UUID:         info.TaskUUID,
+		Data: &engine.BoardMsg{
+			TaskStatus: "end",
+			JobStatus:  string(model.WorkflowJobFailed),
+			Type:       "error",
+			Msg:        msg,
+			Timestamp:  now,
+		},
+	}); err != nil {
+		logger.Errorf(ctx, "control.failTask board msg err: %+v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package control
+
+import (
+	"context"
+	"errors"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/edge/mock"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine/dag"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+// 仿真运行：在调度进程内使用 mock edge 运行工作流，不经过实验室队列，实验室离线也可运行
+// 仿真任务登记所属调度实例，多实例部署时控制消息只由运行任务的实例处理
+
+func (i *control) runSimulation(ctx context.Context, info *engine.WorkflowInfo) {
+	task := &model.WorkflowTask{}
+	if err := i.workflowStore.GetData(ctx, task, map[string]any{
+		"uuid":      info.TaskUUID,
+		"simulated": true,
+	}, "id", "simulate"); err != nil {
+		logger.Errorf(ctx, "control.runSimulation can not found task uuid: %s, err: %+v", info.TaskUUID, err)
+		i.failTask(ctx, info, "simulated task not found")
+		return
+	}
+
+	taskCtx, cancel := context.WithCancel(ctx)
+	mockEdge := mock.NewEdge(taskCtx, task.Simulate.Data())
+	dagTask := dag.NewDagTask(taskCtx, &engine.TaskParam{
+		Session:    mockEdge,
+		Cancle:     cancel,
+		Sandbox:    i.sandbox,
+		BoardEvent: i.boardEvent,
+	})
+	mockEdge.Bind(dagTask)
+
+	if _, loaded := i.simulations.LoadOrStore(info.TaskUUID, dagTask); loaded {
+		logger.Warnf(ctx, "control.runSimulation task already running uuid: %s", info.TaskUUID)
+		dagTask.Release()
+		mockEdge.Close()
+		cancel()
+		return
+	}
+
+	// 登记任务由本实例运行，后续停止、暂停、继续消息路由到本实例
+	if err := i.consumer.AddTask(ctx, info.TaskUUID.String()); err != nil {
+		logger.Errorf(ctx, "control.runSimulation add task owner uuid: %s, err: %+v", info.TaskUUID, err)
+	}
+
+	info.Action = engine.StartJob
+	i.wait.Add(1)
+	utils.SafelyGo(func() {
+		defer i.wait.Done()
+		defer i.simulations.Delete(info.TaskUUID)
+		defer func() {
+			if err := i.consumer.RemoveTask(context.Background(), info.TaskUUID.String()); err != nil {
+				logger.Errorf(ctx, "control.runSimulation remove task owner uuid: %s, err: %+v", info.TaskUUID, err)
+			}
+		}()
+		defer cancel()
+		defer mockEdge.Close()
+
+		err := dagTask.Run(taskCtx, info)
+		// 仿真任务无法在其他调度实例恢复，调度退出导致的中断直接置为失败
+		if errors.Is(err, code.JobInterruptedErr) {
+			i.failTask(context.Background(), info, "simulation interrupted")
+			return
+		}
+
+		if err != nil {
+			logger.Warnf(ctx, "control.runSimulation task uuid: %s, err: %+v", info.TaskUUID, err)
+		}
+	}, func(err error) {
+		logger.Errorf(ctx, "control.runSimulation SafelyGo err: %+v", err)
+	})
+}
+
+// 停止、暂停、继续本实例运行的仿真任务，返回任务是否在本实例运行
+func (i *control) controlSimulation(ctx context.Context, taskUUID uuid.UUID, action engine.WorkflowAction) bool {
+	value, ok := i.simulations.Load(taskUUID)
+	if !ok {
+		return false
+	}
+
+	task := value.(engine.Task)
+	var err error
+	switch action {
+	case engine.StopJob:
+		err = task.Stop(ctx)
+	case engine.PauseJob:
+		err = task.Pause(ctx)
+	case engine.ContinueJob:
+		err = task.Continue(ctx)
+	}
+	if err != nil {
+		logger.Errorf(ctx, "control.controlSimulation action: %s, task uuid: %s, err: %+v", action, taskUUID, err)
+	}
+
+	return true
+}
@@ -0,0 +1,278 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/scienceol/studio/service/internal/config"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/core/schedule/lock/leader"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+/*
+	定时触发工作流，多个调度实例中只有 leader 运行
+1. 到达触发时间后创建工作流任务投递到全局工作流队列，与手动运行相同路径
+2. 实验室离线、上次触发的任务未结束或实验室任务数已满时，按触发配置跳过或排队等待
+3. 每次触发结果记录到触发历史
+*/
+
+const (
+	triggerPeriod = time.Second
+	triggerLease  = 10 * time.Second
+	triggerBatch  = 100
+)
+
+func (i *control) startTrigger(ctx context.Context) {
+	elector := leader.New("workflow_trigger", i.scheduleName, triggerLease)
+	i.wait.Add(1)
+	utils.SafelyGo(func() {
+		defer i.wait.Done()
+		defer elector.Resign(context.Background())
+
+		ticker := time.NewTicker(triggerPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Infof(ctx, "control.startTrigger exit")
+				return
+			case <-ticker.C:
+			}
+
+			isLeader, err := elector.Campaign(ctx)
+			if err != nil {
+				logger.Warnf(ctx, "control.startTrigger campaign err: %+v", err)
+				continue
+			}
+
+			if !isLeader {
+				continue
+			}
+
+			if err := utils.SafelyRun(func() {
+				i.runDueTriggers(ctx)
+			}); err != nil {
+				logger.Errorf(ctx, "control.runDueTriggers err: %+v", err)
+			}
+		}
+	}, func(err error) {
+		logger.Errorf(ctx, "control.startTrigger SafelyGo err: %+v", err)
+	})
+}
+
+func (i *control) runDueTriggers(ctx context.Context) {
+	now := time.Now()
+	triggers, err := i.workflowStore.GetDueTriggers(ctx, now, triggerBatch)
+	if err != nil {
+		return
+	}
+
+	for _, trigger := range triggers {
+		i.fireTrigger(ctx, trigger, now)
+	}
+}
+
+func (i *control) fireTrigger(ctx context.Context, trigger *model.WorkflowTrigger, now time.Time) {
+	keys := []string{"queued"}
+	fireTime := trigger.NextRunTime
+	due := trigger.Enabled && !fireTime.After(now)
+	claimKeys := []string{"updated_at"}
+	if due {
+		next, err := trigger.NextRunAfter(now)
+		if err != nil {
+			logger.Warnf(ctx, "control.fireTrigger next run trigger uuid: %s, err: %+v", trigger.UUID, err)
+		}
+
+		// 已到截止时间或不会再触发，停用
+		trigger.NextRunTime = next
+		trigger.Enabled = !next.IsZero()
+		keys = append(keys, "next_run_time", "enabled")
+		claimKeys = append(claimKeys, "next_run_time", "enabled")
+	}
+
+	// 下发前先抢占，leader 切换时其他实例已处理的触发不再重复运行
+	readUpdatedAt := trigger.UpdatedAt
+	trigger.UpdatedAt = time.Now()
+	claimed, err := i.workflowStore.ClaimTrigger(ctx, trigger, fireTime, readUpdatedAt, claimKeys...)
+	if err != nil || !claimed {
+		return
+	}
+
+	defer func() {
+		if err := i.workflowStore.UpdateData(ctx, trigger, map[string]any{
+			"id": trigger.ID,
+		}, append(keys, "updated_at")...); err != nil {
+			logger.Errorf(ctx, "control.fireTrigger update trigger uuid: %s, err: %+v", trigger.UUID, err)
+		}
+	}()
+
+	wk := &model.Workflow{}
+	if err := i.workflowStore.GetData(ctx, wk, map[string]any{
+		"id": trigger.WorkflowID,
+	}, "id", "uuid", "lab_id"); err != nil {
+		logger.Warnf(ctx, "control.fireTrigger can not get workflow trigger uuid: %s, err: %+v", trigger.UUID, err)
+		trigger.Enabled = false
+		trigger.Queued = false
+		keys = utils.AppendUniqSlice(keys, "enabled")
+		i.recordTrigger(ctx, trigger, fireTime, model.TriggerRecordFailed, 0, "workflow not found")
+		return
+	}
+
+	info := &engine.WorkflowInfo{
+		Action:       engine.StartJob,
+		WorkflowUUID: wk.UUID,
+		LabUUID:      i.workflowStore.ID2UUID(ctx, &model.Laboratory{}, wk.LabID)[wk.LabID],
+		UserID:       trigger.UserID,
+	}
+
+	reason := i.triggerBlocked(ctx, trigger, info)
+	switch {
+	case reason == "":
+		taskID, err := i.dispatchTrigger(ctx, trigger, info)
+		if err != nil {
+			logger.Errorf(ctx, "control.fireTrigger dispatch trigger uuid: %s, err: %+v", trigger.UUID, err)
+			if due {
+				i.recordTrigger(ctx, trigger, fireTime, model.TriggerRecordFailed, 0, err.Error())
+			}
+			return
+		}
+
+		// 排队中的触发与本次到期合并为一次运行，只有排队记录为 triggered
+		switch {
+		case trigger.Queued && due:
+			i.finishQueuedRecord(ctx, trigger, taskID)
+			i.recordTrigger(ctx, trigger, fireTime, model.TriggerRecordMerged, taskID, "merged with queued trigger")
+		case trigger.Queued:
+			i.finishQueuedRecord(ctx, trigger, taskID)
+		case due:
+			i.recordTrigger(ctx, trigger, fireTime, model.TriggerRecordTriggered, taskID, "")
+		}
+
+		trigger.Queued = false
+		trigger.LastTaskID = taskID
+		trigger.LastRunTime = &now
+		keys = append(keys, "last_task_id", "last_run_time")
+	case trigger.MissPolicy == model.TriggerMissQueue:
+		if !due {
+			return
+		}
+
+		// 已有排队的触发，合并为一次
+		if trigger.Queued {
+			i.recordTrigger(ctx, trigger, fireTime, model.TriggerRecordSkipped, 0, "already queued, "+reason)
+			return
+		}
+
+		trigger.Queued = true
+		i.recordTrigger(ctx, trigger, fireTime, model.TriggerRecordQueued, 0, reason)
+	default:
+		trigger.Queued = false
+		if due {
+			i.recordTrigger(ctx, trigger, fireTime, model.TriggerRecordSkipped, 0, reason)
+		}
+	}
+}
+
+// 返回无法运行的原因，为空表示可以运行
+func (i *control) triggerBlocked(ctx context.Context, trigger *model.WorkflowTrigger, info *engine.WorkflowInfo) string {
+	if info.LabUUID.IsNil() || !i.isLabOnline(ctx, info) {
+		return "lab offline"
+	}
+
+	activeStatus := []model.WorkflowTaskStatus{
+		model.WorkflowTaskStatusPending,
+		model.WorkflowTaskStatusRunnig,
+		model.WorkflowTaskStatusPaused,
+	}
+
+	if trigger.LastTaskID > 0 {
+		count, err := i.workflowStore.Count(ctx, &model.WorkflowTask{}, map[string]any{
+			"id":     trigger.LastTaskID,
+			"status": activeStatus,
+		})
+		if err != nil {
+			return "query task status fail"
+		}
+
+		if count > 0 {
+			return "previous task still running"
+		}
+	}
+
+	count, err := i.workflowStore.Count(ctx, &model.WorkflowTask{}, map[string]any{
+		"lab_id":    trigger.LabID,
+		"status":    activeStatus,
+		"simulated": false,
+	})
+	if err != nil {
+		return "query task status fail"
+	}
+
+	if count >= int64(config.Global().Job.LabMaxTasks) {
+		return "lab busy"
+	}
+
+	return ""
+}
+
+// 创建任务并投递到全局工作流队列，返回任务 id
+func (i *control) dispatchTrigger(ctx context.Context, trigger *model.WorkflowTrigger, info *engine.WorkflowInfo) (int64, error) {
+	var taskID int64
+	err := i.workflowStore.ExecTx(ctx, func(txCtx context.Context) error {
+		task := &model.WorkflowTask{
+			LabID:      trigger.LabID,
+			WorkflowID: trigger.WorkflowID,
+			UserID:     trigger.UserID,
+			Params:     trigger.Params,
+		}
+		if err := i.workflowStore.CreateWorkflowTask(txCtx, task); err != nil {
+			return err
+		}
+		taskID = task.ID
+		info.TaskUUID = task.UUID
+
+		dataB, _ := json.Marshal(info)
+		if err := i.rClient.LPush(ctx, config.Global().Job.JobQueueName, dataB).Err(); err != nil {
+			return code.ParamErr.WithMsgf("push workflow redis msg err: %+v", err)
+		}
+
+		return nil
+	})
+
+	return taskID, err
+}
+
+func (i *control) recordTrigger(ctx context.Context, trigger *model.WorkflowTrigger, fireTime time.Time,
+	status model.TriggerRecordStatus, taskID int64, msg string,
+) {
+	if err := i.workflowStore.CreateData(ctx, &model.WorkflowTriggerRecord{
+		Source:    model.TriggerSourceSchedule,
+		TriggerID: trigger.ID,
+		TaskID:    taskID,
+		FireTime:  fireTime,
+		Status:    status,
+		Message:   msg,
+	}); err != nil {
+		logger.Errorf(ctx, "control.recordTrigger trigger uuid: %s, err: %+v", trigger.UUID, err)
+	}
+}
+
+// 排队的触发已运行，更新历史记录
+func (i *control) finishQueuedRecord(ctx context.Context, trigger *model.WorkflowTrigger, taskID int64) {
+	record := &model.WorkflowTriggerRecord{
+		TaskID: taskID,
+		Status: model.TriggerRecordTriggered,
+	}
+	record.UpdatedAt = time.Now()
+	if err := i.workflowStore.UpdateData(ctx, record, map[string]any{
+		"trigger_id": trigger.ID,
+		"status":     model.TriggerRecordQueued,
+	}, "task_id", "status", "updated_at"); err != nil {
+		logger.Errorf(ctx, "control.finishQueuedRecord trigger uuid: %s, err: %+v", trigger.UUID, err)
+	}
+}
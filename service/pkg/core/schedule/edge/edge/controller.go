@@ -3,7 +3,6 @@ package edge
 import (
 	"context"
 	"encoding/json"
-	"reflect"
 
 	"github.com/scienceol/studio/service/pkg/core/schedule/edge"
 	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
@@ -28,8 +27,14 @@ func (e *EdgeImpl) onControlMessage(ctx context.Context, msg string) {
 		e.onStartAction(ctx, msg)
 	case edge.StopJob:
 		e.onStopJob(ctx, msg)
+	case edge.PauseJob:
+		e.onPauseJob(ctx, msg)
+	case edge.ContinueJob:
+		e.onContinueJob(ctx, msg)
 	case edge.StatusJob:
 		e.onStatusJob(ctx, msg)
+	case edge.ManualJob:
+		e.onManualJob(ctx, msg)
 	case edge.AddMaterial, edge.UpdateMaterial, edge.RemoveMaterial:
 		e.onMaterial(ctx, msg)
 	default:
@@ -37,6 +42,7 @@ func (e *EdgeImpl) onControlMessage(ctx context.Context, msg string) {
 	}
 }
 
+// 手动动作独立运行，等待设备锁时不阻塞控制队列
 func (e *EdgeImpl) onStartAction(ctx context.Context, msg string) {
 	apiMsg := &edge.ApiControlData[engine.WorkflowInfo]{}
 	if err := json.Unmarshal([]byte(msg), apiMsg); err != nil {
@@ -44,49 +50,109 @@ func (e *EdgeImpl) onStartAction(ctx context.Context, msg string) {
 		return
 	}
 
-	defer func() { e.actionTask = nil }()
-	e.actionTask = action.NewActionTask(ctx, &engine.TaskParam{
+	taskCtx, cancel := context.WithCancel(ctx)
+	taskUUID := apiMsg.Data.TaskUUID
+	task := action.NewActionTask(taskCtx, &engine.TaskParam{
 		Session:    e.labInfo.Session,
-		Cancle:     e.cancel,
+		Cancle:     cancel,
 		Sandbox:    e.labInfo.Sandbox,
 		BoardEvent: e.boardEvent,
 	})
+	if !e.addTask(ctx, taskUUID, task) {
+		cancel()
+		return
+	}
 
-	if err := utils.SafelyRun(func() {
-		if err := e.actionTask.Run(ctx, &apiMsg.Data); err != nil {
+	e.taskWait.Add(1)
+	utils.SafelyGo(func() {
+		defer e.taskWait.Done()
+		defer cancel()
+		defer e.removeTask(taskUUID)
+		if err := task.Run(taskCtx, &apiMsg.Data); err != nil {
 			logger.Errorf(ctx, "EdgeImpl.onStartAction run err: %+v", err)
 		}
-	}); err != nil {
+	}, func(err error) {
 		logger.Errorf(ctx, "EdgeImpl.onNotebookJob err: %+v", err)
-	}
+	})
 }
 
 func (e *EdgeImpl) onStopJob(ctx context.Context, msg string) {
 	// 停止 workflow 、notebook
-	if e.jobTask == nil {
+	task, ok := e.controlTask(ctx, msg)
+	if !ok {
 		return
-	} else {
-		v := reflect.ValueOf(e.jobTask)
-		if v.Kind() == reflect.Ptr && v.IsNil() {
-			return
-		}
 	}
 
-	apiControlData := &edge.ApiControlData[edge.StopJobReq]{}
-	if err := json.Unmarshal([]byte(msg), apiControlData); err != nil {
-		logger.Errorf(ctx, "EdgeImpl.onAddMaterial unmarshal err: %+v", err)
+	if err := task.Stop(ctx); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.onStopJob stop err: %+v", err)
+	}
+}
+
+func (e *EdgeImpl) onPauseJob(ctx context.Context, msg string) {
+	task, ok := e.controlTask(ctx, msg)
+	if !ok {
 		return
 	}
 
-	if apiControlData.Data.UUID == e.jobTask.ID(ctx) {
-		if err := e.jobTask.Stop(ctx); err != nil {
-			logger.Errorf(ctx, "EdgeImpl.onStopJob stop err: %+v", err)
-		}
+	if err := task.Pause(ctx); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.onPauseJob pause err: %+v", err)
+	}
+}
+
+func (e *EdgeImpl) onContinueJob(ctx context.Context, msg string) {
+	task, ok := e.controlTask(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if err := task.Continue(ctx); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.onContinueJob continue err: %+v", err)
+	}
+}
+
+// 控制消息对应的运行中任务
+func (e *EdgeImpl) controlTask(ctx context.Context, msg string) (engine.Task, bool) {
+	apiControlData := &edge.ApiControlData[edge.StopJobReq]{}
+	if err := json.Unmarshal([]byte(msg), apiControlData); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.controlTask unmarshal err: %+v", err)
+		return nil, false
 	}
+
+	return e.getTask(apiControlData.Data.UUID)
 }
 
+// 查询运行中任务的实时状态，任务不在本实验室运行时回复未运行
 func (e *EdgeImpl) onStatusJob(ctx context.Context, msg string) {
-	panic("not implements")
+	apiControlData := &edge.ApiControlData[edge.StatusJobReq]{}
+	if err := json.Unmarshal([]byte(msg), apiControlData); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.onStatusJob unmarshal err: %+v", err)
+		return
+	}
+
+	task, _ := e.getTask(apiControlData.Data.UUID)
+	engine.ReplyStatus(ctx, e.boardEvent, &engine.WorkflowInfo{
+		TaskUUID:    apiControlData.Data.UUID,
+		LabUUID:     e.labInfo.UUID,
+		RequestUUID: apiControlData.Data.RequestUUID,
+	}, task)
+}
+
+func (e *EdgeImpl) onManualJob(ctx context.Context, msg string) {
+	apiControlData := &edge.ApiControlData[edge.ManualJobReq]{}
+	if err := json.Unmarshal([]byte(msg), apiControlData); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.onManualJob unmarshal err: %+v", err)
+		return
+	}
+
+	task, ok := e.getTask(apiControlData.Data.UUID)
+	if !ok {
+		logger.Warnf(ctx, "EdgeImpl.onManualJob task not running uuid: %s", apiControlData.Data.UUID)
+		return
+	}
+
+	if err := task.OnManualDecision(ctx, apiControlData.Data.Decision); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.onManualJob uuid: %s, err: %+v", apiControlData.Data.UUID, err)
+	}
 }
 
 func (e *EdgeImpl) onMaterial(ctx context.Context, msg string) {
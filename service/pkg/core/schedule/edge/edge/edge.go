@@ -7,7 +7,9 @@ import (
 	"time"
 
 	r "github.com/redis/go-redis/v9"
+	"github.com/scienceol/studio/service/internal/config"
 	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
 	"github.com/scienceol/studio/service/pkg/core/notify"
 	"github.com/scienceol/studio/service/pkg/core/notify/events"
 	"github.com/scienceol/studio/service/pkg/core/schedule/edge"
@@ -16,6 +18,7 @@ import (
 	"github.com/scienceol/studio/service/pkg/middleware/redis"
 	"github.com/scienceol/studio/service/pkg/repo"
 	mStore "github.com/scienceol/studio/service/pkg/repo/material"
+	wfl "github.com/scienceol/studio/service/pkg/repo/workflow"
 	"github.com/scienceol/studio/service/pkg/utils"
 )
 
@@ -25,9 +28,12 @@ type EdgeImpl struct {
 	cancel        context.CancelFunc
 	rClient       *r.Client // redis client
 	labInfo       *edge.LabInfo
-	jobTask       engine.Task // workflow or notebook task
-	actionTask    engine.Task
+	taskLock      sync.RWMutex
+	tasks         map[uuid.UUID]engine.Task // 运行中的 workflow、action 任务
+	taskSlots     chan struct{}             // 工作流并发名额
+	taskWait      sync.WaitGroup
 	materialStore repo.MaterialRepo // 物料调度
+	workflowStore repo.WorkflowRepo // 工作流存储
 	boardEvent    notify.MsgCenter  // 广播系统
 	wait          sync.WaitGroup
 }
@@ -40,7 +46,10 @@ func NewEdge(ctx context.Context, labInfo *edge.LabInfo) (edge.Edge, error) {
 		cancel:        cancel,
 		rClient:       redis.GetClient(),
 		labInfo:       labInfo,
+		tasks:         make(map[uuid.UUID]engine.Task),
+		taskSlots:     make(chan struct{}, max(config.Global().Job.LabMaxTasks, 1)),
 		materialStore: mStore.NewMaterialImpl(),
+		workflowStore: wfl.New(),
 		boardEvent:    events.NewEvents(),
 		wait:          sync.WaitGroup{},
 	}
@@ -123,13 +132,22 @@ func (e *EdgeImpl) startControlConsumer(ctx context.Context) {
 	})
 }
 
-// 启动任务队列消费
+// 启动任务队列消费，每个工作流独立运行，超出并发上限的任务留在队列中排队
 func (e *EdgeImpl) startTaskConsumer(ctx context.Context) {
 	taskName := utils.LabTaskName(e.labInfo.UUID)
 	utils.SafelyGo(func() {
 		defer e.wait.Done()
 		for {
+			if !e.acquireTaskSlot(ctx) {
+				logger.Infof(ctx, "EdgeImpl.startTask exit")
+				return
+			}
+
 			res, err := e.rClient.BRPop(ctx, 10*time.Second, taskName).Result()
+			if err != nil || len(res) < 2 {
+				e.releaseTaskSlot()
+			}
+
 			if err != nil && err == r.Nil {
 				continue
 			}
@@ -144,15 +162,20 @@ func (e *EdgeImpl) startTaskConsumer(ctx context.Context) {
 				continue
 			}
 
-			if len(res) == 0 {
-				logger.Warnf(ctx, "EdgeImpl.startTask err: %+v", err)
+			if len(res) < 2 {
+				logger.Warnf(ctx, "EdgeImpl.startTask empty msg name: %s", taskName)
 				continue
 			}
-			if err := utils.SafelyRun(func() {
-				e.OnJobMessage(ctx, res[1])
-			}); err != nil {
+
+			msg := res[1]
+			e.taskWait.Add(1)
+			utils.SafelyGo(func() {
+				defer e.taskWait.Done()
+				defer e.releaseTaskSlot()
+				e.OnJobMessage(ctx, msg)
+			}, func(err error) {
 				logger.Errorf(ctx, "EdgeImpl.onJobMessage err: %+v", err)
-			}
+			})
 		}
 	}, func(err error) {
 		logger.Errorf(ctx, "EdgeImpl.startTask SafelyGo err: %+v", err)
@@ -166,6 +189,7 @@ func (e *EdgeImpl) Close(ctx context.Context) {
 	}
 
 	e.wait.Wait()
+	e.taskWait.Wait()
 	logger.Infof(ctx, "EdgeImpl.Close exit lab id: %d", e.labInfo.ID)
 }
 
@@ -53,36 +53,17 @@ func (e *EdgeImpl) onJobStatus(ctx context.Context, s *melody.Session, b []byte)
 		return
 	}
 
-	e.onActionTask(ctx, &res)
-	e.onJobTask(ctx, &res)
-}
-
-func (e *EdgeImpl) onActionTask(ctx context.Context, updateData *edge.EdgeData[*engine.JobData]) {
-	if updateData == nil || updateData.Data == nil {
-		return
-	}
-
-	if e.isTaskNil(ctx, e.actionTask) {
-		return
-	}
-
-	if e.actionTask.ID(ctx) == updateData.Data.TaskID {
-		e.actionTask.OnJobUpdate(ctx, updateData.Data)
-	}
-}
-
-func (e *EdgeImpl) onJobTask(ctx context.Context, updateData *edge.EdgeData[*engine.JobData]) {
-	if updateData == nil || updateData.Data == nil {
+	if res.Data == nil {
 		return
 	}
 
-	if e.isTaskNil(ctx, e.jobTask) {
+	task, ok := e.getTask(res.Data.TaskID)
+	if !ok {
+		logger.Warnf(ctx, "onJobStatus can not found task uuid: %s", res.Data.TaskID)
 		return
 	}
 
-	if e.jobTask.ID(ctx) == updateData.Data.TaskID {
-		e.jobTask.OnJobUpdate(ctx, updateData.Data)
-	}
+	task.OnJobUpdate(ctx, res.Data)
 }
 
 // Edge Device Status Update
@@ -121,6 +102,17 @@ func (e *EdgeImpl) onDeviceStatus(ctx context.Context, s *melody.Session, b []by
 		return
 	}
 
+	// 通知等待该设备数据的节点
+	tasks := e.listTasks()
+	for _, n := range nodes {
+		for _, task := range tasks {
+			task.OnMaterialData(ctx, &engine.MaterialData{
+				DeviceName: res.Data.DeviceID,
+				Data:       n.Data,
+			})
+		}
+	}
+
 	data := utils.FilterSlice(nodes, func(n *model.MaterialNode) (*material.UpdateMaterialData, bool) {
 		return &material.UpdateMaterialData{
 			UUID: n.UUID,
@@ -175,40 +167,13 @@ func (e *EdgeImpl) onActionState(ctx context.Context, _ *melody.Session, b []byt
 		return
 	}
 
-	e.onAction(ctx, &res)
-	e.onJob(ctx, &res)
-}
-
-func (e *EdgeImpl) onAction(ctx context.Context, data *edge.EdgeData[edge.ActionStatus]) {
-	if data.Data.TaskID.IsNil() {
-		return
-	}
-
-	if e.isTaskNil(ctx, e.actionTask) {
-		return
-	}
-
-	if e.actionTask.ID(ctx) != data.Data.TaskID {
-		return
-	}
-
-	e.actionTask.SetDeviceActionStatus(ctx, data.Data.ActionKey, data.Data.ActionValue.Free, data.Data.NeedMore*time.Second)
-}
-
-func (e *EdgeImpl) onJob(ctx context.Context, data *edge.EdgeData[edge.ActionStatus]) {
-	if data.Data.TaskID.IsNil() {
-		return
-	}
-
-	if e.isTaskNil(ctx, e.jobTask) {
-		return
-	}
-
-	if e.jobTask.ID(ctx) != data.Data.TaskID {
+	task, ok := e.getTask(res.Data.TaskID)
+	if !ok {
+		logger.Warnf(ctx, "onActionState can not found task uuid: %s", res.Data.TaskID)
 		return
 	}
 
-	e.jobTask.SetDeviceActionStatus(ctx, data.Data.ActionKey, data.Data.ActionValue.Free, data.Data.NeedMore*time.Second)
+	task.SetDeviceActionStatus(ctx, res.Data.ActionKey, res.Data.ActionValue.Free, res.Data.NeedMore*time.Second)
 }
 
 func (e *EdgeImpl) onEdgeReady(ctx context.Context, _ *melody.Session, b []byte) {
@@ -221,6 +186,7 @@ func (e *EdgeImpl) onEdgeReady(ctx context.Context, _ *melody.Session, b []byte)
 	logger.Infof(ctx,
 		"onEdgeReady lab id: %d, status: %s, timestamp: %f",
 		e.labInfo.ID, res.Data.Status, res.Data.Timestamp)
+	e.recoverTasks(ctx)
 	e.startTaskConsumer(e.ctx)
 	e.startControlConsumer(e.ctx)
 	e.wait.Add(2)
@@ -0,0 +1,61 @@
+package edge
+
+import (
+	"context"
+
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+)
+
+// 实验室运行中的任务注册表，按任务 uuid 路由 edge 回调消息
+
+func (e *EdgeImpl) addTask(ctx context.Context, taskUUID uuid.UUID, task engine.Task) bool {
+	e.taskLock.Lock()
+	defer e.taskLock.Unlock()
+	if _, ok := e.tasks[taskUUID]; ok {
+		logger.Warnf(ctx, "EdgeImpl.addTask task already running uuid: %s", taskUUID)
+		return false
+	}
+
+	e.tasks[taskUUID] = task
+	return true
+}
+
+func (e *EdgeImpl) removeTask(taskUUID uuid.UUID) {
+	e.taskLock.Lock()
+	defer e.taskLock.Unlock()
+	delete(e.tasks, taskUUID)
+}
+
+func (e *EdgeImpl) getTask(taskUUID uuid.UUID) (engine.Task, bool) {
+	e.taskLock.RLock()
+	defer e.taskLock.RUnlock()
+	task, ok := e.tasks[taskUUID]
+	return task, ok
+}
+
+func (e *EdgeImpl) listTasks() []engine.Task {
+	e.taskLock.RLock()
+	defer e.taskLock.RUnlock()
+	tasks := make([]engine.Task, 0, len(e.tasks))
+	for _, task := range e.tasks {
+		tasks = append(tasks, task)
+	}
+
+	return tasks
+}
+
+// 获取工作流运行名额，达到实验室并发上限时阻塞
+func (e *EdgeImpl) acquireTaskSlot(ctx context.Context) bool {
+	select {
+	case e.taskSlots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (e *EdgeImpl) releaseTaskSlot() {
+	<-e.taskSlots
+}
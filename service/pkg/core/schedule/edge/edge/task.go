@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 
 	"github.com/scienceol/studio/service/pkg/core/schedule/edge"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine/dag"
 	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
 )
 
 //	处理 api 任务类消息
@@ -20,8 +24,99 @@ func (e *EdgeImpl) OnJobMessage(ctx context.Context, msg string) {
 	}
 
 	switch apiType.Action {
-
+	case edge.StartWorkflow, edge.ResumeWorkflow:
+		e.onStartWorkflow(ctx, msg)
 	default:
 		logger.Errorf(ctx, "EdgeImpl.onJobMessage unknown action: %s", apiType.Action)
 	}
 }
+
+// 运行工作流，阻塞直到工作流结束
+func (e *EdgeImpl) onStartWorkflow(ctx context.Context, msg string) {
+	apiMsg := &edge.ApiData[engine.WorkflowInfo]{}
+	if err := json.Unmarshal([]byte(msg), apiMsg); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.onStartWorkflow unmarshal err: %+v", err)
+		return
+	}
+
+	taskCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	taskUUID := apiMsg.Data.TaskUUID
+	task := dag.NewDagTask(taskCtx, &engine.TaskParam{
+		Session:    e.labInfo.Session,
+		Cancle:     cancel,
+		Sandbox:    e.labInfo.Sandbox,
+		BoardEvent: e.boardEvent,
+	})
+	if !e.addTask(ctx, taskUUID, task) {
+		task.Release()
+		return
+	}
+	defer e.removeTask(taskUUID)
+
+	if err := utils.SafelyRun(func() {
+		if err := task.Run(taskCtx, &apiMsg.Data); err != nil {
+			logger.Errorf(ctx, "EdgeImpl.onStartWorkflow run task uuid: %s, err: %+v", taskUUID, err)
+		}
+	}); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.onStartWorkflow err: %+v", err)
+	}
+}
+
+// edge 就绪后，将该实验室中断的运行中任务放回任务队列头部优先恢复
+// 子工作流任务由父任务恢复时驱动，不单独恢复
+func (e *EdgeImpl) recoverTasks(ctx context.Context) {
+	tasks := make([]*model.WorkflowTask, 0, 1)
+	if err := e.workflowStore.FindDatas(ctx, &tasks, map[string]any{
+		"lab_id": e.labInfo.ID,
+		"status": []model.WorkflowTaskStatus{
+			model.WorkflowTaskStatusRunnig,
+			model.WorkflowTaskStatusPaused,
+		},
+		"simulated":      false,
+		"parent_task_id": 0,
+	}, "id", "uuid", "workflow_id", "user_id"); err != nil {
+		logger.Errorf(ctx, "EdgeImpl.recoverTasks find tasks lab id: %d, err: %+v", e.labInfo.ID, err)
+		return
+	}
+
+	if len(tasks) == 0 {
+		return
+	}
+
+	workflowIDs := utils.FilterUniqSlice(tasks, func(t *model.WorkflowTask) (int64, bool) {
+		return t.WorkflowID, true
+	})
+	workflowMap := e.workflowStore.ID2UUID(ctx, &model.Workflow{}, workflowIDs...)
+
+	taskName := utils.LabTaskName(e.labInfo.UUID)
+	for _, task := range tasks {
+		workflowUUID, ok := workflowMap[task.WorkflowID]
+		if !ok {
+			logger.Warnf(ctx, "EdgeImpl.recoverTasks can not found workflow id: %d", task.WorkflowID)
+			continue
+		}
+
+		data := edge.ApiData[engine.WorkflowInfo]{
+			ApiMsg: edge.ApiMsg{
+				Action: edge.ResumeWorkflow,
+			},
+			Data: engine.WorkflowInfo{
+				Action:       engine.ResumeJob,
+				TaskUUID:     task.UUID,
+				WorkflowUUID: workflowUUID,
+				LabUUID:      e.labInfo.UUID,
+				UserID:       task.UserID,
+			},
+		}
+
+		dataB, _ := json.Marshal(data)
+		if err := e.rClient.RPush(ctx, taskName, dataB).Err(); err != nil {
+			logger.Errorf(ctx, "EdgeImpl.recoverTasks push task uuid: %s, err: %+v", task.UUID, err)
+			continue
+		}
+
+		logger.Infof(ctx, "EdgeImpl.recoverTasks resume task uuid: %s", task.UUID)
+	}
+}
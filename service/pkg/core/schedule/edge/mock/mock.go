@@ -0,0 +1,247 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/repo"
+	wfl "github.com/scienceol/studio/service/pkg/repo/workflow"
+	"github.com/scienceol/studio/service/pkg/utils"
+	"gorm.io/datatypes"
+)
+
+/*
+	内置 mock edge，仿真运行时代替真实 edge 应答调度下发的消息
+1. query_action_state 直接回复设备空闲
+2. job_start 按动作模板的 Result schema 生成返回值，延迟后回调 job 状态，按配置注入失败
+3. query_job_status 重新回调已完成动作的结果，cancel_task 取消所有未回调的动作
+*/
+
+type Edge struct {
+	ctx           context.Context
+	cancel        context.CancelFunc
+	conf          model.SimulateConfig
+	failNodes     map[uuid.UUID]struct{}
+	task          engine.Task
+	workflowStore repo.WorkflowRepo
+	closed        atomic.Bool
+
+	lock    sync.Mutex
+	results map[uuid.UUID]*engine.JobData // 已完成动作的结果，key 为 job uuid
+}
+
+func NewEdge(ctx context.Context, conf model.SimulateConfig) *Edge {
+	mockCtx, cancel := context.WithCancel(ctx)
+	return &Edge{
+		ctx:           mockCtx,
+		cancel:        cancel,
+		conf:          conf,
+		workflowStore: wfl.New(),
+		results:       make(map[uuid.UUID]*engine.JobData),
+		failNodes: utils.Slice2Map(conf.FailNodes, func(nodeUUID uuid.UUID) (uuid.UUID, struct{}) {
+			return nodeUUID, struct{}{}
+		}),
+	}
+}
+
+// 绑定接收回调的任务，需在任务运行前调用
+func (e *Edge) Bind(task engine.Task) {
+	e.task = task
+}
+
+func (e *Edge) IsClosed() bool {
+	return e.closed.Load()
+}
+
+func (e *Edge) Close() {
+	e.closed.Store(true)
+	e.cancel()
+}
+
+// 接收调度下发的消息，异步应答
+func (e *Edge) Write(msg []byte) error {
+	data := schedule.SendAction[json.RawMessage]{}
+	if err := json.Unmarshal(msg, &data); err != nil {
+		logger.Errorf(e.ctx, "mock edge unmarshal msg err: %+v", err)
+		return err
+	}
+
+	switch data.Action {
+	case schedule.QueryActionStatus:
+		key := engine.ActionKey{}
+		if err := json.Unmarshal(data.Data, &key); err != nil {
+			return err
+		}
+		key.Type = engine.QueryActionStatus
+		e.goReply(func() {
+			e.task.SetDeviceActionStatus(e.ctx, key, true, 0)
+		})
+	case schedule.JobStart:
+		action := &engine.SendActionData{}
+		if err := json.Unmarshal(data.Data, action); err != nil {
+			return err
+		}
+		e.goReply(func() {
+			e.runAction(action)
+		})
+	case schedule.QueryJobStatus:
+		key := engine.ActionKey{}
+		if err := json.Unmarshal(data.Data, &key); err != nil {
+			return err
+		}
+		e.goReply(func() {
+			if res, ok := e.getResult(key.JobID); ok {
+				e.task.OnJobUpdate(e.ctx, res)
+			}
+		})
+	case schedule.CancelTask:
+		e.cancel()
+	default:
+		logger.Warnf(e.ctx, "mock edge unknown action: %s", data.Action)
+	}
+
+	return nil
+}
+
+func (e *Edge) goReply(f func()) {
+	utils.SafelyGo(f, func(err error) {
+		logger.Errorf(e.ctx, "mock edge reply SafelyGo err: %+v", err)
+	})
+}
+
+// 模拟动作执行，耗时结束后回调结果
+func (e *Edge) runAction(action *engine.SendActionData) {
+	select {
+	case <-e.ctx.Done():
+		return
+	case <-time.After(e.delay()):
+	}
+
+	returnInfo := model.ReturnInfo{
+		Suc:         true,
+		ReturnValue: e.sampleResult(action.NodeID),
+	}
+	if _, ok := e.failNodes[action.NodeID]; ok {
+		returnInfo.Suc = false
+		returnInfo.Error = "simulated failure"
+	} else if e.conf.FailureRate > 0 && rand.Float64() < e.conf.FailureRate {
+		returnInfo.Suc = false
+		returnInfo.Error = "simulated random failure"
+	}
+
+	status := model.WorkflowJobSuccess
+	if !returnInfo.Suc {
+		status = model.WorkflowJobFailed
+	}
+
+	res := &engine.JobData{
+		JobID:      action.JobID,
+		TaskID:     action.TaskID,
+		DeviceID:   action.DeviceID,
+		ActionName: action.Action,
+		Status:     string(status),
+		ReturnInfo: datatypes.NewJSONType(returnInfo),
+	}
+
+	e.lock.Lock()
+	e.results[action.JobID] = res
+	e.lock.Unlock()
+
+	e.task.OnJobUpdate(e.ctx, res)
+}
+
+func (e *Edge) getResult(jobUUID uuid.UUID) (*engine.JobData, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	res, ok := e.results[jobUUID]
+	return res, ok
+}
+
+func (e *Edge) delay() time.Duration {
+	delay := time.Duration(e.conf.DelayMs) * time.Millisecond
+	if e.conf.JitterMs > 0 {
+		delay += time.Duration(rand.IntN(e.conf.JitterMs)) * time.Millisecond
+	}
+
+	return delay
+}
+
+// 根据节点对应动作模板的 Result schema 生成返回值
+func (e *Edge) sampleResult(nodeUUID uuid.UUID) any {
+	node := &model.WorkflowNode{}
+	if err := e.workflowStore.GetData(e.ctx, node, map[string]any{
+		"uuid": nodeUUID,
+	}, "id", "workflow_node_id"); err != nil {
+		logger.Warnf(e.ctx, "mock edge can not found node uuid: %s, err: %+v", nodeUUID, err)
+		return map[string]any{}
+	}
+
+	template := &model.WorkflowNodeTemplate{}
+	if err := e.workflowStore.GetData(e.ctx, template, map[string]any{
+		"id": node.WorkflowNodeID,
+	}, "id", "result"); err != nil {
+		logger.Warnf(e.ctx, "mock edge can not found node template id: %d, err: %+v", node.WorkflowNodeID, err)
+		return map[string]any{}
+	}
+
+	var schema any
+	if len(template.Result) == 0 || json.Unmarshal(template.Result, &schema) != nil {
+		return map[string]any{}
+	}
+
+	return sampleValue(schema)
+}
+
+// 按 json schema 生成示例值，优先使用 default、example、enum
+func sampleValue(schema any) any {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		return schema
+	}
+
+	if v, ok := s["default"]; ok {
+		return v
+	}
+	if v, ok := s["example"]; ok {
+		return v
+	}
+	if enum, ok := s["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+
+	properties, hasProperties := s["properties"].(map[string]any)
+	typ, _ := s["type"].(string)
+	switch {
+	case typ == "object" || hasProperties:
+		value := make(map[string]any, len(properties))
+		for key, property := range properties {
+			value[key] = sampleValue(property)
+		}
+		return value
+	case typ == "array":
+		if items, ok := s["items"]; ok {
+			return []any{sampleValue(items)}
+		}
+		return []any{}
+	case typ == "string":
+		return ""
+	case typ == "number", typ == "integer":
+		return 0
+	case typ == "boolean":
+		return false
+	case typ == "null":
+		return nil
+	default:
+		// 非 schema 结构，原样作为返回值
+		return s
+	}
+}
@@ -19,8 +19,9 @@ type LabInfo struct {
 type ApiAction string // api 服务和 schedule 交互消息, 通过 redis 发送
 
 const (
-	StartWorkflow ApiAction = "start_job"      // 启动工作流
-	StartNotebook ApiAction = "start_notebook" // 启动实验记录本
+	StartWorkflow  ApiAction = "start_job"      // 启动工作流
+	ResumeWorkflow ApiAction = "resume_job"     // 恢复中断的工作流
+	StartNotebook  ApiAction = "start_notebook" // 启动实验记录本
 )
 
 type ApiMsg struct {
@@ -37,7 +38,10 @@ type ApiControlAction string // api  服务和  schedule  交互，控制类消
 const (
 	StartAction    ApiControlAction = "start_action"    // 启动单个action，属于快速队列消息
 	StopJob        ApiControlAction = "stop_job"        // 停止任务
+	PauseJob       ApiControlAction = "pause_job"       // 暂停任务
+	ContinueJob    ApiControlAction = "continue_job"    // 继续运行暂停的任务
 	StatusJob      ApiControlAction = "status_job"      // 任务状态
+	ManualJob      ApiControlAction = "manual_job"      // 人工节点确认或拒绝
 	AddMaterial    ApiControlAction = "add_material"    // 增加物料
 	UpdateMaterial ApiControlAction = "update_material" // 更新物料
 	RemoveMaterial ApiControlAction = "remove_material" // 移除物料
@@ -57,6 +61,16 @@ type StopJobReq struct {
 	UserID string    `json:"user_id"`
 }
 
+type StatusJobReq struct {
+	UUID        uuid.UUID `json:"uuid"`
+	RequestUUID uuid.UUID `json:"request_uuid"` // 查询请求 id，回复时原样带回
+}
+
+type ManualJobReq struct {
+	UUID     uuid.UUID              `json:"uuid"`
+	Decision *engine.ManualDecision `json:"decision"`
+}
+
 type EdgeAction string // edge 交互消息, 通过 websocket 交互
 
 const (
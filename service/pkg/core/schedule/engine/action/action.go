@@ -6,13 +6,15 @@ import (
 	"sync"
 	"time"
 
-	"github.com/olahol/melody"
 	r "github.com/redis/go-redis/v9"
+	"github.com/scienceol/studio/service/internal/config"
 	"github.com/scienceol/studio/service/pkg/common/code"
 	"github.com/scienceol/studio/service/pkg/common/uuid"
 	"github.com/scienceol/studio/service/pkg/core/notify"
 	"github.com/scienceol/studio/service/pkg/core/schedule"
 	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/core/schedule/lock"
+	"github.com/scienceol/studio/service/pkg/core/schedule/lock/device"
 	"github.com/scienceol/studio/service/pkg/middleware/logger"
 	"github.com/scienceol/studio/service/pkg/middleware/redis"
 	"github.com/scienceol/studio/service/pkg/model"
@@ -26,7 +28,7 @@ type actionEngine struct {
 	job     *engine.WorkflowInfo
 	cancel  context.CancelFunc
 	ctx     context.Context
-	session *melody.Session
+	session engine.Session
 	data    *RunActionReq
 	ret     *RunActionResp
 
@@ -35,9 +37,10 @@ type actionEngine struct {
 
 	boardEvent notify.MsgCenter
 
-	actionStatus sync.Map
+	actionStatus *engine.ActionStatus
 	rClient      *r.Client
 	sanbox       repo.Sandbox
+	deviceLock   lock.DeviceLock
 }
 
 func NewActionTask(ctx context.Context, param *engine.TaskParam) engine.Task {
@@ -49,6 +52,9 @@ func NewActionTask(ctx context.Context, param *engine.TaskParam) engine.Task {
 		rClient:    redis.GetClient(),
 		sanbox:     param.Sandbox,
 		boardEvent: param.BoardEvent,
+		deviceLock: device.New(),
+
+		actionStatus: engine.NewActionStatus(),
 	}
 	d.stepFuncs = append(d.stepFuncs,
 		d.loadData, // 加载运行数据
@@ -106,7 +112,45 @@ func (d *actionEngine) Stop(_ context.Context) error {
 	return nil
 }
 
+func (d *actionEngine) Release() {}
+
+// 单个动作无需暂停
+func (d *actionEngine) Pause(_ context.Context) error {
+	return nil
+}
+
+func (d *actionEngine) Continue(_ context.Context) error {
+	return nil
+}
+
 func (d *actionEngine) runNode(ctx context.Context) error {
+	// 获取设备租约，设备被工作流或其他动作占用时排队
+	leaseCtx, release, err := d.deviceLock.Acquire(ctx, &lock.Holder{
+		LabUUID:    d.data.LabUUID,
+		DeviceName: d.data.DeviceID,
+		HolderID:   d.job.TaskUUID,
+		TaskUUID:   d.job.TaskUUID,
+		ActionName: d.data.Action,
+		Source:     lock.SourceAction,
+		UserID:     d.job.UserID,
+	}, func(current *lock.Holder) {
+		logger.Infof(ctx, "action wait device: %s, holder task uuid: %s", d.data.DeviceID, current.TaskUUID)
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// 租约丢失时设备可能已被其他任务使用，动作按失败处理
+	err = d.execAction(leaseCtx)
+	if err != nil && ctx.Err() == nil && leaseCtx.Err() != nil {
+		return context.Cause(leaseCtx)
+	}
+
+	return err
+}
+
+func (d *actionEngine) execAction(ctx context.Context) error {
 	// 查询 action 是否可以执行
 	err := d.queryAction(ctx)
 	if err != nil {
@@ -126,7 +170,8 @@ func (d *actionEngine) runNode(ctx context.Context) error {
 		ActionName: d.data.Action,
 	}
 
-	d.InitDeviceActionStatus(ctx, key, time.Now().Add(20*time.Second), false)
+	actionTimeout := time.Duration(config.Global().Job.ActionTimeoutSecond) * time.Second
+	d.InitDeviceActionStatus(ctx, key, time.Now().Add(actionTimeout), false)
 	err = d.callbackAction(ctx, key)
 
 	return err
@@ -142,33 +187,13 @@ func (d *actionEngine) queryAction(ctx context.Context) error {
 		}, ""),
 		ActionName: d.data.Action,
 	}
-	d.InitDeviceActionStatus(ctx, key, time.Now().Add(time.Second*20), false)
+	queryTimeout := time.Duration(config.Global().Job.QueryTimeoutSecond) * time.Second
+	d.InitDeviceActionStatus(ctx, key, time.Now().Add(queryTimeout), false)
 	if err := d.sendQueryAction(ctx); err != nil {
 		return err
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return code.JobCanceled
-		default:
-		}
-
-		time.Sleep(time.Millisecond * 500)
-		value, exist := d.GetDeviceActionStatus(ctx, key)
-		if !exist {
-			return code.QueryJobStatusKeyNotExistErr
-		}
-
-		if value.Free {
-			d.DelStatus(ctx, key)
-			return nil
-		}
-
-		if value.Timestamp.Unix() < time.Now().Unix() {
-			return code.JobTimeoutErr
-		}
-	}
+	return d.actionStatus.Wait(ctx, key, code.QueryJobStatusKeyNotExistErr)
 }
 
 func (d *actionEngine) sendQueryAction(_ context.Context) error {
@@ -220,27 +245,8 @@ func (d *actionEngine) sendAction(_ context.Context) error {
 }
 
 func (d *actionEngine) callbackAction(ctx context.Context, key engine.ActionKey) error {
-	for {
-		select {
-		case <-ctx.Done():
-			return code.JobCanceled
-		default:
-		}
-
-		time.Sleep(time.Millisecond * 500)
-		value, exist := d.GetDeviceActionStatus(ctx, key)
-		if !exist {
-			return code.CallbackJobStatusKeyNotExistErr
-		}
-
-		if value.Free {
-			d.DelStatus(ctx, key)
-			break
-		}
-
-		if value.Timestamp.Unix() < time.Now().Unix() {
-			return code.JobTimeoutErr
-		}
+	if err := d.actionStatus.Wait(ctx, key, code.CallbackJobStatusKeyNotExistErr); err != nil {
+		return err
 	}
 
 	// 查询任务状态是否回调成功
@@ -259,10 +265,31 @@ func (d *actionEngine) callbackAction(ctx context.Context, key engine.ActionKey)
 	}
 }
 
-func (d *actionEngine) GetStatus(_ context.Context) error {
-	return nil
+// 手动动作没有节点调度，只返回等待 edge 回复的动作
+func (d *actionEngine) GetStatus(_ context.Context) (*engine.TaskStatus, error) {
+	status := &engine.TaskStatus{
+		Running:      true,
+		ReadyNodes:   make([]*engine.NodeStatus, 0),
+		RunningNodes: make([]*engine.NodeStatus, 0),
+		WaitingNodes: make([]*engine.NodeStatus, 0),
+		Actions:      d.actionStatus.Snapshot(),
+		Timestamp:    time.Now(),
+	}
+	if d.job != nil {
+		status.TaskUUID = d.job.TaskUUID
+	}
+
+	return status, nil
 }
 
+// 手动动作没有人工节点
+func (d *actionEngine) OnManualDecision(_ context.Context, _ *engine.ManualDecision) error {
+	return code.ManualApprovalNotFoundErr
+}
+
+// 手动动作没有等待物料数据的节点
+func (d *actionEngine) OnMaterialData(_ context.Context, _ *engine.MaterialData) {}
+
 func (d *actionEngine) OnJobUpdate(ctx context.Context, data *engine.JobData) error {
 	// 广播状态更新（包括 running 状态）
 	d.boardMsg(ctx, data)
@@ -271,6 +298,10 @@ func (d *actionEngine) OnJobUpdate(ctx context.Context, data *engine.JobData) er
 		return nil
 	}
 
+	d.ret = &RunActionResp{
+		JobData: data,
+	}
+
 	d.SetDeviceActionStatus(ctx, engine.ActionKey{
 		Type:       engine.JobCallbackStatus,
 		TaskID:     data.TaskID,
@@ -279,10 +310,6 @@ func (d *actionEngine) OnJobUpdate(ctx context.Context, data *engine.JobData) er
 		ActionName: data.ActionName,
 	}, true, 0)
 
-	d.ret = &RunActionResp{
-		JobData: data,
-	}
-
 	return nil
 }
 
@@ -310,35 +337,24 @@ func (d *actionEngine) setActionRet(ctx context.Context) {
 }
 
 func (d *actionEngine) GetDeviceActionStatus(ctx context.Context, key engine.ActionKey) (engine.ActionValue, bool) {
-	valueI, ok := d.actionStatus.Load(key)
-	if !ok {
-		return engine.ActionValue{}, false
-	}
-	return valueI.(engine.ActionValue), true
+	return d.actionStatus.Get(key)
 }
 
 func (d *actionEngine) SetDeviceActionStatus(ctx context.Context, key engine.ActionKey, free bool, needMore time.Duration) {
-	valueI, ok := d.actionStatus.Load(key)
-	if ok {
-		value := valueI.(engine.ActionValue)
-		value.Free = free
-		value.Timestamp = value.Timestamp.Add(needMore)
-		logger.Warnf(ctx, "SetDeviceActionStatus key: %+v, value: %+v, more: %d", key, value, needMore)
-		d.actionStatus.Store(key, value)
-	} else {
+	value, ok := d.actionStatus.Set(key, free, needMore)
+	if !ok {
 		logger.Warnf(ctx, "SetDeviceActionStatus not found key: %+v", key)
+		return
 	}
+	logger.Infof(ctx, "SetDeviceActionStatus key: %+v, value: %+v, more: %d", key, value, needMore)
 }
 
 func (d *actionEngine) InitDeviceActionStatus(ctx context.Context, key engine.ActionKey, start time.Time, free bool) {
-	d.actionStatus.Store(key, engine.ActionValue{
-		Timestamp: start,
-		Free:      free,
-	})
+	d.actionStatus.Init(key, start, free)
 }
 
 func (d *actionEngine) DelStatus(ctx context.Context, key engine.ActionKey) {
-	d.actionStatus.Delete(key)
+	d.actionStatus.Del(key)
 }
 
 func (d *actionEngine) boardMsg(ctx context.Context, jobData *engine.JobData) {
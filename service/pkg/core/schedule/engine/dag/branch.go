@@ -0,0 +1,225 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/tidwall/gjson"
+	"gorm.io/datatypes"
+)
+
+// 条件分支节点：按顺序匹配 cases，命中的 handle 对应的出边被激活，其余出边下游节点跳过
+//
+// 节点 param 示例，上游返回值通过边注入到 param 后参与计算:
+// {"input": {...}, "cases": [{"path": "input.ok", "operator": "eq", "value": true, "handle_key": "true"}], "default": "false"}
+
+const branchHandleKey = "handle_key"
+
+type branchCase struct {
+	Path      string `json:"path"`       // gjson 路径
+	Operator  string `json:"operator"`   // eq、ne、gt、gte、lt、lte、in、exists、not_exists
+	Value     any    `json:"value"`      // 比较值
+	HandleKey string `json:"handle_key"` // 命中后激活的输出 handle
+}
+
+type branchParam struct {
+	Cases   []*branchCase `json:"cases"`
+	Default string        `json:"default"` // 均未命中时激活的输出 handle，为空则全部跳过
+}
+
+func checkBranchNode(node *model.WorkflowNode) error {
+	param := &branchParam{}
+	if err := json.Unmarshal(node.Param, param); err != nil {
+		return code.WorkflowNodeBranchConfigErr.WithErr(err)
+	}
+
+	if len(param.Cases) == 0 && param.Default == "" {
+		return code.WorkflowNodeBranchConfigErr.WithMsgf("node id: %d, empty cases", node.ID)
+	}
+
+	for _, c := range param.Cases {
+		if c.HandleKey == "" {
+			return code.WorkflowNodeBranchConfigErr.WithMsgf("node id: %d, empty handle key", node.ID)
+		}
+
+		if !isBranchOperator(c.Operator) {
+			return code.WorkflowNodeBranchConfigErr.WithMsgf("node id: %d, unknown operator: %s", node.ID, c.Operator)
+		}
+	}
+
+	return nil
+}
+
+func isBranchOperator(op string) bool {
+	switch op {
+	case "eq", "ne", "gt", "gte", "lt", "lte", "in", "exists", "not_exists":
+		return true
+	default:
+		return false
+	}
+}
+
+// 计算分支节点命中的 handle，结果写入 job 的返回值
+func (d *dagEngine) execBranch(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) error {
+	param := &branchParam{}
+	if err := json.Unmarshal(node.Param, param); err != nil {
+		return code.WorkflowNodeBranchConfigErr.WithErr(err)
+	}
+
+	handleKey := param.Default
+	for _, c := range param.Cases {
+		if matchBranchCase(node.Param, c) {
+			handleKey = c.HandleKey
+			break
+		}
+	}
+
+	d.setJobStatus(job, model.WorkflowJobSuccess)
+	job.ReturnInfo = datatypes.NewJSONType(model.ReturnInfo{
+		Suc: true,
+		ReturnValue: map[string]any{
+			branchHandleKey: handleKey,
+		},
+	})
+	job.UpdatedAt = time.Now()
+
+	return d.workflowStore.UpdateData(ctx, job, map[string]any{
+		"id": job.ID,
+	}, "status", "return_info", "updated_at")
+}
+
+func matchBranchCase(param datatypes.JSON, c *branchCase) bool {
+	res := gjson.GetBytes(param, c.Path)
+	switch c.Operator {
+	case "exists":
+		return res.Exists()
+	case "not_exists":
+		return !res.Exists()
+	}
+
+	if !res.Exists() {
+		return false
+	}
+
+	valueB, err := json.Marshal(c.Value)
+	if err != nil {
+		return false
+	}
+	expect := gjson.ParseBytes(valueB)
+
+	switch c.Operator {
+	case "eq":
+		return equalResult(res, expect)
+	case "ne":
+		return !equalResult(res, expect)
+	case "gt":
+		return res.Float() > expect.Float()
+	case "gte":
+		return res.Float() >= expect.Float()
+	case "lt":
+		return res.Float() < expect.Float()
+	case "lte":
+		return res.Float() <= expect.Float()
+	case "in":
+		for _, item := range expect.Array() {
+			if equalResult(res, item) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func equalResult(a, b gjson.Result) bool {
+	if a.Type != b.Type {
+		return false
+	}
+
+	switch a.Type {
+	case gjson.Number:
+		return a.Float() == b.Float()
+	case gjson.String:
+		return a.Str == b.Str
+	case gjson.JSON:
+		var av, bv any
+		if json.Unmarshal([]byte(a.Raw), &av) != nil || json.Unmarshal([]byte(b.Raw), &bv) != nil {
+			return false
+		}
+		ab, _ := json.Marshal(av)
+		bb, _ := json.Marshal(bv)
+		return string(ab) == string(bb)
+	default:
+		// True、False、Null 类型相同即相等
+		return true
+	}
+}
+
+// 分支节点选中的输出 handle
+func branchSelected(job *model.WorkflowNodeJob) string {
+	valueB, err := json.Marshal(job.ReturnInfo.Data().ReturnValue)
+	if err != nil {
+		return ""
+	}
+
+	return gjson.GetBytes(valueB, branchHandleKey).String()
+}
+
+// 边是否处于激活状态：上游被跳过或分支未选中该 handle 时边失效
+func (d *dagEngine) isEdgeActive(pair *engine.HandlePair) bool {
+	if pair.SourceNode == nil {
+		return true
+	}
+
+	job, ok := d.getNodeJob(pair.SourceNode.ID)
+	if !ok {
+		return true
+	}
+
+	if job.Status == model.WorkflowJobSkipped {
+		return false
+	}
+
+	if pair.SourceNode.Type != model.WorkflowBranch {
+		return true
+	}
+
+	return pair.SourceHandle != nil && pair.SourceHandle.HandleKey == branchSelected(job)
+}
+
+// 所有入边均失效的节点跳过执行
+func (d *dagEngine) shouldSkip(node *model.WorkflowNode) bool {
+	pairs := d.nodeParentEdges[node.ID]
+	hasParent := false
+	for _, p := range pairs {
+		if p.SourceNode == nil {
+			continue
+		}
+
+		hasParent = true
+		if d.isEdgeActive(p) {
+			return false
+		}
+	}
+
+	return hasParent
+}
+
+func (d *dagEngine) skipNode(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) {
+	d.setJobStatus(job, model.WorkflowJobSkipped)
+	d.finishJob(ctx, job)
+	d.boardMsg(ctx, &engine.BoardMsg{
+		TaskStatus: "running",
+		JobStatus:  string(model.WorkflowJobSkipped),
+		Header:     node.ActionName,
+		NodeUUID:   node.UUID,
+		Type:       "info",
+		Msg:        "skipped",
+		Timestamp:  time.Now(),
+	})
+}
@@ -9,14 +9,17 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/olahol/melody"
 	"github.com/panjf2000/ants/v2"
+	"github.com/scienceol/studio/service/internal/config"
 	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/expr"
 	"github.com/scienceol/studio/service/pkg/common/uuid"
 	"github.com/scienceol/studio/service/pkg/core/notify"
 	"github.com/scienceol/studio/service/pkg/core/notify/events"
 	"github.com/scienceol/studio/service/pkg/core/schedule"
 	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/core/schedule/lock"
+	"github.com/scienceol/studio/service/pkg/core/schedule/lock/device"
 	"github.com/scienceol/studio/service/pkg/middleware/logger"
 	"github.com/scienceol/studio/service/pkg/model"
 	"github.com/scienceol/studio/service/pkg/repo"
@@ -34,7 +37,7 @@ type dagEngine struct {
 	job     *engine.WorkflowInfo
 	cancel  context.CancelFunc
 	ctx     context.Context
-	session *melody.Session
+	session engine.Session
 
 	envStore      repo.LaboratoryRepo
 	workflowStore repo.WorkflowRepo
@@ -43,12 +46,18 @@ type dagEngine struct {
 	edges   []*model.WorkflowEdge           // 所有边
 	handles []*model.WorkflowHandleTemplate // 所有 handles
 
+	jobLock         sync.RWMutex                         // 保护 jobMap 和 nodeMap
 	jobMap          map[uuid.UUID]*model.WorkflowNodeJob // 所有的 job map
 	nodeMap         map[int64]*model.WorkflowNodeJob     // 所有的 node 对应的运行结果
 	nodeParentEdges map[int64][]*engine.HandlePair       // 节点对应的所有 parent edge
 
+	depLock      sync.RWMutex                                             // 保护 dependencies 的写入和状态查询
 	dependencies map[*model.WorkflowNode]map[*model.WorkflowNode]struct{} // dag 图依赖关系
 
+	taskTimeout  time.Duration           // 任务整体超时时间，0 不限制
+	runTime      time.Duration           // 任务已运行时间，不含暂停
+	nodeTimeouts map[int64]time.Duration // 节点 id 对应的超时时间
+
 	pools     *ants.Pool
 	wg        sync.WaitGroup
 	stepFuncs []stepFunc
@@ -56,11 +65,38 @@ type dagEngine struct {
 	boardEvent notify.MsgCenter
 	sandbox    repo.Sandbox
 
-	actionStatus sync.Map
+	actionStatus *engine.ActionStatus
+	stopped      *atomic.Bool    // 是否由用户主动停止
+	deviceLock   lock.DeviceLock // 实验室设备锁
+	pause        *pauseGate      // 暂停控制
+	manual       *manualGate     // 等待人工处理的节点
+	waits        *waitGate       // 等待物料数据的节点
+	resuming     sync.Map        // 恢复时查询状态的在途 job uuid -> *atomic.Bool，edge 已回复时置为 true，记录在根任务
+
+	mapChildren map[int64][]*model.WorkflowNode // map 节点 id 对应的子图节点
+	mapEdges    map[int64][]*model.WorkflowEdge // map 节点 id 对应的子图边
+	mapNode     *model.WorkflowNode             // 子图所属的 map 节点，根图为空
+	parentJobID int64                           // 子图所属的 map job id
+	iteration   int                             // 子图迭代序号
+
+	root        *dagEngine          // 根任务引擎，根任务为空
+	parent      *dagEngine          // 子工作流的父任务引擎
+	subflowNode *model.WorkflowNode // 子工作流对应的父任务节点
+
+	sourceTaskID  int64 // 从失败处重跑时的源任务 id
+	restartNodeID int64 // 指定的重跑起点节点 id
+	simulated     bool  // 仿真任务，不获取设备锁
+
+	params datatypes.JSONMap // 任务运行参数值
+
+	failurePolicy model.FailurePolicy // 工作流的节点失败处理策略
+	partial       *atomic.Bool        // 是否有节点失败后按策略继续运行
+
+	diagnostics *diagnostics // 运行前校验时收集问题，运行时为空
 }
 
 func NewDagTask(ctx context.Context, param *engine.TaskParam) engine.Task {
-	pools, _ := ants.NewPool(5,
+	pools, _ := ants.NewPool(config.Global().Job.NodePoolSize,
 		ants.WithExpiryDuration(10*time.Second))
 
 	d := &dagEngine{
@@ -76,12 +112,24 @@ func NewDagTask(ctx context.Context, param *engine.TaskParam) engine.Task {
 		jobMap:          make(map[uuid.UUID]*model.WorkflowNodeJob),
 		nodeMap:         make(map[int64]*model.WorkflowNodeJob),
 		nodeParentEdges: make(map[int64][]*engine.HandlePair),
+		nodeTimeouts:    make(map[int64]time.Duration),
 		sandbox:         param.Sandbox,
+		actionStatus:    engine.NewActionStatus(),
+		stopped:         &atomic.Bool{},
+		deviceLock:      device.New(),
+		pause:           &pauseGate{},
+		manual:          &manualGate{},
+		waits:           &waitGate{},
+		partial:         &atomic.Bool{},
+		mapChildren:     make(map[int64][]*model.WorkflowNode),
+		mapEdges:        make(map[int64][]*model.WorkflowEdge),
 	}
 	d.stepFuncs = append(d.stepFuncs,
 		d.checkTaskStatus, // 检查任务状态
 		d.loadData,        // 加载运行数据
 		d.buildTask,       // 构建任务
+		d.copySourceJobs,  // 从失败处重跑时复用源任务的结果
+		d.restoreJobs,     // 恢复中断任务的运行状态
 		d.runAllNodes,     // 运行任务
 	)
 
@@ -100,61 +148,90 @@ func (d *dagEngine) checkTaskStatus(ctx context.Context) error {
 	task := &model.WorkflowTask{}
 	if err := d.workflowStore.GetData(ctx, task, map[string]any{
 		"uuid": d.job.TaskUUID,
-	}, "id", "uuid", "status"); err != nil {
+	}, "id", "uuid", "lab_id", "status", "source_task_id", "restart_node_id", "simulated", "params", "run_second"); err != nil {
 		logger.Errorf(ctx, "can not found workflow task uuid: %s, err: %+v", d.job.TaskUUID, err)
 		return code.CanNotGetWorkflowTaskErr
 	}
 
-	if task.Status != model.WorkflowTaskStatusPending {
+	switch {
+	case task.Status == model.WorkflowTaskStatusPending:
+	case task.Status == model.WorkflowTaskStatusRunnig && d.job.Action == engine.ResumeJob:
+	case task.Status == model.WorkflowTaskStatusPaused && d.job.Action == engine.ResumeJob:
+		// 恢复暂停中的任务，保持暂停状态
+		d.pause.pause()
+	default:
 		return code.WorkflowTaskStatusNotPendingErr
 	}
 
+	if d.job.LabData == nil {
+		lab, err := d.envStore.GetLabByID(ctx, task.LabID)
+		if err != nil {
+			return err
+		}
+		d.job.LabData = lab
+	}
+
 	d.job.TaskID = task.ID
+	d.sourceTaskID = task.SourceTaskID
+	d.restartNodeID = task.RestartNodeID
+	d.simulated = task.Simulated
+	d.params = task.Params
+	d.runTime = time.Duration(task.RunSecond) * time.Second
+	if task.Status == model.WorkflowTaskStatusPaused {
+		return nil
+	}
+
+	// 按读取时的状态条件更新，同一任务被重复投递时只有一个实例能启动
+	readStatus := task.Status
+	task.Status = model.WorkflowTaskStatusRunnig
+	task.UpdatedAt = time.Now()
+	ok, err := d.workflowStore.ClaimTask(ctx, task, readStatus)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return code.WorkflowTaskStatusNotPendingErr.WithMsgf("task uuid: %s already claimed", d.job.TaskUUID)
+	}
+
 	return nil
 }
 
 func (d *dagEngine) loadData(ctx context.Context) error {
-	// 获取工作流
-	wk, err := d.workflowStore.GetWorkflowByUUID(ctx, d.job.WorkflowUUID)
+	// 已有快照时按快照运行，工作流之后的修改不影响该任务
+	snapshot, err := d.loadSnapshot(ctx, d.job.TaskID)
 	if err != nil {
 		return err
 	}
 
-	// 加载所有工作流节点数据
-	allNodes, err := d.workflowStore.GetWorkflowNodes(ctx, map[string]any{
-		"workflow_id": wk.ID,
-		"type": []model.WorkflowNodeType{
-			model.WorkflowNodeILab,
-			model.WorkflowPyScript,
-		},
-	})
-	if err != nil {
-		return err
+	if snapshot == nil {
+		if d.sourceTaskID > 0 {
+			snapshot, err = d.sourceSnapshot(ctx)
+		} else {
+			snapshot, err = d.buildSnapshot(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := d.saveSnapshot(ctx, snapshot); err != nil {
+			return err
+		}
 	}
 
+	allNodes := snapshot.Nodes
+
 	// 过滤检查可执行节点
 	nodes, err := utils.FilterSliceWithErr(allNodes, func(node *model.WorkflowNode) ([]*model.WorkflowNode, bool, error) {
 		if node.Type == model.WorkflowNodeGroup || node.Disabled {
 			return nil, false, nil
 		}
 
-		if node.Type == model.WorkflowNodeILab {
-			if node.DeviceName == nil || *node.DeviceName == "" {
-				return nil, false, code.WorkflowNodeNoDeviceName
-			}
-
-			if node.ActionName == "" {
-				return nil, false, code.WorkflowNodeNoActionName
-			}
-
-			if node.ActionType == "" {
-				return nil, false, code.WorkflowNodeNoActionType
-			}
-		} else {
-			// 计算类型
-			if node.Script == nil || *node.Script == "" {
-				return nil, false, code.WorkflowNodeScriptEmtpyErr
+		if err := checkNode(node); err != nil {
+			// 校验时记录问题，继续检查其他节点
+			if d.diagnostics == nil {
+				return nil, false, err
 			}
+			d.diagnostics.add(node, nil, err)
 		}
 
 		return []*model.WorkflowNode{node}, true, nil
@@ -163,33 +240,53 @@ func (d *dagEngine) loadData(ctx context.Context) error {
 		return err
 	}
 
-	// 节点UUID查询边
-	nodeUUIDs := utils.FilterSlice(nodes, func(node *model.WorkflowNode) (uuid.UUID, bool) {
-		return node.UUID, true
-	})
+	d.nodes, d.edges = d.splitMapScopes(allNodes, nodes, snapshot.Edges)
+	d.handles = snapshot.Handles
+	d.taskTimeout = time.Duration(snapshot.TimeoutSecond) * time.Second
+	d.failurePolicy = snapshot.FailurePolicy
 
-	edges, err := d.workflowStore.GetWorkflowEdges(ctx, nodeUUIDs)
-	if err != nil {
-		return err
-	}
+	return d.loadNodeTimeouts(ctx, nodes)
+}
 
-	edgeHandleUUIDs := make([]uuid.UUID, 0, 2*len(edges))
-	utils.Range(edges, func(_ int, e *model.WorkflowEdge) bool {
-		edgeHandleUUIDs = utils.AppendUniqSlice(edgeHandleUUIDs, e.SourceHandleUUID)
-		edgeHandleUUIDs = utils.AppendUniqSlice(edgeHandleUUIDs, e.TargetHandleUUID)
-		return true
-	})
+// 检查节点配置是否可以运行
+func checkNode(node *model.WorkflowNode) error {
+	switch node.Type {
+	case model.WorkflowNodeILab:
+		if node.DeviceName == nil || *node.DeviceName == "" {
+			return code.WorkflowNodeNoDeviceName
+		}
 
-	handleTpls := make([]*model.WorkflowHandleTemplate, 0, len(edgeHandleUUIDs))
-	if err := d.workflowStore.FindDatas(ctx, &handleTpls, map[string]any{
-		"uuid": edgeHandleUUIDs,
-	}); err != nil {
+		if node.ActionName == "" {
+			return code.WorkflowNodeNoActionName
+		}
+
+		if node.ActionType == "" {
+			return code.WorkflowNodeNoActionType
+		}
+	case model.WorkflowBranch:
+		return checkBranchNode(node)
+	case model.WorkflowMap:
+		_, err := parseMapParam(node)
+		return err
+	case model.WorkflowSubflow:
+		_, err := parseSubflowParam(node)
+		return err
+	case model.WorkflowManual:
+		_, err := parseManualParam(node)
+		return err
+	case model.WorkflowTimer:
+		_, err := parseTimerParam(node)
 		return err
+	case model.WorkflowWait:
+		_, err := parseWaitParam(node)
+		return err
+	default:
+		// 计算类型
+		if node.Script == nil || *node.Script == "" {
+			return code.WorkflowNodeScriptEmtpyErr
+		}
 	}
 
-	d.nodes = nodes
-	d.edges = edges
-	d.handles = handleTpls
 	return nil
 }
 
@@ -220,7 +317,9 @@ func (d *dagEngine) buildTask(ctx context.Context) error {
 	for _, node := range d.nodes {
 		parentNodeMap := make(map[*model.WorkflowNode]struct{})
 		d.findAllParents(nodeMap, nodeParentUUIDMap, node, parentNodeMap)
+		d.depLock.Lock()
 		d.dependencies[node] = parentNodeMap
+		d.depLock.Unlock()
 
 		// 找出该节点的所有前向边
 		leftEdges := utils.FilterSlice(d.edges, func(e *model.WorkflowEdge) (*model.WorkflowEdge, bool) {
@@ -234,19 +333,37 @@ func (d *dagEngine) buildTask(ctx context.Context) error {
 		if true {
 			var err error
 			d.nodeParentEdges[node.ID], err = utils.FilterSliceErr(leftEdges, func(e *model.WorkflowEdge) (*engine.HandlePair, bool, error) {
+				// 校验时记录问题，跳过这条边继续检查
+				edgeErr := func(err error) (*engine.HandlePair, bool, error) {
+					if d.diagnostics == nil {
+						return nil, false, err
+					}
+					d.diagnostics.add(node, e, err)
+					return nil, false, nil
+				}
+
 				sourceHandle, ok := handleMap[e.SourceHandleUUID]
 				if !ok {
-					return nil, false, code.CanNotFoundWorkflowHandleErr.WithMsg(fmt.Sprintf("node id: %d, source uuid: %s", node.ID, e.SourceHandleUUID))
+					return edgeErr(code.CanNotFoundWorkflowHandleErr.WithMsg(fmt.Sprintf("node id: %d, source uuid: %s", node.ID, e.SourceHandleUUID)))
 				}
 
 				targetHandle, ok := handleMap[e.TargetHandleUUID]
 				if !ok {
-					return nil, false, code.CanNotFoundWorkflowHandleErr.WithMsg(fmt.Sprintf("node id: %d, target uuid: %s", node.ID, e.TargetHandleUUID))
+					return edgeErr(code.CanNotFoundWorkflowHandleErr.WithMsg(fmt.Sprintf("node id: %d, target uuid: %s", node.ID, e.TargetHandleUUID)))
 				}
 
 				pair := &engine.HandlePair{
 					SourceHandle: sourceHandle,
 					TargetHandle: targetHandle,
+					Edge:         e,
+				}
+
+				if e.Expression != "" {
+					program, err := expr.Compile(e.Expression, engine.EdgeExpressionVars...)
+					if err != nil {
+						return edgeErr(code.EdgeExpressionErr.WithMsgf("edge: %s, expression: %s, err: %v", e.UUID, e.Expression, err))
+					}
+					pair.Expression = program
 				}
 
 				sourceNode, ok := nodeMap[e.SourceNodeUUID]
@@ -332,6 +449,8 @@ func (d *dagEngine) findAllParents(nodeMap map[uuid.UUID]*model.WorkflowNode,
 
 // 运行入口
 func (d *dagEngine) Run(ctx context.Context, job *engine.WorkflowInfo) error {
+	defer d.Release()
+
 	d.job = job
 	var err error
 	data := &engine.BoardMsg{
@@ -351,6 +470,18 @@ func (d *dagEngine) Run(ctx context.Context, job *engine.WorkflowInfo) error {
 		}
 	}
 
+	// 任务已被取消或已在其他地方运行，不覆盖任务状态
+	if errors.Is(err, code.WorkflowTaskStatusNotPendingErr) {
+		return err
+	}
+
+	// edge 断开导致中断，保留运行状态等待 edge 重连后恢复
+	if d.isInterrupted() {
+		logger.Warnf(ctx, "dag task interrupted, wait for recovery task uuid: %s", d.job.TaskUUID)
+		d.wg.Wait()
+		return code.JobInterruptedErr
+	}
+
 	taskStatus := model.WorkflowTaskStatusFailed
 	data.TaskStatus = "end"
 	data.Timestamp = time.Now()
@@ -368,6 +499,10 @@ func (d *dagEngine) Run(ctx context.Context, job *engine.WorkflowInfo) error {
 			data.Msg = "job failed"
 			data.Type = "error"
 		}
+	} else if d.partial.Load() {
+		taskStatus = model.WorkflowTaskStatusPartially
+		data.Msg = "finished, some nodes failed"
+		data.Type = "warning"
 	} else {
 		taskStatus = model.WorkflowTaskStatusSuccessed
 		data.Msg = "finished"
@@ -381,119 +516,298 @@ func (d *dagEngine) Run(ctx context.Context, job *engine.WorkflowInfo) error {
 }
 
 func (d *dagEngine) Stop(_ context.Context) error {
-	data := schedule.SendAction[*engine.CancelTask]{
-		Action: schedule.CancelTask,
-		Data: &engine.CancelTask{
-			TaskID: d.job.TaskUUID,
-		},
-	}
-	b, _ := json.Marshal(data)
-	d.session.Write(b)
+	d.stopped.Store(true)
+	d.sendCancelTask()
 
 	d.cancel()
 	d.wg.Wait()
+	d.Release()
 
+	return nil
+}
+
+func (d *dagEngine) Release() {
 	if d.pools != nil {
 		d.pools.Release()
 	}
+}
 
-	return nil
+// 节点运行结果
+type nodeResult struct {
+	node *model.WorkflowNode
+	err  error
 }
 
+// 就绪队列调度：节点的父节点全部完成后立即下发，不等待同层其他节点
 func (d *dagEngine) runAllNodes(ctx context.Context) error {
-	var hasError atomic.Bool
-	var firstError atomic.Value
-	closeCtx, cancel := context.WithCancel(ctx)
+	taskCtx, taskCancel := d.withTaskDeadline(ctx)
+	defer taskCancel()
+	closeCtx, cancel := context.WithCancel(taskCtx)
 	defer cancel()
 
+	resultCh := make(chan *nodeResult, len(d.dependencies))
+	running := 0
+	pausedNotified := false
+	var firstErr error
+
 	for {
-		if len(d.dependencies) == 0 {
-			return nil
+		// 暂停时不下发新节点，等待运行中的节点完成或继续运行
+		resumeCh := d.pause.wait()
+		if resumeCh == nil {
+			pausedNotified = false
+		} else if !pausedNotified {
+			pausedNotified = true
+			d.boardPausedNodes(closeCtx)
+		}
+
+		if firstErr == nil && closeCtx.Err() == nil && resumeCh == nil {
+			count, err := d.dispatchReadyNodes(closeCtx, resultCh)
+			running += count
+			if err != nil {
+				firstErr = err
+				cancel()
+			}
 		}
 
+		if running == 0 && (resumeCh == nil || firstErr != nil || closeCtx.Err() != nil) {
+			break
+		}
+
+		var res *nodeResult
 		select {
+		case res = <-resultCh:
+		case <-resumeCh:
+			continue
 		case <-closeCtx.Done():
-			return code.JobCanceled
-		default:
+			if running == 0 {
+				continue
+			}
+			res = <-resultCh
 		}
+		running--
+		if res.err != nil {
+			if !errors.Is(res.err, code.JobCanceled) {
+				logger.Errorf(closeCtx, "node run fail node id: %d, err: %+v", res.node.ID, res.err)
+			}
 
-		noDepNodes := make([]*model.WorkflowNode, 0, 10)
+			// 按失败策略继续运行时视为完成，下游节点在下发时判断是否跳过
+			if firstErr == nil && d.tolerateFailure(closeCtx, res.node, res.err) {
+				d.removeDependencies([]*model.WorkflowNode{res.node})
+				continue
+			}
+
+			if firstErr == nil {
+				firstErr = res.err
+				cancel()
+			}
+			continue
+		}
+
+		// 移除依赖关系
+		d.removeDependencies([]*model.WorkflowNode{res.node})
+	}
+
+	// 任务整体超时，子节点返回的取消错误转换为超时
+	if errors.Is(context.Cause(taskCtx), context.DeadlineExceeded) {
+		return d.taskDoneErr(taskCtx)
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if len(d.dependencies) > 0 {
+		return d.taskDoneErr(taskCtx)
+	}
+
+	return nil
+}
+
+// 下发所有依赖已满足的节点，返回下发数量
+func (d *dagEngine) dispatchReadyNodes(ctx context.Context, resultCh chan<- *nodeResult) (int, error) {
+	count := 0
+	for {
+		readyNodes := make([]*model.WorkflowNode, 0, 10)
 		nodeJobs := make([]*model.WorkflowNodeJob, 0, 10)
+		newJobs := make([]*model.WorkflowNodeJob, 0, 10)
 		for node, nodeDependences := range d.dependencies {
 			if len(nodeDependences) > 0 {
 				continue
 			}
 
-			noDepNodes = append(noDepNodes, node)
-			nodeJobs = append(nodeJobs, &model.WorkflowNodeJob{
-				LabID:          d.job.LabData.ID,
-				WorkflowTaskID: d.job.TaskID,
-				NodeID:         node.ID,
-				Status:         model.WorkflowJobPending,
-			})
+			// 恢复的任务复用已存在的 job
+			job, ok := d.getNodeJob(node.ID)
+			if !ok {
+				queuedAt := time.Now()
+				job = &model.WorkflowNodeJob{
+					LabID:          d.job.LabData.ID,
+					WorkflowTaskID: d.job.TaskID,
+					NodeID:         node.ID,
+					ParentJobID:    d.parentJobID,
+					Iteration:      d.iteration,
+					Status:         model.WorkflowJobPending,
+					QueuedAt:       &queuedAt,
+				}
+				newJobs = append(newJobs, job)
+			}
+
+			readyNodes = append(readyNodes, node)
+			nodeJobs = append(nodeJobs, job)
 		}
 
-		if err := d.workflowStore.CreateJobs(closeCtx, nodeJobs); err != nil {
-			return err
+		if len(readyNodes) == 0 {
+			return count, nil
 		}
 
-		for _, job := range nodeJobs {
-			d.jobMap[job.UUID] = job
-			d.nodeMap[job.NodeID] = job
+		if len(newJobs) > 0 {
+			if err := d.workflowStore.CreateJobs(ctx, newJobs); err != nil {
+				return count, err
+			}
 		}
 
-		for index, node := range noDepNodes {
+		skippedNodes := make([]*model.WorkflowNode, 0, len(readyNodes))
+		for index, node := range readyNodes {
 			newNode := node
-			newIndex := index
+			job := nodeJobs[index]
+			d.setJob(job)
+			// 已下发的节点不再参与就绪判断，完成后再从其他节点的依赖中移除
+			d.depLock.Lock()
+			delete(d.dependencies, newNode)
+			d.depLock.Unlock()
+
+			if d.shouldSkip(newNode) || d.upstreamFailed(newNode) {
+				d.skipNode(ctx, newNode, job)
+				skippedNodes = append(skippedNodes, newNode)
+				continue
+			}
+
+			// 等待类节点和人工节点不占用协程池
+			if isWaitNode(newNode) {
+				d.startWait(ctx, newNode, job, resultCh)
+				count++
+				continue
+			}
+
+			if newNode.Type == model.WorkflowManual {
+				d.startManual(ctx, newNode, job, resultCh)
+				count++
+				continue
+			}
+
 			d.wg.Add(1)
 			if err := d.pools.Submit(func() {
 				defer d.wg.Done()
 
-				if err := utils.SafelyRun(func() {
+				var err error
+				if panicErr := utils.SafelyRun(func() {
 					select {
-					case <-closeCtx.Done():
+					case <-ctx.Done():
+						err = code.JobCanceled
 						return
 					default:
 					}
 
-					if err := d.runNode(closeCtx, newNode, nodeJobs[newIndex]); err != nil {
-						if !errors.Is(err, code.JobCanceled) {
-							logger.Errorf(closeCtx, "node run fail node id: %d, err: %+v", newNode.ID, err)
-						}
-
-						if !hasError.Load() {
-							firstError.Store(err)
-							hasError.Store(true)
-							cancel()
-						}
-					}
-				}); err != nil {
-					if !errors.Is(err, code.JobCanceled) {
-						logger.Errorf(ctx, "run all node SafelyRun err: %+v", err)
-					}
+					err = d.runNode(ctx, newNode, job)
+				}); panicErr != nil {
+					logger.Errorf(ctx, "run all node SafelyRun err: %+v", panicErr)
+					err = code.JobRunFailErr.WithErr(panicErr)
 				}
+
+				resultCh <- &nodeResult{node: newNode, err: err}
 			}); err != nil {
+				d.wg.Done()
 				logger.Errorf(ctx, "run all node submit run node fail err: %+v", err)
+				return count, code.JobRunFailErr.WithErr(err)
 			}
 
+			count++
 		}
 
-		d.wg.Wait()
-
-		if hasError.Load() {
-			return firstError.Load().(error)
+		if len(skippedNodes) == 0 {
+			return count, nil
 		}
 
-		// 移除依赖关系
-		for _, runnedNode := range noDepNodes {
-			delete(d.dependencies, runnedNode)
-			for _, nodeDependences := range d.dependencies {
-				delete(nodeDependences, runnedNode)
-			}
+		// 跳过的节点直接完成，继续下发因此就绪的节点
+		d.removeDependencies(skippedNodes)
+	}
+}
+
+func (d *dagEngine) removeDependencies(nodes []*model.WorkflowNode) {
+	d.depLock.Lock()
+	defer d.depLock.Unlock()
+	for _, runnedNode := range nodes {
+		delete(d.dependencies, runnedNode)
+		for _, nodeDependences := range d.dependencies {
+			delete(nodeDependences, runnedNode)
 		}
 	}
 }
 
+func (d *dagEngine) setJob(job *model.WorkflowNodeJob) {
+	d.jobLock.Lock()
+	defer d.jobLock.Unlock()
+	d.jobMap[job.UUID] = job
+	d.nodeMap[job.NodeID] = job
+}
+
+func (d *dagEngine) getJob(jobUUID uuid.UUID) (*model.WorkflowNodeJob, bool) {
+	d.jobLock.RLock()
+	defer d.jobLock.RUnlock()
+	job, ok := d.jobMap[jobUUID]
+	return job, ok
+}
+
+func (d *dagEngine) getNodeJob(nodeID int64) (*model.WorkflowNodeJob, bool) {
+	d.jobLock.RLock()
+	defer d.jobLock.RUnlock()
+	job, ok := d.nodeMap[nodeID]
+	return job, ok
+}
+
+// job 状态和尝试次数会被状态查询并发读取，统一在 jobLock 下修改
+func (d *dagEngine) setJobStatus(job *model.WorkflowNodeJob, status model.WorkflowJobStatus) {
+	d.jobLock.Lock()
+	defer d.jobLock.Unlock()
+	job.Status = status
+}
+
+func (d *dagEngine) addJobAttempt(job *model.WorkflowNodeJob) {
+	d.jobLock.Lock()
+	defer d.jobLock.Unlock()
+	job.Attempt++
+}
+
+// 在 jobLock 下复制节点 job 的状态，供状态查询使用
+func (d *dagEngine) nodeJobState(nodeID int64) (model.WorkflowNodeJob, bool) {
+	d.jobLock.RLock()
+	defer d.jobLock.RUnlock()
+	job, ok := d.nodeMap[nodeID]
+	if !ok {
+		return model.WorkflowNodeJob{}, false
+	}
+
+	state := model.WorkflowNodeJob{
+		Status:  job.Status,
+		Attempt: job.Attempt,
+	}
+	state.UUID = job.UUID
+	return state, true
+}
+
+// edge 按根任务路由消息，子工作流使用根任务 uuid 与 edge 通信
+func (d *dagEngine) edgeTaskID() uuid.UUID {
+	if d.root != nil {
+		return d.root.job.TaskUUID
+	}
+
+	return d.job.TaskUUID
+}
+
+// 任务上下文被取消但并非用户主动停止，说明 edge 连接已关闭
+func (d *dagEngine) isInterrupted() bool {
+	return d.ctx.Err() != nil && !d.stopped.Load()
+}
+
 func (d *dagEngine) parsePreNodeParam(ctx context.Context, node *model.WorkflowNode) error {
 	// dynamicConf := config.Global().Dynamic()
 	// if !dynamicConf.Schedule.TranslateNodeParam {
@@ -511,7 +825,13 @@ func (d *dagEngine) parsePreNodeParam(ctx context.Context, node *model.WorkflowN
 			continue
 		}
 
-		if p.SourceHandle == nil || p.SourceHandle.DataKey == "" {
+		// 上游被跳过或分支未选中，没有可传递的数据
+		if !d.isEdgeActive(p) {
+			continue
+		}
+
+		// 上游失败后被忽略，目标参数保留节点自身配置的值
+		if d.sourceFailed(p) {
 			continue
 		}
 
@@ -519,12 +839,24 @@ func (d *dagEngine) parsePreNodeParam(ctx context.Context, node *model.WorkflowN
 			continue
 		}
 
+		// 配置了表达式的边按表达式计算目标参数
+		if p.Expression != nil {
+			if err := d.applyEdgeExpression(node, p, pairs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if p.SourceHandle == nil || p.SourceHandle.DataKey == "" {
+			continue
+		}
+
 		if p.SourceHandle.DataSource != "executor" ||
 			p.SourceHandle.HandleKey == "ready" {
 			continue
 		}
 
-		job, ok := d.nodeMap[p.SourceNode.ID]
+		job, ok := d.getNodeJob(p.SourceNode.ID)
 		if !ok {
 			return code.CanNotGetParentJobErr.WithMsg(
 				fmt.Sprintf("parent node id: %d, node id: %d", p.SourceNode.ID, node.ID))
@@ -552,9 +884,8 @@ func (d *dagEngine) parsePreNodeParam(ctx context.Context, node *model.WorkflowN
 }
 
 func (d *dagEngine) runNode(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) error {
-	if err := d.parsePreNodeParam(ctx, node); err != nil {
-		return err
-	}
+	// 恢复任务时，已下发但未回调的 job 处于 running 状态
+	inflight := job.Status == model.WorkflowJobRunning
 
 	data := &engine.BoardMsg{
 		TaskStatus: "running",
@@ -569,70 +900,169 @@ func (d *dagEngine) runNode(ctx context.Context, node *model.WorkflowNode, job *
 
 	var err error
 	defer func() {
-		jobStatus := model.WorkflowJobFailed
-		data.Msg = "failed"
-		data.Timestamp = time.Now()
-		data.ReturnInfos = job.ReturnInfo
-		if err != nil {
-			jobStatus = model.WorkflowJobFailed
-			if errors.Is(err, code.JobCanceled) {
-				data.Msg = "job canceled"
-				data.Type = "warning"
-				data.JobStatus = "failed"
-				jobStatus = model.WorkflowJobCanceled
-			}
+		d.finishNode(ctx, data, job, err)
+	}()
 
-			if errors.Is(err, code.JobTimeoutErr) {
-				data.Msg = "job timeout"
-				data.Type = "warning"
-				data.JobStatus = "failed"
-				jobStatus = model.WorkflowJobTimeout
-			} else {
-				data.Msg = "job failed"
-				data.Type = "warning"
-				data.JobStatus = "failed"
-				data.StackTrace = append(data.StackTrace, err.Error())
-				jobStatus = model.WorkflowJobFailed
-			}
+	// 参数解析失败同样通知前端
+	if err = d.parsePreNodeParam(ctx, node); err != nil {
+		return err
+	}
+
+	policy := node.RetryPolicy.Data()
+	for {
+		start := time.Now()
+		err = d.runAttempt(ctx, node, job, inflight)
+		inflight = false
+		if d.isInterrupted() {
+			return err
+		}
+
+		d.recordAttempt(ctx, job, start, err)
+		if !shouldRetry(node, policy, job.Attempt, err) {
+			return err
+		}
+
+		logger.Warnf(ctx, "node run fail, retry node id: %d, attempt: %d, err: %+v", node.ID, job.Attempt, err)
+		if err = d.waitRetry(ctx, node, job, err); err != nil {
+			return err
+		}
+	}
+}
+
+// 节点运行结束，写入最终状态并通知前端
+func (d *dagEngine) finishNode(ctx context.Context, data *engine.BoardMsg, job *model.WorkflowNodeJob, err error) {
+	// 中断时保留 job 状态，恢复后重新查询
+	if d.isInterrupted() {
+		return
+	}
+
+	jobStatus := model.WorkflowJobFailed
+	data.Msg = "failed"
+	data.Timestamp = time.Now()
+	data.ReturnInfos = job.ReturnInfo
+	data.Attempt = job.Attempt
+	var codeErr code.ErrCodeWithMsg
+	if err != nil {
+		jobStatus = model.WorkflowJobFailed
+		if errors.Is(err, code.JobCanceled) {
+			data.Msg = "job canceled"
+			data.Type = "warning"
+			data.JobStatus = "failed"
+			jobStatus = model.WorkflowJobCanceled
+		} else if errors.Is(err, code.JobTimeoutErr) {
+			data.Msg = "job timeout"
+			data.Type = "warning"
+			data.JobStatus = "failed"
+			jobStatus = model.WorkflowJobTimeout
+		} else if errors.As(err, &codeErr) && codeErr.ErrCode == code.EdgeExpressionErr {
+			data.Msg = "edge expression failed"
+			data.Type = "error"
+			data.JobStatus = "failed"
+			data.StackTrace = append(data.StackTrace, codeErr.Msgs())
+			jobStatus = model.WorkflowJobFailed
 		} else {
-			data.Msg = "success"
-			data.JobStatus = "success"
-			jobStatus = model.WorkflowJobSuccess
+			data.Msg = "job failed"
+			data.Type = "warning"
+			data.JobStatus = "failed"
+			data.StackTrace = append(data.StackTrace, err.Error())
+			jobStatus = model.WorkflowJobFailed
 		}
+	} else if job.Status == model.WorkflowJobSkipped {
+		// 人工节点超时跳过
+		data.Msg = "skipped"
+		data.JobStatus = string(model.WorkflowJobSkipped)
+		jobStatus = model.WorkflowJobSkipped
+	} else {
+		data.Msg = "success"
+		data.JobStatus = "success"
+		jobStatus = model.WorkflowJobSuccess
+	}
 
-		d.boardMsg(ctx, data)
-		d.updateJob(ctx, jobStatus, job.ID)
-	}()
+	d.setJobStatus(job, jobStatus)
+	d.boardMsg(ctx, data)
+	d.finishJob(ctx, job)
+}
+
+// 执行节点的一次尝试
+func (d *dagEngine) runAttempt(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob, inflight bool) error {
+	// 下发前获取设备租约，设备被其他任务占用时排队，仿真任务不占用真实设备
+	if node.Type != model.WorkflowNodeILab || d.simulated {
+		return d.execAttempt(ctx, node, job, inflight)
+	}
+
+	leaseCtx, release, err := d.acquireDevice(ctx, node, job)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// 租约丢失时设备可能已被其他任务使用，节点按失败处理
+	err = d.execAttempt(leaseCtx, node, job, inflight)
+	if err != nil && ctx.Err() == nil && leaseCtx.Err() != nil {
+		return context.Cause(leaseCtx)
+	}
 
+	return err
+}
+
+func (d *dagEngine) execAttempt(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob, inflight bool) error {
+	if inflight && node.Type == model.WorkflowNodeILab {
+		return d.resumeAction(ctx, node, job)
+	}
+
+	d.addJobAttempt(job)
 	// 查询 action 是否可以执行
 	if node.Type == model.WorkflowNodeILab {
-		err = d.queryAction(ctx, node, job)
-		if err != nil {
+		if err := d.queryAction(ctx, node, job); err != nil {
 			return err
 		}
 	}
 
-	err = d.execNodeAction(ctx, node, job)
+	// 下发前记录 running，恢复时据此判断是否需要向 edge 重新查询
+	d.startAttempt(ctx, node, job)
+
+	err := d.execNodeAction(ctx, node, job)
 	if err != nil {
 		return err
 	}
 
-	if node.Type == model.WorkflowPyScript {
+	if node.Type != model.WorkflowNodeILab {
 		return err
 	}
 
 	key := engine.ActionKey{
 		Type:       engine.JobCallbackStatus,
-		TaskID:     d.job.TaskUUID,
+		TaskID:     d.edgeTaskID(),
 		JobID:      job.UUID,
 		DeviceID:   *node.DeviceName,
 		ActionName: node.ActionName,
 	}
 
-	d.InitDeviceActionStatus(ctx, key, time.Now().Add(20*time.Second), false)
-	err = d.callbackAction(ctx, key, job)
+	d.InitDeviceActionStatus(ctx, key, time.Now().Add(d.nodeTimeout(node)), false)
+	return d.callbackAction(ctx, key, job)
+}
 
-	return err
+func (d *dagEngine) acquireDevice(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) (context.Context, lock.ReleaseFunc, error) {
+	return d.deviceLock.Acquire(ctx, &lock.Holder{
+		LabUUID:    d.job.LabUUID,
+		DeviceName: *node.DeviceName,
+		HolderID:   job.UUID,
+		TaskUUID:   d.job.TaskUUID,
+		NodeUUID:   node.UUID,
+		ActionName: node.ActionName,
+		Source:     lock.SourceWorkflow,
+		UserID:     d.job.UserID,
+	}, func(current *lock.Holder) {
+		d.boardMsg(ctx, &engine.BoardMsg{
+			TaskStatus: "running",
+			JobStatus:  "pending",
+			Header:     node.ActionName,
+			NodeUUID:   node.UUID,
+			Type:       "info",
+			Msg:        fmt.Sprintf("device %s is busy, waiting for task %s", *node.DeviceName, current.TaskUUID),
+			Timestamp:  time.Now(),
+		})
+	})
 }
 
 func (d *dagEngine) queryAction(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) error {
@@ -642,40 +1072,20 @@ func (d *dagEngine) queryAction(ctx context.Context, node *model.WorkflowNode, j
 
 	key := engine.ActionKey{
 		Type:   engine.QueryActionStatus,
-		TaskID: d.job.TaskUUID,
+		TaskID: d.edgeTaskID(),
 		JobID:  job.UUID,
 		DeviceID: utils.SafeValue(func() string {
 			return *node.DeviceName
 		}, ""),
 		ActionName: node.ActionName,
 	}
-	d.InitDeviceActionStatus(ctx, key, time.Now().Add(time.Second*20), false)
+	queryTimeout := time.Duration(config.Global().Job.QueryTimeoutSecond) * time.Second
+	d.InitDeviceActionStatus(ctx, key, time.Now().Add(queryTimeout), false)
 	if err := d.sendQueryAction(ctx, node, job); err != nil {
 		return err
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return code.JobCanceled
-		default:
-		}
-
-		time.Sleep(time.Millisecond * 500)
-		value, exist := d.GetDeviceActionStatus(ctx, key)
-		if !exist {
-			return code.QueryJobStatusKeyNotExistErr
-		}
-
-		if value.Free {
-			d.DelStatus(ctx, key)
-			return nil
-		}
-
-		if value.Timestamp.Unix() < time.Now().Unix() {
-			return code.JobTimeoutErr
-		}
-	}
+	return d.actionStatus.Wait(ctx, key, code.QueryJobStatusKeyNotExistErr)
 }
 
 func (d *dagEngine) sendQueryAction(_ context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) error {
@@ -686,7 +1096,7 @@ func (d *dagEngine) sendQueryAction(_ context.Context, node *model.WorkflowNode,
 	data := schedule.SendAction[engine.ActionKey]{
 		Action: schedule.QueryActionStatus,
 		Data: engine.ActionKey{
-			TaskID:     d.job.TaskUUID,
+			TaskID:     d.edgeTaskID(),
 			JobID:      job.UUID,
 			DeviceID:   *node.DeviceName,
 			ActionName: node.ActionName,
@@ -703,6 +1113,12 @@ func (d *dagEngine) execNodeAction(ctx context.Context, node *model.WorkflowNode
 		return d.sendAction(ctx, node, job)
 	case model.WorkflowPyScript:
 		return d.execScript(ctx, node, job)
+	case model.WorkflowBranch:
+		return d.execBranch(ctx, node, job)
+	case model.WorkflowMap:
+		return d.execMap(ctx, node, job)
+	case model.WorkflowSubflow:
+		return d.execSubflow(ctx, node, job)
 	default:
 		return code.UnknownWorkflowNodeTypeErr
 	}
@@ -724,8 +1140,12 @@ func (d *dagEngine) execScript(ctx context.Context, node *model.WorkflowNode, jo
 		returnInfo.Suc = false
 	}
 
-	if err != nil || errMsg != "" {
-		job.Status = model.WorkflowJobFailed
+	if err == nil && errMsg != "" {
+		err = code.JobRunFailErr.WithMsg(errMsg)
+	}
+
+	if err != nil {
+		d.setJobStatus(job, model.WorkflowJobFailed)
 	}
 
 	job.ReturnInfo = datatypes.NewJSONType(returnInfo)
@@ -753,7 +1173,7 @@ func (d *dagEngine) sendAction(_ context.Context, node *model.WorkflowNode, job
 			ActionType: node.ActionType,
 			ActionArgs: node.Param,
 			JobID:      job.UUID,
-			TaskID:     d.job.TaskUUID,
+			TaskID:     d.edgeTaskID(),
 			NodeID:     node.UUID,
 			ServerInfo: engine.ServerInfo{
 				SendTimestamp: float64(time.Now().UnixNano()) / 1e9,
@@ -770,27 +1190,8 @@ func (d *dagEngine) sendAction(_ context.Context, node *model.WorkflowNode, job
 }
 
 func (d *dagEngine) callbackAction(ctx context.Context, key engine.ActionKey, job *model.WorkflowNodeJob) error {
-	for {
-		select {
-		case <-ctx.Done():
-			return code.JobCanceled
-		default:
-		}
-
-		time.Sleep(time.Millisecond * 500)
-		value, exist := d.GetDeviceActionStatus(ctx, key)
-		if !exist {
-			return code.CallbackJobStatusKeyNotExistErr
-		}
-
-		if value.Free {
-			d.DelStatus(ctx, key)
-			break
-		}
-
-		if value.Timestamp.Unix() < time.Now().Unix() {
-			return code.JobTimeoutErr
-		}
+	if err := d.actionStatus.Wait(ctx, key, code.CallbackJobStatusKeyNotExistErr); err != nil {
+		return err
 	}
 
 	// 查询任务状态是否回调成功
@@ -812,27 +1213,20 @@ func (d *dagEngine) callbackAction(ctx context.Context, key engine.ActionKey, jo
 	}
 }
 
-func (d *dagEngine) GetStatus(_ context.Context) error {
-	return nil
-}
-
 func (d *dagEngine) OnJobUpdate(ctx context.Context, data *engine.JobData) error {
+	if answered, ok := d.resuming.Load(data.JobID); ok {
+		answered.(*atomic.Bool).Store(true)
+	}
+
 	if data.Status == "running" {
+		d.markJobStarted(ctx, data.JobID)
 		return nil
 	}
 
-	d.SetDeviceActionStatus(ctx, engine.ActionKey{
-		Type:       engine.JobCallbackStatus,
-		TaskID:     data.TaskID,
-		JobID:      data.JobID,
-		DeviceID:   data.DeviceID,
-		ActionName: data.ActionName,
-	}, true, 0)
-
-	if job, ok := d.jobMap[data.JobID]; ok {
+	if job, ok := d.getJob(data.JobID); ok {
 		job.ReturnInfo = data.ReturnInfo
 		job.FeedbackData = data.FeedbackData
-		job.Status = model.WorkflowJobStatus(data.Status)
+		d.setJobStatus(job, model.WorkflowJobStatus(data.Status))
 	}
 
 	if err := d.workflowStore.UpdateData(ctx, &model.WorkflowNodeJob{
@@ -849,9 +1243,26 @@ func (d *dagEngine) OnJobUpdate(ctx context.Context, data *engine.JobData) error
 		logger.Errorf(ctx, "onJobStatus update job fail uuid: %s, err: %+v", data.JobID, err)
 	}
 
+	// 结果落库后再唤醒等待方，callbackAction 被唤醒后会立即读取 job 状态
+	d.SetDeviceActionStatus(ctx, engine.ActionKey{
+		Type:       engine.JobCallbackStatus,
+		TaskID:     data.TaskID,
+		JobID:      data.JobID,
+		DeviceID:   data.DeviceID,
+		ActionName: data.ActionName,
+	}, true, 0)
+
 	return nil
 }
 
+// 设备上报 running 时记录本次尝试的开始时间
+// 回调都发往根任务，map 迭代和子工作流的 job 不在根任务的 jobMap 中，按 uuid 直接更新数据库
+func (d *dagEngine) markJobStarted(ctx context.Context, jobID uuid.UUID) {
+	if err := d.workflowStore.MarkJobStarted(context.Background(), jobID, time.Now()); err != nil {
+		logger.Errorf(ctx, "engine dag markJobStarted job uuid: %s, err: %+v", jobID, err)
+	}
+}
+
 func (d *dagEngine) updateTaskStatus(ctx context.Context, status model.WorkflowTaskStatus, taskID int64) {
 	data := &model.WorkflowTask{
 		Status:       status,
@@ -865,20 +1276,29 @@ func (d *dagEngine) updateTaskStatus(ctx context.Context, status model.WorkflowT
 	}
 }
 
-func (d *dagEngine) updateJob(ctx context.Context, status model.WorkflowJobStatus, jobID int64) {
+// 写入 job 最终状态和结束时间
+func (d *dagEngine) finishJob(ctx context.Context, job *model.WorkflowNodeJob) {
+	now := time.Now()
+	job.FinishedAt = &now
 	data := &model.WorkflowNodeJob{
-		Status: status,
+		Status:     job.Status,
+		FinishedAt: &now,
 	}
-	data.UpdatedAt = time.Now()
+	data.UpdatedAt = now
 
 	if err := d.workflowStore.UpdateData(context.Background(), data, map[string]any{
-		"id": jobID,
-	}, "status", "updated_at"); err != nil {
-		logger.Errorf(ctx, "engine dag updateJob job id: %+v, err: %+v", jobID, err)
+		"id": job.ID,
+	}, "status", "finished_at", "updated_at"); err != nil {
+		logger.Errorf(ctx, "engine dag finishJob job id: %+v, err: %+v", job.ID, err)
 	}
 }
 
 func (d *dagEngine) boardMsg(ctx context.Context, msg *engine.BoardMsg) {
+	if d.mapNode != nil {
+		msg.MapNodeUUID = d.mapNode.UUID
+		msg.Iteration = d.iteration
+	}
+
 	if err := d.boardEvent.Broadcast(context.Background(), &notify.SendMsg{
 		Channel:      notify.WorkflowRun,
 		TaskUUID:     d.job.TaskUUID,
@@ -891,36 +1311,34 @@ func (d *dagEngine) boardMsg(ctx context.Context, msg *engine.BoardMsg) {
 	}); err != nil {
 		logger.Errorf(ctx, "schedule board msg fail err: %+v", err)
 	}
+
+	// 子工作流的消息同时推送到父任务
+	if d.parent != nil {
+		parentMsg := *msg
+		parentMsg.TaskStatus = "running"
+		parentMsg.SubflowNodeUUID = d.subflowNode.UUID
+		parentMsg.SubTaskUUID = d.job.TaskUUID
+		d.parent.boardMsg(ctx, &parentMsg)
+	}
 }
 
 func (d *dagEngine) GetDeviceActionStatus(ctx context.Context, key engine.ActionKey) (engine.ActionValue, bool) {
-	valueI, ok := d.actionStatus.Load(key)
-	if !ok {
-		return engine.ActionValue{}, false
-	}
-	return valueI.(engine.ActionValue), true
+	return d.actionStatus.Get(key)
 }
 
 func (d *dagEngine) SetDeviceActionStatus(ctx context.Context, key engine.ActionKey, free bool, needMore time.Duration) {
-	valueI, ok := d.actionStatus.Load(key)
-	if ok {
-		value := valueI.(engine.ActionValue)
-		value.Free = free
-		value.Timestamp = value.Timestamp.Add(needMore)
-		logger.Warnf(ctx, "SetDeviceActionStatus key: %+v, value: %+v, more: %d", key, value, needMore)
-		d.actionStatus.Store(key, value)
-	} else {
+	value, ok := d.actionStatus.Set(key, free, needMore)
+	if !ok {
 		logger.Warnf(ctx, "SetDeviceActionStatus not found key: %+v", key)
+		return
 	}
+	logger.Infof(ctx, "SetDeviceActionStatus key: %+v, value: %+v, more: %d", key, value, needMore)
 }
 
 func (d *dagEngine) InitDeviceActionStatus(ctx context.Context, key engine.ActionKey, start time.Time, free bool) {
-	d.actionStatus.Store(key, engine.ActionValue{
-		Timestamp: start,
-		Free:      free,
-	})
+	d.actionStatus.Init(key, start, free)
 }
 
 func (d *dagEngine) DelStatus(ctx context.Context, key engine.ActionKey) {
-	d.actionStatus.Delete(key)
+	d.actionStatus.Del(key)
 }
@@ -0,0 +1,83 @@
+package dag
+
+import (
+	"encoding/json"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"gorm.io/datatypes"
+)
+
+// 执行边上的表达式，结果写入目标 handle 对应的参数
+func (d *dagEngine) applyEdgeExpression(node *model.WorkflowNode, p *engine.HandlePair, pairs []*engine.HandlePair) error {
+	exprErr := func(err error) error {
+		return code.EdgeExpressionErr.WithMsgf("edge: %s, expression: %s, err: %v", p.Edge.UUID, p.Expression, err)
+	}
+
+	job, ok := d.getNodeJob(p.SourceNode.ID)
+	if !ok {
+		return code.CanNotGetParentJobErr.WithMsgf("parent node id: %d, node id: %d", p.SourceNode.ID, node.ID)
+	}
+
+	source := job.ReturnInfo.Data().ReturnValue
+	value := source
+	if p.SourceHandle != nil && p.SourceHandle.DataKey != "" {
+		sourceB, err := json.Marshal(source)
+		if err != nil {
+			return exprErr(err)
+		}
+		// 取不到时为 null，由表达式决定默认值
+		value = gjson.GetBytes(sourceB, p.SourceHandle.DataKey).Value()
+	}
+
+	var param any
+	if len(node.Param) > 0 {
+		if err := json.Unmarshal(node.Param, &param); err != nil {
+			return exprErr(err)
+		}
+	}
+
+	res, err := p.Expression.Eval(map[string]any{
+		"value":    value,
+		"source":   source,
+		"param":    param,
+		"upstream": d.upstreamValues(pairs),
+	})
+	if err != nil {
+		return exprErr(err)
+	}
+
+	jsonStr, err := sjson.Set(string(node.Param), p.TargetHandle.DataKey, res)
+	if err != nil {
+		return exprErr(err)
+	}
+	node.Param = datatypes.JSON(jsonStr)
+
+	return nil
+}
+
+// 生效的上游节点返回值，同时按节点名和 uuid 索引
+func (d *dagEngine) upstreamValues(pairs []*engine.HandlePair) map[string]any {
+	upstream := make(map[string]any, len(pairs)*2)
+	for _, p := range pairs {
+		if p.SourceNode == nil || !d.isEdgeActive(p) || d.sourceFailed(p) {
+			continue
+		}
+
+		job, ok := d.getNodeJob(p.SourceNode.ID)
+		if !ok {
+			continue
+		}
+
+		value := job.ReturnInfo.Data().ReturnValue
+		upstream[p.SourceNode.UUID.String()] = value
+		if p.SourceNode.Name != "" {
+			upstream[p.SourceNode.Name] = value
+		}
+	}
+
+	return upstream
+}
@@ -0,0 +1,85 @@
+package dag
+
+import (
+	"context"
+	"errors"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/model"
+)
+
+// 节点失败处理策略：立即停止任务、跳过失败节点的下游继续其他分支、忽略失败
+
+func (d *dagEngine) nodeFailurePolicy(node *model.WorkflowNode) model.FailurePolicy {
+	if node.FailurePolicy != model.FailureInherit {
+		return node.FailurePolicy
+	}
+
+	if d.failurePolicy != model.FailureInherit {
+		return d.failurePolicy
+	}
+
+	return model.FailureFailFast
+}
+
+// 节点失败后任务是否可以继续运行，取消和任务整体超时始终停止任务
+func (d *dagEngine) tolerateFailure(ctx context.Context, node *model.WorkflowNode, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, code.JobCanceled) {
+		return false
+	}
+
+	switch d.nodeFailurePolicy(node) {
+	case model.FailureContinueBranches:
+		d.partial.Store(true)
+		return true
+	case model.FailureIgnoreError:
+		// 任务最终为部分成功，不因忽略的失败而显示为成功
+		d.partial.Store(true)
+		return true
+	default:
+		return false
+	}
+}
+
+// 上游节点运行失败，按 ignore_error 继续时其返回值不可信，不向下游传递
+func (d *dagEngine) sourceFailed(p *engine.HandlePair) bool {
+	job, ok := d.getNodeJob(p.SourceNode.ID)
+	if !ok {
+		return false
+	}
+
+	switch job.Status {
+	case model.WorkflowJobFailed, model.WorkflowJobTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// 上游存在按 continue_branches 处理的失败节点，失败节点的下游跳过后继续向下传递
+func (d *dagEngine) upstreamFailed(node *model.WorkflowNode) bool {
+	for _, p := range d.nodeParentEdges[node.ID] {
+		if p.SourceNode == nil {
+			continue
+		}
+
+		job, ok := d.getNodeJob(p.SourceNode.ID)
+		if !ok {
+			continue
+		}
+
+		switch job.Status {
+		case model.WorkflowJobFailed, model.WorkflowJobTimeout:
+			if d.nodeFailurePolicy(p.SourceNode) != model.FailureIgnoreError {
+				return true
+			}
+		case model.WorkflowJobSkipped:
+			if d.upstreamFailed(p.SourceNode) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
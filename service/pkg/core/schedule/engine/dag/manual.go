@@ -0,0 +1,322 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"gorm.io/datatypes"
+)
+
+// 人工节点：运行到该节点时生成待处理记录并通知前端，当前分支等待实验室成员确认或拒绝，
+// 确认时提交的表单作为节点输出，超时按配置失败或跳过下游分支
+//
+// 节点 param 示例:
+// {"title": "检查样品", "message": "...", "form": [{"name": "volume", "type": "number", "required": true}],
+//  "role": "admin", "timeout_second": 600, "on_timeout": "skip"}
+
+type manualParam struct {
+	Title         string                     `json:"title"`
+	Message       string                     `json:"message"`
+	Form          []model.ManualField        `json:"form"`
+	Role          model.LaboratoryMemberRole `json:"role"`           // 为空实验室成员均可处理
+	TimeoutSecond int64                      `json:"timeout_second"` // 0 不超时
+	OnTimeout     model.ManualTimeoutPolicy  `json:"on_timeout"`     // 默认 fail
+}
+
+// 等待人工处理的节点，根任务和子图共享
+type manualGate struct {
+	waits sync.Map // approval uuid -> *manualWait
+}
+
+type manualWait struct {
+	lock     sync.Mutex // 保护以下字段的初始化和 finished
+	finished bool
+	timer    *time.Timer
+	stopCtx  func() bool
+	remove   func()
+
+	decide func(decision *engine.ManualDecision) error // 注册后设置
+	done   func(err error)
+}
+
+func parseManualParam(node *model.WorkflowNode) (*manualParam, error) {
+	param := &manualParam{}
+	if err := json.Unmarshal(node.Param, param); err != nil {
+		return nil, code.WorkflowNodeManualConfigErr.WithErr(err)
+	}
+
+	switch param.Role {
+	case "", model.LaboratoryMemberAdmin, model.LaboratoryMemberNormal:
+	default:
+		return nil, code.WorkflowNodeManualConfigErr.WithMsgf("node id: %d, unknown role: %s", node.ID, param.Role)
+	}
+
+	switch param.OnTimeout {
+	case "":
+		param.OnTimeout = model.ManualTimeoutFail
+	case model.ManualTimeoutFail, model.ManualTimeoutSkip:
+	default:
+		return nil, code.WorkflowNodeManualConfigErr.WithMsgf("node id: %d, unknown timeout policy: %s", node.ID, param.OnTimeout)
+	}
+
+	if param.TimeoutSecond < 0 {
+		return nil, code.WorkflowNodeManualConfigErr.WithMsgf("node id: %d, invalid timeout second", node.ID)
+	}
+
+	names := make(map[string]struct{}, len(param.Form))
+	for _, field := range param.Form {
+		if field.Name == "" {
+			return nil, code.WorkflowNodeManualConfigErr.WithMsgf("node id: %d, empty field name", node.ID)
+		}
+
+		if _, ok := names[field.Name]; ok {
+			return nil, code.WorkflowNodeManualConfigErr.WithMsgf("node id: %d, duplicate field: %s", node.ID, field.Name)
+		}
+		names[field.Name] = struct{}{}
+
+		switch field.Type {
+		case "string", "number", "boolean":
+		default:
+			return nil, code.WorkflowNodeManualConfigErr.WithMsgf("node id: %d, field %s unknown type: %s", node.ID, field.Name, field.Type)
+		}
+	}
+
+	return param, nil
+}
+
+// 下发人工节点：生成待处理记录并注册等待后立即返回，不占用协程池，
+// 由 OnManualDecision、超时定时器或任务取消完成节点，结果写入 resultCh
+func (d *dagEngine) startManual(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob, resultCh chan<- *nodeResult) {
+	d.wg.Add(1)
+
+	// 恢复任务时，已开始等待的 job 处于 running 状态
+	inflight := job.Status == model.WorkflowJobRunning
+
+	data := &engine.BoardMsg{
+		TaskStatus: "running",
+		JobStatus:  "running",
+		Header:     node.ActionName,
+		NodeUUID:   node.UUID,
+		Type:       "info",
+		Msg:        "running",
+	}
+	d.boardMsg(ctx, data)
+
+	start := time.Now()
+	if !inflight {
+		d.addJobAttempt(job)
+	}
+
+	w := &manualWait{}
+	w.done = func(err error) {
+		if !d.isInterrupted() {
+			d.recordAttempt(ctx, job, start, err)
+		}
+		d.finishNode(ctx, data, job, err)
+		resultCh <- &nodeResult{node: node, err: err}
+		d.wg.Done()
+	}
+
+	if err := d.parsePreNodeParam(ctx, node); err != nil {
+		w.finish(func() error { return err })
+		return
+	}
+
+	if !inflight {
+		d.startAttempt(ctx, node, job)
+	}
+
+	param, err := parseManualParam(node)
+	if err != nil {
+		w.finish(func() error { return err })
+		return
+	}
+
+	approval, err := d.loadApproval(ctx, node, job, param)
+	if err != nil {
+		w.finish(func() error { return err })
+		return
+	}
+
+	w.decide = func(decision *engine.ManualDecision) error {
+		return d.decideManual(ctx, approval, job, decision)
+	}
+
+	d.boardMsg(ctx, &engine.BoardMsg{
+		TaskStatus: "running",
+		JobStatus:  string(model.WorkflowJobRunning),
+		Header:     node.ActionName,
+		NodeUUID:   node.UUID,
+		Type:       "manual",
+		Msg:        "waiting for manual confirmation",
+		Manual: &engine.ManualRequest{
+			UUID:      approval.UUID,
+			Title:     approval.Title,
+			Message:   approval.Message,
+			Form:      approval.Form,
+			Role:      approval.Role,
+			Deadline:  approval.Deadline,
+			OnTimeout: approval.OnTimeout,
+		},
+		Timestamp: time.Now(),
+	})
+
+	// 先持有锁完成注册，定时器和取消回调在注册完成后才能结束节点
+	w.lock.Lock()
+	d.manual.waits.Store(approval.UUID, w)
+	w.remove = func() { d.manual.waits.Delete(approval.UUID) }
+	if approval.Deadline != nil {
+		w.timer = time.AfterFunc(time.Until(*approval.Deadline), func() {
+			w.finish(func() error {
+				d.finishApproval(ctx, approval, model.ManualTimeout)
+				if approval.OnTimeout != model.ManualTimeoutSkip {
+					return code.JobTimeoutErr
+				}
+
+				d.setJobStatus(job, model.WorkflowJobSkipped)
+				return nil
+			})
+		})
+	}
+	w.stopCtx = context.AfterFunc(ctx, func() {
+		w.finish(func() error {
+			// 中断时保留待处理记录，恢复后继续等待
+			if !d.isInterrupted() {
+				d.finishApproval(ctx, approval, model.ManualCanceled)
+			}
+			return code.JobCanceled
+		})
+	})
+	w.lock.Unlock()
+}
+
+// 处理人工确认结果，确认时提交的表单作为节点输出
+func (d *dagEngine) decideManual(ctx context.Context, approval *model.WorkflowManualApproval, job *model.WorkflowNodeJob, decision *engine.ManualDecision) error {
+	approval.Input = decision.Input
+	approval.Comment = decision.Comment
+	approval.OperatorID = decision.UserID
+	if !decision.Confirm {
+		d.finishApproval(ctx, approval, model.ManualRejected)
+		return code.ManualRejectedErr.WithMsgf("operator: %s, comment: %s", decision.UserID, decision.Comment)
+	}
+
+	d.finishApproval(ctx, approval, model.ManualConfirmed)
+	d.setJobStatus(job, model.WorkflowJobSuccess)
+	job.ReturnInfo = datatypes.NewJSONType(model.ReturnInfo{
+		Suc:         true,
+		ReturnValue: map[string]any(approval.Input),
+	})
+	job.UpdatedAt = time.Now()
+
+	return d.workflowStore.UpdateData(context.Background(), job, map[string]any{
+		"id": job.ID,
+	}, "status", "return_info", "updated_at")
+}
+
+// 只有第一次调用生效，返回是否由本次调用完成节点
+func (w *manualWait) finish(result func() error) bool {
+	w.lock.Lock()
+	if w.finished {
+		w.lock.Unlock()
+		return false
+	}
+	w.finished = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if w.stopCtx != nil {
+		w.stopCtx()
+	}
+	if w.remove != nil {
+		w.remove()
+	}
+	w.lock.Unlock()
+
+	w.done(result())
+	return true
+}
+
+// 恢复任务时复用未处理的记录，保持原截止时间
+func (d *dagEngine) loadApproval(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob, param *manualParam) (*model.WorkflowManualApproval, error) {
+	approvals := make([]*model.WorkflowManualApproval, 0, 1)
+	if err := d.workflowStore.FindDatas(ctx, &approvals, map[string]any{
+		"job_id": job.ID,
+		"status": model.ManualWaiting,
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(approvals) > 0 {
+		return approvals[0], nil
+	}
+
+	root := d.rootEngine()
+	approval := &model.WorkflowManualApproval{
+		LabID:     d.job.LabData.ID,
+		TaskID:    root.job.TaskID,
+		JobID:     job.ID,
+		NodeUUID:  node.UUID,
+		Title:     param.Title,
+		Message:   param.Message,
+		Form:      param.Form,
+		Role:      param.Role,
+		OnTimeout: param.OnTimeout,
+		Status:    model.ManualWaiting,
+		Input:     datatypes.JSONMap{},
+	}
+	approval.UUID = uuid.NewV4()
+	if param.TimeoutSecond > 0 {
+		deadline := time.Now().Add(time.Duration(param.TimeoutSecond) * time.Second)
+		approval.Deadline = &deadline
+	}
+
+	if err := d.workflowStore.CreateData(ctx, approval); err != nil {
+		return nil, err
+	}
+
+	return approval, nil
+}
+
+func (d *dagEngine) finishApproval(ctx context.Context, approval *model.WorkflowManualApproval, status model.ManualStatus) {
+	now := time.Now()
+	approval.Status = status
+	approval.DecidedAt = &now
+	approval.UpdatedAt = now
+	if approval.Input == nil {
+		approval.Input = datatypes.JSONMap{}
+	}
+
+	if err := d.workflowStore.UpdateData(context.Background(), approval, map[string]any{
+		"id":     approval.ID,
+		"status": model.ManualWaiting,
+	}, "status", "input", "comment", "operator_id", "decided_at", "updated_at"); err != nil {
+		logger.Errorf(ctx, "engine dag finishApproval id: %d, err: %+v", approval.ID, err)
+	}
+}
+
+// 人工处理结果，只投递给正在等待的节点
+func (d *dagEngine) OnManualDecision(ctx context.Context, data *engine.ManualDecision) error {
+	if data == nil {
+		return code.ParamErr.WithMsg("empty manual decision")
+	}
+
+	value, ok := d.manual.waits.Load(data.ApprovalUUID)
+	if !ok {
+		logger.Warnf(ctx, "manual approval not waiting uuid: %s", data.ApprovalUUID)
+		return code.ManualApprovalFinishedErr
+	}
+
+	w := value.(*manualWait)
+	if !w.finish(func() error { return w.decide(data) }) {
+		return code.ManualApprovalFinishedErr
+	}
+
+	return nil
+}
@@ -0,0 +1,320 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+	"github.com/scienceol/studio/service/internal/config"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"gorm.io/datatypes"
+)
+
+// map 节点：对上游传入的列表逐个元素运行 map 节点内的子图，结果汇总为列表输出
+//
+// map 节点作为容器，子图为 parent 指向该 map 节点（可经过 Group）的所有节点。
+// 每次迭代的子图节点 param 中注入当前元素，每个子图节点在每次迭代中都有独立的 job。
+
+const mapResultsKey = "results"
+
+type mapParam struct {
+	ItemsPath   string `json:"items_path"`  // 列表在 map 节点 param 中的 gjson 路径，默认 items
+	ItemKey     string `json:"item_key"`    // 子图节点 param 中注入当前元素的 key，默认 item
+	Parallelism int    `json:"parallelism"` // 同时运行的迭代数，默认 1
+}
+
+func parseMapParam(node *model.WorkflowNode) (*mapParam, error) {
+	param := &mapParam{}
+	if len(node.Param) > 0 {
+		if err := json.Unmarshal(node.Param, param); err != nil {
+			return nil, code.WorkflowNodeMapConfigErr.WithErr(err)
+		}
+	}
+
+	param.ItemsPath = utils.Or(param.ItemsPath, "items")
+	param.ItemKey = utils.Or(param.ItemKey, "item")
+	if param.Parallelism <= 0 {
+		param.Parallelism = 1
+	}
+
+	return param, nil
+}
+
+// 将 map 节点内的节点和边从根图中拆分出来
+func (d *dagEngine) splitMapScopes(allNodes, nodes []*model.WorkflowNode,
+	edges []*model.WorkflowEdge,
+) ([]*model.WorkflowNode, []*model.WorkflowEdge) {
+	allNodeMap := utils.Slice2Map(allNodes, func(node *model.WorkflowNode) (int64, *model.WorkflowNode) {
+		return node.ID, node
+	})
+
+	// 节点所属的最近一层 map 节点
+	findMap := func(node *model.WorkflowNode) *model.WorkflowNode {
+		parent := allNodeMap[node.ParentID]
+		for depth := 0; parent != nil && depth < len(allNodes); depth++ {
+			if parent.Type == model.WorkflowMap {
+				return parent
+			}
+			parent = allNodeMap[parent.ParentID]
+		}
+		return nil
+	}
+
+	nodeScope := make(map[uuid.UUID]int64, len(nodes))
+	rootNodes := make([]*model.WorkflowNode, 0, len(nodes))
+	for _, node := range nodes {
+		mapNode := findMap(node)
+		if mapNode == nil {
+			rootNodes = append(rootNodes, node)
+			continue
+		}
+
+		// map 节点被禁用时子图一并禁用
+		if mapNode.Disabled {
+			continue
+		}
+
+		nodeScope[node.UUID] = mapNode.ID
+		d.mapChildren[mapNode.ID] = append(d.mapChildren[mapNode.ID], node)
+	}
+
+	rootEdges := make([]*model.WorkflowEdge, 0, len(edges))
+	for _, edge := range edges {
+		sourceScope := nodeScope[edge.SourceNodeUUID]
+		targetScope := nodeScope[edge.TargetNodeUUID]
+		switch {
+		case sourceScope != targetScope:
+			// 跨越 map 边界的边不参与调度，子图数据只通过迭代元素传入
+			continue
+		case sourceScope == 0:
+			rootEdges = append(rootEdges, edge)
+		default:
+			d.mapEdges[sourceScope] = append(d.mapEdges[sourceScope], edge)
+		}
+	}
+
+	return rootNodes, rootEdges
+}
+
+func (d *dagEngine) execMap(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) error {
+	param, err := parseMapParam(node)
+	if err != nil {
+		return err
+	}
+
+	items := gjson.GetBytes(node.Param, param.ItemsPath)
+	if !items.IsArray() {
+		return code.WorkflowNodeMapItemsErr.WithMsgf("node id: %d, path: %s", node.ID, param.ItemsPath)
+	}
+
+	// 重试或恢复时复用已有的迭代 job
+	existJobs := make([]*model.WorkflowNodeJob, 0, 1)
+	if err := d.workflowStore.FindDatas(ctx, &existJobs, map[string]any{
+		"workflow_task_id": d.job.TaskID,
+		"parent_job_id":    job.ID,
+	}); err != nil {
+		return err
+	}
+	iterationJobs := make(map[int][]*model.WorkflowNodeJob)
+	for _, j := range existJobs {
+		iterationJobs[j.Iteration] = append(iterationJobs[j.Iteration], j)
+	}
+
+	elems := items.Array()
+	results := make([]any, len(elems))
+	iterCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	setErr := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	sem := make(chan struct{}, param.Parallelism)
+dispatch:
+	for index, elem := range elems {
+		select {
+		case sem <- struct{}{}:
+		case <-iterCtx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		utils.SafelyGo(func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res, err := d.runIteration(iterCtx, node, job, param, index, elem.Value(), iterationJobs[index])
+			if err != nil {
+				setErr(err)
+				return
+			}
+			results[index] = res
+		}, func(err error) {
+			logger.Errorf(ctx, "map node run iteration node id: %d, index: %d, err: %+v", node.ID, index, err)
+			setErr(code.JobRunFailErr.WithErr(err))
+		})
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if ctx.Err() != nil {
+		return code.JobCanceled
+	}
+
+	d.setJobStatus(job, model.WorkflowJobSuccess)
+	job.ReturnInfo = datatypes.NewJSONType(model.ReturnInfo{
+		Suc: true,
+		ReturnValue: map[string]any{
+			mapResultsKey: results,
+		},
+	})
+	job.UpdatedAt = time.Now()
+
+	return d.workflowStore.UpdateData(ctx, job, map[string]any{
+		"id": job.ID,
+	}, "status", "return_info", "updated_at")
+}
+
+// 运行一次迭代，返回子图叶子节点的结果
+func (d *dagEngine) runIteration(ctx context.Context, mapNode *model.WorkflowNode, mapJob *model.WorkflowNodeJob,
+	param *mapParam, index int, item any, jobs []*model.WorkflowNodeJob,
+) (any, error) {
+	sub := d.newSubEngine(mapNode, mapJob.ID, index)
+	defer sub.pools.Release()
+
+	nodes, err := utils.FilterSliceWithErr(d.mapChildren[mapNode.ID], func(node *model.WorkflowNode) ([]*model.WorkflowNode, bool, error) {
+		// 每次迭代使用独立的节点副本，避免参数注入互相影响
+		clone := *node
+		nodeParam, err := sjson.SetBytes(node.Param, param.ItemKey, item)
+		if err != nil {
+			return nil, false, code.UpdateNodeErr.WithErr(err)
+		}
+		clone.Param = datatypes.JSON(nodeParam)
+		return []*model.WorkflowNode{&clone}, true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sub.nodes = nodes
+	sub.edges = d.mapEdges[mapNode.ID]
+	if err := sub.buildTask(ctx); err != nil {
+		return nil, err
+	}
+
+	sub.restoreIteration(jobs)
+	if err := sub.runAllNodes(ctx); err != nil {
+		return nil, err
+	}
+
+	return sub.iterationResult(), nil
+}
+
+func (d *dagEngine) newSubEngine(mapNode *model.WorkflowNode, parentJobID int64, iteration int) *dagEngine {
+	pools, _ := ants.NewPool(config.Global().Job.NodePoolSize,
+		ants.WithExpiryDuration(10*time.Second))
+
+	return &dagEngine{
+		job:             d.job,
+		cancel:          d.cancel,
+		ctx:             d.ctx,
+		session:         d.session,
+		envStore:        d.envStore,
+		workflowStore:   d.workflowStore,
+		handles:         d.handles,
+		dependencies:    make(map[*model.WorkflowNode]map[*model.WorkflowNode]struct{}),
+		pools:           pools,
+		boardEvent:      d.boardEvent,
+		jobMap:          make(map[uuid.UUID]*model.WorkflowNodeJob),
+		nodeMap:         make(map[int64]*model.WorkflowNodeJob),
+		nodeParentEdges: make(map[int64][]*engine.HandlePair),
+		nodeTimeouts:    d.nodeTimeouts,
+		sandbox:         d.sandbox,
+		actionStatus:    d.actionStatus,
+		stopped:         d.stopped,
+		deviceLock:      d.deviceLock,
+		pause:           d.pause,
+		manual:          d.manual,
+		waits:           d.waits,
+		mapChildren:     d.mapChildren,
+		mapEdges:        d.mapEdges,
+		mapNode:         mapNode,
+		parentJobID:     parentJobID,
+		iteration:       iteration,
+		root:            d.root,
+		parent:          d.parent,
+		subflowNode:     d.subflowNode,
+		simulated:       d.simulated,
+		failurePolicy:   d.failurePolicy,
+		partial:         d.partial,
+	}
+}
+
+// 复用本次迭代已有的 job，已完成的节点不再运行
+func (d *dagEngine) restoreIteration(jobs []*model.WorkflowNodeJob) {
+	nodeMap := utils.Slice2Map(d.nodes, func(node *model.WorkflowNode) (int64, *model.WorkflowNode) {
+		return node.ID, node
+	})
+
+	finishedNodes := make([]*model.WorkflowNode, 0, len(jobs))
+	for _, job := range jobs {
+		node, ok := nodeMap[job.NodeID]
+		if !ok {
+			continue
+		}
+
+		if job.Status == model.WorkflowJobSuccess || job.Status == model.WorkflowJobSkipped {
+			finishedNodes = append(finishedNodes, node)
+		}
+		d.setJob(job)
+	}
+
+	d.removeDependencies(finishedNodes)
+}
+
+// 子图叶子节点的返回值，只有一个叶子节点时直接返回其结果
+func (d *dagEngine) iterationResult() any {
+	sourceUUIDs := utils.Slice2Map(d.edges, func(e *model.WorkflowEdge) (uuid.UUID, struct{}) {
+		return e.SourceNodeUUID, struct{}{}
+	})
+
+	results := make(map[string]any)
+	for _, node := range d.nodes {
+		if _, ok := sourceUUIDs[node.UUID]; ok {
+			continue
+		}
+
+		job, ok := d.getNodeJob(node.ID)
+		if !ok || job.Status != model.WorkflowJobSuccess {
+			continue
+		}
+
+		results[node.UUID.String()] = job.ReturnInfo.Data().ReturnValue
+	}
+
+	if len(results) == 1 {
+		for _, v := range results {
+			return v
+		}
+	}
+
+	return results
+}
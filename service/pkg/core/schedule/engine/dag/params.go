@@ -0,0 +1,53 @@
+package dag
+
+import (
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+	"github.com/tidwall/sjson"
+	"gorm.io/datatypes"
+)
+
+// 运行参数写入绑定的节点参数，任务未保存的参数使用声明的默认值
+func (d *dagEngine) applyParams(wk *model.Workflow, nodes []*model.WorkflowNode) error {
+	if len(wk.Params) == 0 {
+		return nil
+	}
+
+	nodeMap := utils.Slice2Map(nodes, func(node *model.WorkflowNode) (uuid.UUID, *model.WorkflowNode) {
+		return node.UUID, node
+	})
+
+	for _, param := range wk.Params {
+		value, ok := d.params[param.Name]
+		if !ok {
+			value = param.Default
+		}
+
+		// 子工作流的参数由输入映射写入
+		if value == nil {
+			continue
+		}
+
+		for _, binding := range param.Bindings {
+			node, ok := nodeMap[binding.NodeUUID]
+			if !ok {
+				continue
+			}
+
+			paramStr := string(node.Param)
+			if paramStr == "" {
+				paramStr = "{}"
+			}
+
+			jsonStr, err := sjson.Set(paramStr, binding.Path, value)
+			if err != nil {
+				return code.WorkflowParamErr.WithMsgf("param %s binding path: %s, err: %+v", param.Name, binding.Path, err)
+			}
+			node.Param = datatypes.JSON(jsonStr)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package dag
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+)
+
+// 暂停控制：暂停期间不再下发新节点，已下发的动作继续运行直到完成
+
+type pauseGate struct {
+	lock     sync.Mutex
+	resumeCh chan struct{} // 暂停时非空，继续运行时关闭
+}
+
+// 返回是否由运行状态切换为暂停
+func (p *pauseGate) pause() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.resumeCh != nil {
+		return false
+	}
+
+	p.resumeCh = make(chan struct{})
+	return true
+}
+
+// 返回是否由暂停状态切换为运行
+func (p *pauseGate) resume() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.resumeCh == nil {
+		return false
+	}
+
+	close(p.resumeCh)
+	p.resumeCh = nil
+	return true
+}
+
+// 暂停时返回继续运行的通知 channel，未暂停返回 nil
+func (p *pauseGate) wait() <-chan struct{} {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.resumeCh
+}
+
+func (d *dagEngine) Pause(ctx context.Context) error {
+	if d.job == nil || d.job.TaskID == 0 {
+		return code.WorkflowTaskStatusErr
+	}
+
+	if !d.pause.pause() {
+		return nil
+	}
+
+	d.setTaskStatus(ctx, model.WorkflowTaskStatusPaused)
+	d.boardMsg(ctx, &engine.BoardMsg{
+		TaskStatus: string(model.WorkflowTaskStatusPaused),
+		JobStatus:  string(model.WorkflowJobPaused),
+		Type:       "info",
+		Msg:        "paused, running actions will finish",
+		Timestamp:  time.Now(),
+	})
+
+	return nil
+}
+
+func (d *dagEngine) Continue(ctx context.Context) error {
+	if d.job == nil || d.job.TaskID == 0 {
+		return code.WorkflowTaskStatusErr
+	}
+
+	if !d.pause.resume() {
+		return nil
+	}
+
+	d.setTaskStatus(ctx, model.WorkflowTaskStatusRunnig)
+	d.boardMsg(ctx, &engine.BoardMsg{
+		TaskStatus: "running",
+		JobStatus:  "pending",
+		Type:       "info",
+		Msg:        "continue",
+		Timestamp:  time.Now(),
+	})
+
+	return nil
+}
+
+func (d *dagEngine) setTaskStatus(ctx context.Context, status model.WorkflowTaskStatus) {
+	data := &model.WorkflowTask{
+		Status: status,
+	}
+	data.UpdatedAt = time.Now()
+	if err := d.workflowStore.UpdateData(context.Background(), data, map[string]any{
+		"id": d.job.TaskID,
+	}, "status", "updated_at"); err != nil {
+		logger.Errorf(ctx, "engine dag setTaskStatus id: %d, err: %+v", d.job.TaskID, err)
+	}
+}
+
+// 推送暂停期间未下发的就绪节点
+func (d *dagEngine) boardPausedNodes(ctx context.Context) {
+	for node, nodeDependences := range d.dependencies {
+		if len(nodeDependences) > 0 {
+			continue
+		}
+
+		d.boardMsg(ctx, &engine.BoardMsg{
+			TaskStatus: string(model.WorkflowTaskStatusPaused),
+			JobStatus:  string(model.WorkflowJobPaused),
+			Header:     node.ActionName,
+			NodeUUID:   node.UUID,
+			Type:       "info",
+			Msg:        "paused",
+			Timestamp:  time.Now(),
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/scienceol/studio/service/internal/config"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+/*
+	恢复中断任务：根据已落库的 job 重建剩余的 dag
+	已下发但未回调的设备 job 不重复下发，通过 query_job_status 向 edge 查询:
+1. 调度下发 query_job_status，data 为 ActionKey，task_id 为根任务 uuid
+2. edge 通过 job_status 回复：动作已结束回复最终状态和结果，仍在执行回复 running，不认识该 job 不回复
+3. 查询窗口内没有回复时重新查询；edge 回复 running 后按节点超时等待最终结果
+4. 到节点超时仍未收到任何回复，动作是否执行未知，节点按状态未知失败，不自动重新下发
+*/
+
+func (d *dagEngine) restoreJobs(ctx context.Context) error {
+	if d.job.Action != engine.ResumeJob {
+		return nil
+	}
+
+	jobs := make([]*model.WorkflowNodeJob, 0, len(d.nodes))
+	if err := d.workflowStore.FindDatas(ctx, &jobs, map[string]any{
+		"workflow_task_id": d.job.TaskID,
+		"parent_job_id":    0,
+	}); err != nil {
+		return err
+	}
+
+	nodeMap := utils.Slice2Map(d.nodes, func(node *model.WorkflowNode) (int64, *model.WorkflowNode) {
+		return node.ID, node
+	})
+
+	finishedNodes := make([]*model.WorkflowNode, 0, len(jobs))
+	for _, job := range jobs {
+		node, ok := nodeMap[job.NodeID]
+		if !ok {
+			// 节点已被删除或禁用
+			continue
+		}
+
+		switch job.Status {
+		case model.WorkflowJobSuccess, model.WorkflowJobSkipped:
+			finishedNodes = append(finishedNodes, node)
+		case model.WorkflowJobPending, model.WorkflowJobRunning:
+		case model.WorkflowJobFailed, model.WorkflowJobTimeout:
+			// 按失败策略继续运行的失败节点视为完成
+			if d.nodeFailurePolicy(node) == model.FailureFailFast {
+				return code.JobRunFailErr.WithMsgf("node id: %d, job status: %s", job.NodeID, job.Status)
+			}
+			d.partial.Store(true)
+			finishedNodes = append(finishedNodes, node)
+		default:
+			// 中断前已有节点失败，任务无法继续
+			return code.JobRunFailErr.WithMsgf("node id: %d, job status: %s", job.NodeID, job.Status)
+		}
+
+		d.setJob(job)
+	}
+
+	d.removeDependencies(finishedNodes)
+	logger.Infof(ctx, "dag restore task uuid: %s, finished nodes: %d, remain nodes: %d",
+		d.job.TaskUUID, len(finishedNodes), len(d.dependencies))
+
+	return nil
+}
+
+// 在途 job 不重复下发，向 edge 查询状态并等待回调，没有回复时按查询窗口重新查询
+func (d *dagEngine) resumeAction(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) error {
+	key := engine.ActionKey{
+		Type:       engine.JobCallbackStatus,
+		TaskID:     d.edgeTaskID(),
+		JobID:      job.UUID,
+		DeviceID:   *node.DeviceName,
+		ActionName: node.ActionName,
+	}
+
+	// 回调都发往根任务，由根任务记录 edge 是否已回复
+	root := d.rootEngine()
+	answered := &atomic.Bool{}
+	root.resuming.Store(job.UUID, answered)
+	defer root.resuming.Delete(job.UUID)
+
+	deadline := time.Now().Add(d.nodeTimeout(node))
+	queryTimeout := time.Duration(config.Global().Job.QueryTimeoutSecond) * time.Second
+	for {
+		window := time.Now().Add(queryTimeout)
+		if answered.Load() || window.After(deadline) {
+			window = deadline
+		}
+
+		d.InitDeviceActionStatus(ctx, key, window, false)
+		if err := d.sendQueryJob(ctx, key); err != nil {
+			return err
+		}
+
+		err := d.callbackAction(ctx, key, job)
+		if !errors.Is(err, code.JobTimeoutErr) {
+			return err
+		}
+
+		if time.Now().Before(deadline) {
+			logger.Warnf(ctx, "dag resume job no reply, query again job uuid: %s", job.UUID)
+			continue
+		}
+
+		// edge 确认在途但未在节点超时内完成
+		if answered.Load() {
+			return err
+		}
+
+		return code.JobStatusUnknownErr.WithMsgf("job uuid: %s, no reply to query_job_status", job.UUID)
+	}
+}
+
+func (d *dagEngine) sendQueryJob(_ context.Context, key engine.ActionKey) error {
+	if d.session.IsClosed() {
+		return code.EdgeConnectClosedErr
+	}
+
+	data := schedule.SendAction[engine.ActionKey]{
+		Action: schedule.QueryJobStatus,
+		Data: engine.ActionKey{
+			TaskID:     key.TaskID,
+			JobID:      key.JobID,
+			DeviceID:   key.DeviceID,
+			ActionName: key.ActionName,
+		},
+	}
+
+	bData, _ := json.Marshal(data)
+	return d.session.Write(bData)
+}
@@ -0,0 +1,144 @@
+package dag
+
+import (
+	"context"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+// 从失败处重跑：复制源任务中成功节点的结果，只运行重跑起点及其所有下游节点
+
+func (d *dagEngine) copySourceJobs(ctx context.Context) error {
+	// 中断恢复时已复制的 job 由 restoreJobs 加载
+	if d.sourceTaskID == 0 || d.job.Action == engine.ResumeJob {
+		return nil
+	}
+
+	sourceJobs := make([]*model.WorkflowNodeJob, 0, len(d.nodes))
+	if err := d.workflowStore.FindDatas(ctx, &sourceJobs, map[string]any{
+		"workflow_task_id": d.sourceTaskID,
+		"parent_job_id":    0,
+	}); err != nil {
+		return err
+	}
+
+	nodeMap := utils.Slice2Map(d.nodes, func(node *model.WorkflowNode) (int64, *model.WorkflowNode) {
+		return node.ID, node
+	})
+	sourceJobMap := utils.Slice2Map(sourceJobs, func(job *model.WorkflowNodeJob) (int64, *model.WorkflowNodeJob) {
+		return job.NodeID, job
+	})
+
+	// 重跑起点：指定节点，或源任务中所有未成功完成的节点
+	startNodes := make([]*model.WorkflowNode, 0, 1)
+	if d.restartNodeID > 0 {
+		node, ok := nodeMap[d.restartNodeID]
+		if !ok {
+			return code.WorkflowNodeNotFoundErr.WithMsgf("restart node id: %d", d.restartNodeID)
+		}
+		startNodes = append(startNodes, node)
+	} else {
+		for _, node := range d.nodes {
+			if job, ok := sourceJobMap[node.ID]; ok && isFinishedJob(job) {
+				continue
+			}
+			startNodes = append(startNodes, node)
+		}
+	}
+
+	rerunNodes := d.descendants(startNodes)
+	copiedNodes := make([]*model.WorkflowNode, 0, len(sourceJobs))
+	newJobs := make([]*model.WorkflowNodeJob, 0, len(sourceJobs))
+	for _, node := range d.nodes {
+		if _, ok := rerunNodes[node.ID]; ok {
+			continue
+		}
+
+		sourceJob, ok := sourceJobMap[node.ID]
+		if !ok || !isFinishedJob(sourceJob) {
+			continue
+		}
+
+		copiedNodes = append(copiedNodes, node)
+		newJobs = append(newJobs, &model.WorkflowNodeJob{
+			LabID:          d.job.LabData.ID,
+			WorkflowTaskID: d.job.TaskID,
+			NodeID:         node.ID,
+			Status:         sourceJob.Status,
+			FeedbackData:   sourceJob.FeedbackData,
+			ReturnInfo:     sourceJob.ReturnInfo,
+			Timestamp:      sourceJob.Timestamp,
+			Attempt:        sourceJob.Attempt,
+			Attempts:       sourceJob.Attempts,
+			Inputs:         sourceJob.Inputs,
+			QueuedAt:       sourceJob.QueuedAt,
+			DispatchedAt:   sourceJob.DispatchedAt,
+			StartedAt:      sourceJob.StartedAt,
+			FinishedAt:     sourceJob.FinishedAt,
+		})
+	}
+
+	if len(newJobs) > 0 {
+		if err := d.workflowStore.CreateJobs(ctx, newJobs); err != nil {
+			return err
+		}
+	}
+
+	for _, job := range newJobs {
+		d.setJob(job)
+	}
+	d.removeDependencies(copiedNodes)
+
+	logger.Infof(ctx, "dag rerun task uuid: %s, source task id: %d, copied nodes: %d, remain nodes: %d",
+		d.job.TaskUUID, d.sourceTaskID, len(copiedNodes), len(d.dependencies))
+
+	return nil
+}
+
+func isFinishedJob(job *model.WorkflowNodeJob) bool {
+	return job.Status == model.WorkflowJobSuccess || job.Status == model.WorkflowJobSkipped
+}
+
+// 节点及其所有下游节点
+func (d *dagEngine) descendants(nodes []*model.WorkflowNode) map[int64]struct{} {
+	uuidMap := utils.Slice2Map(d.nodes, func(node *model.WorkflowNode) (uuid.UUID, *model.WorkflowNode) {
+		return node.UUID, node
+	})
+
+	children := make(map[uuid.UUID][]uuid.UUID, len(d.nodes))
+	for _, edge := range d.edges {
+		children[edge.SourceNodeUUID] = append(children[edge.SourceNodeUUID], edge.TargetNodeUUID)
+	}
+
+	result := make(map[int64]struct{}, len(nodes))
+	queue := make([]uuid.UUID, 0, len(nodes))
+	for _, node := range nodes {
+		result[node.ID] = struct{}{}
+		queue = append(queue, node.UUID)
+	}
+
+	for len(queue) > 0 {
+		nodeUUID := queue[0]
+		queue = queue[1:]
+		for _, childUUID := range children[nodeUUID] {
+			child, ok := uuidMap[childUUID]
+			if !ok {
+				continue
+			}
+
+			if _, ok := result[child.ID]; ok {
+				continue
+			}
+
+			result[child.ID] = struct{}{}
+			queue = append(queue, childUUID)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,154 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+)
+
+// 节点失败重试：按节点配置的重试策略判断是否重试及退避间隔
+
+// 未配置可重试错误码时默认重试的错误
+var defaultRetryCodes = []code.ErrCode{
+	code.JobTimeoutErr,
+	code.RPCHttpErr,
+	code.JobRunFailErr,
+}
+
+// 设备节点默认不重试超时：edge 无法单独取消仍在执行的动作，重试使用同一 job uuid，
+// 超时的动作可能仍在仪器上运行，其迟到的回调也无法与新的尝试区分
+var defaultILabRetryCodes = []code.ErrCode{
+	code.RPCHttpErr,
+	code.JobRunFailErr,
+}
+
+func errCodeOf(err error) (code.ErrCode, bool) {
+	var errCode code.ErrCode
+	if errors.As(err, &errCode) {
+		return errCode, true
+	}
+
+	var errWithMsg code.ErrCodeWithMsg
+	if errors.As(err, &errWithMsg) {
+		return errWithMsg.ErrCode, true
+	}
+
+	return 0, false
+}
+
+func shouldRetry(node *model.WorkflowNode, policy model.RetryPolicy, attempt int, err error) bool {
+	if err == nil || attempt >= policy.MaxAttempts {
+		return false
+	}
+
+	errCode, ok := errCodeOf(err)
+	if !ok {
+		return false
+	}
+
+	if len(policy.RetryOn) == 0 {
+		if node.Type == model.WorkflowNodeILab {
+			return slices.Contains(defaultILabRetryCodes, errCode)
+		}
+		return slices.Contains(defaultRetryCodes, errCode)
+	}
+
+	return slices.Contains(policy.RetryOn, errCode.Int())
+}
+
+// 第 attempt 次失败后的等待间隔
+func retryBackoff(policy model.RetryPolicy, attempt int) time.Duration {
+	factor := math.Max(policy.BackoffFactor, 1)
+	second := policy.BackoffSecond * math.Pow(factor, float64(attempt-1))
+	if policy.MaxBackoffSecond > 0 {
+		second = math.Min(second, policy.MaxBackoffSecond)
+	}
+
+	return time.Duration(second * float64(time.Second))
+}
+
+func attemptStatus(err error) model.WorkflowJobStatus {
+	switch {
+	case err == nil:
+		return model.WorkflowJobSuccess
+	case errors.Is(err, code.JobCanceled):
+		return model.WorkflowJobCanceled
+	case errors.Is(err, code.JobTimeoutErr):
+		return model.WorkflowJobTimeout
+	default:
+		return model.WorkflowJobFailed
+	}
+}
+
+// 下发本次尝试，记录 running 状态及尝试次数
+func (d *dagEngine) startAttempt(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) {
+	now := time.Now()
+	d.setJobStatus(job, model.WorkflowJobRunning)
+	job.UpdatedAt = now
+	// 记录本次下发的输入，设备节点为 action args，其他节点为解析后的参数
+	job.Inputs = node.Param
+	job.DispatchedAt = &now
+	keys := []string{"status", "attempt", "inputs", "dispatched_at", "updated_at"}
+	// 设备节点以 edge 上报 running 为开始时间
+	if node.Type != model.WorkflowNodeILab {
+		job.StartedAt = &now
+		keys = append(keys, "started_at")
+	}
+	if err := d.workflowStore.UpdateData(context.Background(), job, map[string]any{
+		"id": job.ID,
+	}, keys...); err != nil {
+		logger.Errorf(ctx, "engine dag startAttempt job id: %d, err: %+v", job.ID, err)
+	}
+}
+
+// 记录本次尝试结果
+func (d *dagEngine) recordAttempt(ctx context.Context, job *model.WorkflowNodeJob, start time.Time, err error) {
+	attempt := model.JobAttempt{
+		Attempt:   job.Attempt,
+		Status:    attemptStatus(err),
+		StartTime: start,
+		EndTime:   time.Now(),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	job.Attempts = append(job.Attempts, attempt)
+	job.UpdatedAt = time.Now()
+	if err := d.workflowStore.UpdateData(context.Background(), job, map[string]any{
+		"id": job.ID,
+	}, "attempt", "attempts", "updated_at"); err != nil {
+		logger.Errorf(ctx, "engine dag recordAttempt job id: %d, err: %+v", job.ID, err)
+	}
+}
+
+// 等待退避间隔后重试，任务被取消时返回错误
+func (d *dagEngine) waitRetry(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob, err error) error {
+	delay := retryBackoff(node.RetryPolicy.Data(), job.Attempt)
+	d.boardMsg(ctx, &engine.BoardMsg{
+		TaskStatus: "running",
+		JobStatus:  "running",
+		Header:     node.ActionName,
+		NodeUUID:   node.UUID,
+		Type:       "warning",
+		Msg:        fmt.Sprintf("attempt %d failed, retry after %s", job.Attempt, delay),
+		StackTrace: []string{err.Error()},
+		Attempt:    job.Attempt,
+		Timestamp:  time.Now(),
+	})
+
+	select {
+	case <-ctx.Done():
+		return code.JobCanceled
+	case <-time.After(delay):
+		return nil
+	}
+}
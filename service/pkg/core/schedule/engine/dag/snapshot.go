@@ -0,0 +1,158 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+	"gorm.io/datatypes"
+)
+
+// 读取任务已保存的快照，没有快照时返回空
+func (d *dagEngine) loadSnapshot(ctx context.Context, taskID int64) (*model.WorkflowSnapshot, error) {
+	if taskID == 0 {
+		return nil, nil
+	}
+
+	task := &model.WorkflowTask{}
+	if err := d.workflowStore.GetData(ctx, task, map[string]any{
+		"id": taskID,
+	}, "id", "snapshot"); err != nil {
+		return nil, err
+	}
+
+	if len(task.Snapshot) == 0 || string(task.Snapshot) == "null" {
+		return nil, nil
+	}
+
+	snapshot := &model.WorkflowSnapshot{}
+	if err := json.Unmarshal(task.Snapshot, snapshot); err != nil {
+		logger.Errorf(ctx, "engine dag loadSnapshot task id: %d, err: %+v", taskID, err)
+		return nil, code.WorkflowSnapshotErr.WithErr(err)
+	}
+
+	return snapshot, nil
+}
+
+// 从失败处重跑使用源任务的快照，复用的结果与重跑的节点来自同一个图
+// 快照功能上线前的源任务没有快照，退回按工作流当前数据构建
+func (d *dagEngine) sourceSnapshot(ctx context.Context) (*model.WorkflowSnapshot, error) {
+	snapshot, err := d.loadSnapshot(ctx, d.sourceTaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshot == nil {
+		logger.Warnf(ctx, "engine dag source task id: %d has no snapshot, build from current workflow", d.sourceTaskID)
+		return d.buildSnapshot(ctx)
+	}
+
+	return snapshot, nil
+}
+
+// 从工作流当前数据构建快照，节点参数已写入运行参数
+func (d *dagEngine) buildSnapshot(ctx context.Context) (*model.WorkflowSnapshot, error) {
+	// 获取工作流
+	wk, err := d.workflowStore.GetWorkflowByUUID(ctx, d.job.WorkflowUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 子工作流引用不能成环且不能跨实验室，根任务统一检查
+	if d.root == nil {
+		if err := d.checkSubflowCycle(ctx, wk.LabID, wk.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 加载所有工作流节点数据
+	allNodes, err := d.workflowStore.GetWorkflowNodes(ctx, map[string]any{
+		"workflow_id": wk.ID,
+		"type": []model.WorkflowNodeType{
+			model.WorkflowNodeILab,
+			model.WorkflowPyScript,
+			model.WorkflowBranch,
+			model.WorkflowMap,
+			model.WorkflowSubflow,
+			model.WorkflowManual,
+			model.WorkflowTimer,
+			model.WorkflowWait,
+			model.WorkflowNodeGroup,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 运行参数覆盖节点参数
+	if err := d.applyParams(wk, allNodes); err != nil {
+		return nil, err
+	}
+
+	// 节点UUID查询边，禁用的节点不参与运行
+	nodeUUIDs := utils.FilterSlice(allNodes, func(node *model.WorkflowNode) (uuid.UUID, bool) {
+		return node.UUID, node.Type != model.WorkflowNodeGroup && !node.Disabled
+	})
+
+	edges, err := d.workflowStore.GetWorkflowEdges(ctx, nodeUUIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	edgeHandleUUIDs := make([]uuid.UUID, 0, 2*len(edges))
+	utils.Range(edges, func(_ int, e *model.WorkflowEdge) bool {
+		edgeHandleUUIDs = utils.AppendUniqSlice(edgeHandleUUIDs, e.SourceHandleUUID)
+		edgeHandleUUIDs = utils.AppendUniqSlice(edgeHandleUUIDs, e.TargetHandleUUID)
+		return true
+	})
+
+	handleTpls := make([]*model.WorkflowHandleTemplate, 0, len(edgeHandleUUIDs))
+	if err := d.workflowStore.FindDatas(ctx, &handleTpls, map[string]any{
+		"uuid": edgeHandleUUIDs,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &model.WorkflowSnapshot{
+		WorkflowUUID:  wk.UUID,
+		Name:          wk.Name,
+		TimeoutSecond: wk.TimeoutSecond,
+		FailurePolicy: wk.FailurePolicy,
+		Params:        wk.Params,
+		Nodes:         allNodes,
+		Edges:         edges,
+		Handles:       handleTpls,
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+// 保存任务快照，恢复和查询时以快照为准
+func (d *dagEngine) saveSnapshot(ctx context.Context, snapshot *model.WorkflowSnapshot) error {
+	// 运行前校验不保存
+	if d.job.TaskID == 0 || d.diagnostics != nil {
+		return nil
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return code.WorkflowSnapshotErr.WithErr(err)
+	}
+
+	task := &model.WorkflowTask{
+		Snapshot: datatypes.JSON(data),
+	}
+	task.UpdatedAt = time.Now()
+	if err := d.workflowStore.UpdateData(ctx, task, map[string]any{
+		"id": d.job.TaskID,
+	}, "snapshot", "updated_at"); err != nil {
+		logger.Errorf(ctx, "engine dag saveSnapshot task id: %d, err: %+v", d.job.TaskID, err)
+		return err
+	}
+
+	return nil
+}
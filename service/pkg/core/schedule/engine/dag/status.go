@@ -0,0 +1,71 @@
+package dag
+
+import (
+	"context"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/model"
+)
+
+// 运行时状态查询：从内存读取节点调度情况和等待 edge 回复的动作，map 和子工作流的动作也在动作列表中
+
+func (d *dagEngine) GetStatus(_ context.Context) (*engine.TaskStatus, error) {
+	status := &engine.TaskStatus{
+		Running:      true,
+		Paused:       d.pause.wait() != nil,
+		ReadyNodes:   make([]*engine.NodeStatus, 0, 1),
+		RunningNodes: make([]*engine.NodeStatus, 0, 1),
+		WaitingNodes: make([]*engine.NodeStatus, 0, 1),
+		Actions:      d.actionStatus.Snapshot(),
+		Timestamp:    time.Now(),
+	}
+	if d.job != nil {
+		status.TaskUUID = d.job.TaskUUID
+	}
+
+	d.depLock.RLock()
+	for node, nodeDependences := range d.dependencies {
+		nodeStatus := d.nodeStatus(node)
+		nodeStatus.PendingParents = len(nodeDependences)
+		if nodeStatus.PendingParents == 0 {
+			status.ReadyNodes = append(status.ReadyNodes, nodeStatus)
+		} else {
+			status.WaitingNodes = append(status.WaitingNodes, nodeStatus)
+		}
+	}
+	d.depLock.RUnlock()
+
+	for _, node := range d.nodes {
+		job, ok := d.nodeJobState(node.ID)
+		if !ok {
+			continue
+		}
+
+		switch job.Status {
+		case model.WorkflowJobPending, model.WorkflowJobRunning:
+			status.RunningNodes = append(status.RunningNodes, d.nodeStatus(node))
+		default:
+		}
+	}
+
+	return status, nil
+}
+
+func (d *dagEngine) nodeStatus(node *model.WorkflowNode) *engine.NodeStatus {
+	status := &engine.NodeStatus{
+		NodeUUID:    node.UUID,
+		Name:        node.Name,
+		ActionName:  node.ActionName,
+		Status:      model.WorkflowJobPending,
+		MaxAttempts: node.RetryPolicy.Data().MaxAttempts,
+	}
+
+	if job, ok := d.nodeJobState(node.ID); ok {
+		status.JobUUID = job.UUID
+		status.Status = job.Status
+		status.Attempt = job.Attempt
+	}
+
+	return status
+}
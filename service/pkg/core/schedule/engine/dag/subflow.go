@@ -0,0 +1,350 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"gorm.io/datatypes"
+)
+
+// 子工作流节点：引用另一个已保存的工作流，作为父任务的子任务内联运行
+//
+// 节点 param 示例，上游数据通过边注入到 param 后按 input_mapping 写入子工作流节点:
+// {"workflow_uuid": "...", "volume": 10,
+//  "input_mapping": [{"source": "volume", "node_uuid": "...", "target": "volume"}],
+//  "output_mapping": [{"node_uuid": "...", "source": "weight", "target": "weight"}]}
+
+type subflowMapping struct {
+	NodeUUID uuid.UUID `json:"node_uuid"` // 子工作流节点 uuid
+	Source   string    `json:"source"`    // 取值的 gjson 路径
+	Target   string    `json:"target"`    // 写入的 sjson 路径
+}
+
+type subflowParam struct {
+	WorkflowUUID  uuid.UUID         `json:"workflow_uuid"`
+	InputMapping  []*subflowMapping `json:"input_mapping"`  // 子工作流节点输入，source 为本节点 param 路径
+	OutputMapping []*subflowMapping `json:"output_mapping"` // 本节点输出，source 为子工作流节点返回值路径，为空输出所有叶子节点结果
+}
+
+func parseSubflowParam(node *model.WorkflowNode) (*subflowParam, error) {
+	param := &subflowParam{}
+	if err := json.Unmarshal(node.Param, param); err != nil {
+		return nil, code.WorkflowNodeSubflowConfigErr.WithErr(err)
+	}
+
+	if param.WorkflowUUID.IsNil() {
+		return nil, code.WorkflowNodeSubflowConfigErr.WithMsgf("node id: %d, empty workflow uuid", node.ID)
+	}
+
+	for _, m := range param.InputMapping {
+		if m.NodeUUID.IsNil() || m.Target == "" {
+			return nil, code.WorkflowNodeSubflowConfigErr.WithMsgf("node id: %d, invalid input mapping", node.ID)
+		}
+	}
+
+	for _, m := range param.OutputMapping {
+		if m.NodeUUID.IsNil() || m.Target == "" {
+			return nil, code.WorkflowNodeSubflowConfigErr.WithMsgf("node id: %d, invalid output mapping", node.ID)
+		}
+	}
+
+	return param, nil
+}
+
+// 子工作流只能引用同一实验室的工作流
+func (d *dagEngine) subflowWorkflowID(ctx context.Context, labID int64, workflowUUID uuid.UUID) (int64, error) {
+	wk := &model.Workflow{}
+	if err := d.workflowStore.GetData(ctx, wk, map[string]any{
+		"uuid":   workflowUUID,
+		"lab_id": labID,
+	}, "id"); err != nil {
+		if errors.Is(err, code.RecordNotFound) {
+			return 0, code.WorkflowSubflowLabErr.WithMsgf("sub workflow uuid: %s", workflowUUID)
+		}
+		return 0, err
+	}
+
+	return wk.ID, nil
+}
+
+// 沿子工作流引用做 DFS，检测跨工作流的循环引用和跨实验室引用
+func (d *dagEngine) checkSubflowCycle(ctx context.Context, labID, workflowID int64) error {
+	return d.dfsSubflowCycle(ctx, labID, workflowID, make(map[int64]bool), make(map[int64]bool))
+}
+
+func (d *dagEngine) dfsSubflowCycle(ctx context.Context, labID, workflowID int64, visited, recStack map[int64]bool) error {
+	if recStack[workflowID] {
+		return code.WorkflowHasCircularErr.WithMsgf("sub workflow reference cycle, workflow id: %d", workflowID)
+	}
+
+	if visited[workflowID] {
+		return nil
+	}
+
+	visited[workflowID] = true
+	recStack[workflowID] = true
+
+	nodes, err := d.workflowStore.GetWorkflowNodes(ctx, map[string]any{
+		"workflow_id": workflowID,
+		"type":        model.WorkflowSubflow,
+		"disabled":    false,
+	}, "id", "param")
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		param, err := parseSubflowParam(node)
+		if err != nil {
+			return err
+		}
+
+		childID, err := d.subflowWorkflowID(ctx, labID, param.WorkflowUUID)
+		if err != nil {
+			return err
+		}
+
+		if err := d.dfsSubflowCycle(ctx, labID, childID, visited, recStack); err != nil {
+			return err
+		}
+	}
+
+	recStack[workflowID] = false
+	return nil
+}
+
+func (d *dagEngine) execSubflow(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob) error {
+	param, err := parseSubflowParam(node)
+	if err != nil {
+		return err
+	}
+
+	childTask, err := d.prepareSubflowTask(ctx, param, job)
+	if err != nil {
+		return err
+	}
+
+	child := d.newChildEngine(node, param)
+	defer child.pools.Release()
+
+	info := &engine.WorkflowInfo{
+		Action:       engine.StartJob,
+		TaskUUID:     childTask.UUID,
+		WorkflowUUID: param.WorkflowUUID,
+		LabUUID:      d.job.LabUUID,
+		UserID:       d.job.UserID,
+		LabData:      d.job.LabData,
+	}
+
+	switch childTask.Status {
+	case model.WorkflowTaskStatusSuccessed:
+		// 子任务已完成，只加载数据计算输出
+		child.job = info
+		info.TaskID = childTask.ID
+		if err := child.loadData(ctx); err != nil {
+			return err
+		}
+	case model.WorkflowTaskStatusRunnig:
+		info.Action = engine.ResumeJob
+		fallthrough
+	default:
+		logger.Infof(ctx, "dag run sub workflow node id: %d, sub task uuid: %s", node.ID, childTask.UUID)
+		if err := child.Run(ctx, info); err != nil {
+			return err
+		}
+	}
+
+	output, err := child.subflowOutput(ctx, param)
+	if err != nil {
+		return err
+	}
+
+	d.setJobStatus(job, model.WorkflowJobSuccess)
+	job.ReturnInfo = datatypes.NewJSONType(model.ReturnInfo{
+		Suc:         true,
+		ReturnValue: output,
+	})
+	job.UpdatedAt = time.Now()
+
+	return d.workflowStore.UpdateData(ctx, job, map[string]any{
+		"id": job.ID,
+	}, "status", "return_info", "updated_at")
+}
+
+// 获取可复用的子任务，不存在或已失败则创建新的子任务
+func (d *dagEngine) prepareSubflowTask(ctx context.Context, param *subflowParam, job *model.WorkflowNodeJob) (*model.WorkflowTask, error) {
+	tasks := make([]*model.WorkflowTask, 0, 1)
+	if err := d.workflowStore.FindDatas(ctx, &tasks, map[string]any{
+		"parent_job_id": job.ID,
+	}, "id", "uuid", "status"); err != nil {
+		return nil, err
+	}
+
+	var latest *model.WorkflowTask
+	for _, t := range tasks {
+		if latest == nil || t.ID > latest.ID {
+			latest = t
+		}
+	}
+
+	if latest != nil && (latest.Status == model.WorkflowTaskStatusRunnig ||
+		latest.Status == model.WorkflowTaskStatusSuccessed) {
+		return latest, nil
+	}
+
+	workflowID, err := d.subflowWorkflowID(ctx, d.job.LabData.ID, param.WorkflowUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	task := &model.WorkflowTask{
+		LabID:        d.job.LabData.ID,
+		WorkflowID:   workflowID,
+		UserID:       d.job.UserID,
+		Status:       model.WorkflowTaskStatusPending,
+		ParentTaskID: d.job.TaskID,
+		ParentJobID:  job.ID,
+		Simulated:    d.simulated,
+	}
+	if err := d.workflowStore.CreateWorkflowTask(ctx, task); err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (d *dagEngine) newChildEngine(node *model.WorkflowNode, param *subflowParam) *dagEngine {
+	child := NewDagTask(d.ctx, &engine.TaskParam{
+		Session: d.session,
+		Cancle:  d.cancel,
+		Sandbox: d.sandbox,
+	}).(*dagEngine)
+
+	child.actionStatus = d.actionStatus
+	child.stopped = d.stopped
+	child.pause = d.pause
+	child.manual = d.manual
+	child.waits = d.waits
+	child.root = d.rootEngine()
+	child.parent = d
+	child.subflowNode = node
+	child.stepFuncs = []stepFunc{
+		child.checkTaskStatus, // 检查任务状态
+		child.loadData,        // 加载运行数据
+		func(ctx context.Context) error { // 写入子工作流输入
+			return child.applySubflowInputs(node, param)
+		},
+		child.buildTask,   // 构建任务
+		child.restoreJobs, // 恢复中断任务的运行状态
+		child.runAllNodes, // 运行任务
+	}
+
+	return child
+}
+
+func (d *dagEngine) rootEngine() *dagEngine {
+	if d.root != nil {
+		return d.root
+	}
+
+	return d
+}
+
+func (d *dagEngine) applySubflowInputs(node *model.WorkflowNode, param *subflowParam) error {
+	nodeMap := utils.Slice2Map(d.nodes, func(n *model.WorkflowNode) (uuid.UUID, *model.WorkflowNode) {
+		return n.UUID, n
+	})
+
+	for _, m := range param.InputMapping {
+		target, ok := nodeMap[m.NodeUUID]
+		if !ok {
+			return code.WorkflowNodeNotFoundErr.WithMsgf("sub workflow node uuid: %s", m.NodeUUID)
+		}
+
+		value := gjson.GetBytes(node.Param, m.Source)
+		if !value.Exists() {
+			return code.ValueNotExistErr.WithMsgf("sub workflow input source: %s", m.Source)
+		}
+
+		nodeParam, err := sjson.SetBytes(target.Param, m.Target, value.Value())
+		if err != nil {
+			return code.UpdateNodeErr.WithErr(err)
+		}
+		target.Param = datatypes.JSON(nodeParam)
+	}
+
+	return nil
+}
+
+// 根据子任务各节点的返回值计算子工作流节点的输出
+func (d *dagEngine) subflowOutput(ctx context.Context, param *subflowParam) (any, error) {
+	jobs := make([]*model.WorkflowNodeJob, 0, len(d.nodes))
+	if err := d.workflowStore.FindDatas(ctx, &jobs, map[string]any{
+		"workflow_task_id": d.job.TaskID,
+		"parent_job_id":    0,
+	}, "node_id", "status", "return_info"); err != nil {
+		return nil, err
+	}
+
+	jobMap := utils.Slice2Map(jobs, func(j *model.WorkflowNodeJob) (int64, *model.WorkflowNodeJob) {
+		return j.NodeID, j
+	})
+
+	if len(param.OutputMapping) > 0 {
+		nodeIDs := utils.Slice2Map(d.nodes, func(n *model.WorkflowNode) (uuid.UUID, int64) {
+			return n.UUID, n.ID
+		})
+
+		output := "{}"
+		for _, m := range param.OutputMapping {
+			job, ok := jobMap[nodeIDs[m.NodeUUID]]
+			if !ok {
+				return nil, code.CanNotGetParentJobErr.WithMsgf("sub workflow node uuid: %s", m.NodeUUID)
+			}
+
+			retValueB, err := json.Marshal(job.ReturnInfo.Data().ReturnValue)
+			if err != nil {
+				return nil, code.DataNotMapAnyTypeErr
+			}
+
+			value := gjson.ParseBytes(retValueB)
+			if m.Source != "" {
+				value = gjson.GetBytes(retValueB, m.Source)
+			}
+
+			if output, err = sjson.Set(output, m.Target, value.Value()); err != nil {
+				return nil, code.UpdateNodeErr.WithErr(err)
+			}
+		}
+
+		return gjson.Parse(output).Value(), nil
+	}
+
+	// 未配置输出映射时返回所有叶子节点的结果
+	sourceUUIDs := utils.Slice2Map(d.edges, func(e *model.WorkflowEdge) (uuid.UUID, struct{}) {
+		return e.SourceNodeUUID, struct{}{}
+	})
+
+	results := make(map[string]any)
+	for _, n := range d.nodes {
+		if _, ok := sourceUUIDs[n.UUID]; ok {
+			continue
+		}
+
+		if job, ok := jobMap[n.ID]; ok && job.Status == model.WorkflowJobSuccess {
+			results[n.UUID.String()] = job.ReturnInfo.Data().ReturnValue
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,150 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/scienceol/studio/service/internal/config"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+// 超时控制：节点超时优先级为 节点配置 > 模板配置 > 全局默认值
+
+const (
+	taskBudgetTick = time.Second      // 任务运行时间的计时间隔
+	taskBudgetSave = 10 * time.Second // 已运行时间的保存间隔
+)
+
+func (d *dagEngine) loadNodeTimeouts(ctx context.Context, nodes []*model.WorkflowNode) error {
+	tplIDs := utils.FilterUniqSlice(nodes, func(node *model.WorkflowNode) (int64, bool) {
+		return node.WorkflowNodeID, node.TimeoutSecond <= 0 && node.WorkflowNodeID > 0
+	})
+
+	tplTimeouts := make(map[int64]int)
+	if len(tplIDs) > 0 {
+		tpls := make([]*model.WorkflowNodeTemplate, 0, len(tplIDs))
+		if err := d.workflowStore.FindDatas(ctx, &tpls, map[string]any{
+			"id": tplIDs,
+		}, "id", "timeout_second"); err != nil {
+			return err
+		}
+
+		tplTimeouts = utils.Slice2Map(tpls, func(tpl *model.WorkflowNodeTemplate) (int64, int) {
+			return tpl.ID, tpl.TimeoutSecond
+		})
+	}
+
+	for _, node := range nodes {
+		second := node.TimeoutSecond
+		if second <= 0 {
+			second = tplTimeouts[node.WorkflowNodeID]
+		}
+
+		if second > 0 {
+			d.nodeTimeouts[node.ID] = time.Duration(second) * time.Second
+		}
+	}
+
+	return nil
+}
+
+func (d *dagEngine) nodeTimeout(node *model.WorkflowNode) time.Duration {
+	if timeout, ok := d.nodeTimeouts[node.ID]; ok {
+		return timeout
+	}
+
+	return time.Duration(config.Global().Job.ActionTimeoutSecond) * time.Second
+}
+
+// 任务整体超时只计算运行时间，暂停期间不计时，已运行时间写入任务，恢复后继续累计
+func (d *dagEngine) withTaskDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.taskTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	taskCtx, cancel := context.WithCancelCause(ctx)
+	utils.SafelyGo(func() {
+		d.watchTaskBudget(taskCtx, cancel)
+	}, func(err error) {
+		logger.Errorf(ctx, "engine dag watchTaskBudget SafelyGo err: %+v", err)
+	})
+
+	return taskCtx, func() { cancel(context.Canceled) }
+}
+
+func (d *dagEngine) watchTaskBudget(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(taskBudgetTick)
+	defer ticker.Stop()
+	defer d.saveRunTime(ctx)
+
+	saved := d.runTime
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if d.pause.wait() != nil {
+			continue
+		}
+
+		d.runTime += taskBudgetTick
+		if d.runTime >= d.taskTimeout {
+			cancel(context.DeadlineExceeded)
+			return
+		}
+
+		if d.runTime-saved >= taskBudgetSave {
+			d.saveRunTime(ctx)
+			saved = d.runTime
+		}
+	}
+}
+
+func (d *dagEngine) saveRunTime(ctx context.Context) {
+	if d.job.TaskID == 0 {
+		return
+	}
+
+	data := &model.WorkflowTask{
+		RunSecond: int(d.runTime / time.Second),
+	}
+	if err := d.workflowStore.UpdateData(context.Background(), data, map[string]any{
+		"id": d.job.TaskID,
+	}, "run_second"); err != nil {
+		logger.Errorf(ctx, "engine dag saveRunTime task id: %d, err: %+v", d.job.TaskID, err)
+	}
+}
+
+// 任务上下文结束的原因：整体超时需要通知 edge 取消正在运行的动作
+func (d *dagEngine) taskDoneErr(taskCtx context.Context) error {
+	if !errors.Is(context.Cause(taskCtx), context.DeadlineExceeded) {
+		return code.JobCanceled
+	}
+
+	d.sendCancelTask()
+	return code.JobTimeoutErr
+}
+
+func (d *dagEngine) sendCancelTask() {
+	if d.session.IsClosed() {
+		return
+	}
+
+	data := schedule.SendAction[*engine.CancelTask]{
+		Action: schedule.CancelTask,
+		Data: &engine.CancelTask{
+			TaskID: d.edgeTaskID(),
+		},
+	}
+	b, _ := json.Marshal(data)
+	d.session.Write(b)
+}
@@ -0,0 +1,180 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/model"
+	eStore "github.com/scienceol/studio/service/pkg/repo/environment"
+	wfl "github.com/scienceol/studio/service/pkg/repo/workflow"
+	"github.com/scienceol/studio/service/pkg/utils"
+	"github.com/tidwall/gjson"
+	"gorm.io/datatypes"
+)
+
+// 校验时收集的问题
+type diagnostics struct {
+	items []*engine.Diagnostic
+}
+
+func (g *diagnostics) add(node *model.WorkflowNode, edge *model.WorkflowEdge, err error) {
+	item := &engine.Diagnostic{
+		Code: code.UnDefineErr,
+		Msg:  err.Error(),
+	}
+
+	var errCode code.ErrCode
+	var errWithMsg code.ErrCodeWithMsg
+	if errors.As(err, &errWithMsg) {
+		item.Code = errWithMsg.ErrCode
+		item.Msg = strings.TrimSpace(errWithMsg.ErrCode.String() + ": " + errWithMsg.Msgs())
+	} else if errors.As(err, &errCode) {
+		item.Code = errCode
+		item.Msg = errCode.String()
+	}
+
+	if node != nil {
+		item.NodeUUID = node.UUID
+		item.NodeName = node.Name
+	}
+	if edge != nil {
+		item.EdgeUUID = edge.UUID
+	}
+
+	g.items = append(g.items, item)
+}
+
+// 运行前校验工作流，执行与运行时相同的加载和构建检查，返回所有发现的问题
+func Validate(ctx context.Context, workflowUUID uuid.UUID, params datatypes.JSONMap) ([]*engine.Diagnostic, error) {
+	d := &dagEngine{
+		job:             &engine.WorkflowInfo{WorkflowUUID: workflowUUID},
+		envStore:        eStore.New(),
+		workflowStore:   wfl.New(),
+		dependencies:    make(map[*model.WorkflowNode]map[*model.WorkflowNode]struct{}),
+		nodeParentEdges: make(map[int64][]*engine.HandlePair),
+		nodeTimeouts:    make(map[int64]time.Duration),
+		mapChildren:     make(map[int64][]*model.WorkflowNode),
+		mapEdges:        make(map[int64][]*model.WorkflowEdge),
+		params:          params,
+		diagnostics:     &diagnostics{},
+	}
+
+	// 加载失败时无法继续检查
+	if err := d.loadData(ctx); err != nil {
+		d.diagnostics.add(nil, nil, err)
+		return d.diagnostics.items, nil
+	}
+
+	if err := d.buildTask(ctx); err != nil {
+		d.diagnostics.add(nil, nil, err)
+	}
+
+	nodes, edges := d.nodes, d.edges
+	for mapID, children := range d.mapChildren {
+		nodes = append(nodes, children...)
+		edges = append(edges, d.mapEdges[mapID]...)
+	}
+
+	d.checkEdgeHandles(nodes, edges)
+	if err := d.checkRequiredGoals(ctx, nodes, edges); err != nil {
+		return nil, err
+	}
+
+	return d.diagnostics.items, nil
+}
+
+// 检查已有边两端的句柄类型
+func (d *dagEngine) checkEdgeHandles(nodes []*model.WorkflowNode, edges []*model.WorkflowEdge) {
+	nodeMap := utils.Slice2Map(nodes, func(node *model.WorkflowNode) (uuid.UUID, *model.WorkflowNode) {
+		return node.UUID, node
+	})
+	handleMap := utils.Slice2Map(d.handles, func(h *model.WorkflowHandleTemplate) (uuid.UUID, *model.WorkflowHandleTemplate) {
+		return h.UUID, h
+	})
+
+	for _, e := range edges {
+		source, sok := handleMap[e.SourceHandleUUID]
+		target, tok := handleMap[e.TargetHandleUUID]
+		// 句柄不存在在构建时已记录
+		if !sok || !tok {
+			continue
+		}
+
+		if err := engine.CheckHandleType(source, target, e.Expression); err != nil {
+			d.diagnostics.add(nodeMap[e.TargetNodeUUID], e, err)
+		}
+	}
+}
+
+// 检查设备节点的必填 goal 字段，参数中没有值且没有入边写入时记录问题
+func (d *dagEngine) checkRequiredGoals(ctx context.Context, nodes []*model.WorkflowNode, edges []*model.WorkflowEdge) error {
+	ilabNodes := utils.FilterSlice(nodes, func(node *model.WorkflowNode) (*model.WorkflowNode, bool) {
+		return node, node.Type == model.WorkflowNodeILab && node.WorkflowNodeID > 0
+	})
+	if len(ilabNodes) == 0 {
+		return nil
+	}
+
+	tplIDs := utils.FilterUniqSlice(ilabNodes, func(node *model.WorkflowNode) (int64, bool) {
+		return node.WorkflowNodeID, true
+	})
+	tpls := make([]*model.WorkflowNodeTemplate, 0, len(tplIDs))
+	if err := d.workflowStore.FindDatas(ctx, &tpls, map[string]any{
+		"id": tplIDs,
+	}, "id", "schema"); err != nil {
+		return err
+	}
+	tplMap := utils.Slice2Map(tpls, func(tpl *model.WorkflowNodeTemplate) (int64, *model.WorkflowNodeTemplate) {
+		return tpl.ID, tpl
+	})
+
+	handleMap := utils.Slice2Map(d.handles, func(h *model.WorkflowHandleTemplate) (uuid.UUID, *model.WorkflowHandleTemplate) {
+		return h.UUID, h
+	})
+
+	// 节点由入边写入的参数 key
+	mappedKeys := make(map[uuid.UUID][]string)
+	for _, e := range edges {
+		if h, ok := handleMap[e.TargetHandleUUID]; ok && h.DataKey != "" {
+			mappedKeys[e.TargetNodeUUID] = append(mappedKeys[e.TargetNodeUUID], h.DataKey)
+		}
+	}
+
+	for _, node := range ilabNodes {
+		tpl, ok := tplMap[node.WorkflowNodeID]
+		if !ok {
+			continue
+		}
+
+		for _, field := range gjson.GetBytes(tpl.Schema, "properties.goal.required").Array() {
+			key := field.String()
+			if isGoalMapped(key, mappedKeys[node.UUID]) {
+				continue
+			}
+
+			value := gjson.GetBytes(node.Param, key)
+			if !value.Exists() || value.Type == gjson.Null ||
+				(value.Type == gjson.String && value.String() == "") {
+				d.diagnostics.add(node, nil, code.WorkflowGoalUnmappedErr.WithMsgf("field: %s", key))
+			}
+		}
+	}
+
+	return nil
+}
+
+// 入边写入该字段或其子字段时视为已映射
+func isGoalMapped(key string, dataKeys []string) bool {
+	for _, dataKey := range dataKeys {
+		if dataKey == key || strings.HasPrefix(dataKey, key+".") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,310 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"gorm.io/datatypes"
+)
+
+// 延时节点和条件等待节点：等待期间不占用协程池，由定时器、任务取消和物料数据更新回调完成节点
+//
+// 延时节点 param 示例: {"duration_second": 1800}
+// 条件等待节点 param 示例，所有条件满足时继续，超时失败:
+// {"device_name": "incubator", "conditions": [{"path": "temperature", "operator": "gte", "value": 37}], "timeout_second": 3600}
+
+type timerParam struct {
+	DurationSecond float64 `json:"duration_second"`
+}
+
+type waitCondition struct {
+	Path     string `json:"path"`     // 物料数据的 gjson 路径
+	Operator string `json:"operator"` // 同分支节点
+	Value    any    `json:"value"`
+}
+
+type waitParam struct {
+	DeviceName    string           `json:"device_name"`
+	Conditions    []*waitCondition `json:"conditions"`
+	TimeoutSecond float64          `json:"timeout_second"` // 0 使用节点超时配置
+}
+
+// 等待物料数据的节点，根任务和子图共享
+type waitGate struct {
+	lock  sync.Mutex
+	waits map[*nodeWait]struct{}
+}
+
+type nodeWait struct {
+	param   *waitParam // 延时节点为空
+	removed bool       // 已完成，由 waitGate 的锁保护
+
+	lock    sync.Mutex // 保护 timer 和 stopCtx 的初始化
+	timer   *time.Timer
+	stopCtx func() bool
+	once    sync.Once
+	finish  func(err error, returnValue any)
+}
+
+func isWaitNode(node *model.WorkflowNode) bool {
+	return node.Type == model.WorkflowTimer || node.Type == model.WorkflowWait
+}
+
+func parseTimerParam(node *model.WorkflowNode) (*timerParam, error) {
+	param := &timerParam{}
+	if err := json.Unmarshal(node.Param, param); err != nil {
+		return nil, code.WorkflowNodeTimerConfigErr.WithErr(err)
+	}
+
+	if param.DurationSecond <= 0 {
+		return nil, code.WorkflowNodeTimerConfigErr.WithMsgf("node id: %d, invalid duration second", node.ID)
+	}
+
+	return param, nil
+}
+
+func parseWaitParam(node *model.WorkflowNode) (*waitParam, error) {
+	param := &waitParam{}
+	if err := json.Unmarshal(node.Param, param); err != nil {
+		return nil, code.WorkflowNodeWaitConfigErr.WithErr(err)
+	}
+
+	if param.DeviceName == "" {
+		return nil, code.WorkflowNodeWaitConfigErr.WithMsgf("node id: %d, empty device name", node.ID)
+	}
+
+	if len(param.Conditions) == 0 {
+		return nil, code.WorkflowNodeWaitConfigErr.WithMsgf("node id: %d, empty conditions", node.ID)
+	}
+
+	for _, c := range param.Conditions {
+		if c.Path == "" {
+			return nil, code.WorkflowNodeWaitConfigErr.WithMsgf("node id: %d, empty path", node.ID)
+		}
+
+		if !isBranchOperator(c.Operator) {
+			return nil, code.WorkflowNodeWaitConfigErr.WithMsgf("node id: %d, unknown operator: %s", node.ID, c.Operator)
+		}
+	}
+
+	if param.TimeoutSecond < 0 {
+		return nil, code.WorkflowNodeWaitConfigErr.WithMsgf("node id: %d, invalid timeout second", node.ID)
+	}
+
+	return param, nil
+}
+
+func (p *waitParam) match(data datatypes.JSON) bool {
+	for _, c := range p.Conditions {
+		if !matchBranchCase(data, &branchCase{
+			Path:     c.Path,
+			Operator: c.Operator,
+			Value:    c.Value,
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 下发等待节点：注册定时器和取消回调后立即返回，完成时把结果写入 resultCh
+func (d *dagEngine) startWait(ctx context.Context, node *model.WorkflowNode, job *model.WorkflowNodeJob, resultCh chan<- *nodeResult) {
+	d.wg.Add(1)
+	done := func(err error) {
+		resultCh <- &nodeResult{node: node, err: err}
+		d.wg.Done()
+	}
+
+	// 恢复任务时，已开始等待的 job 处于 running 状态
+	inflight := job.Status == model.WorkflowJobRunning
+
+	data := &engine.BoardMsg{
+		TaskStatus: "running",
+		JobStatus:  "running",
+		Header:     node.ActionName,
+		NodeUUID:   node.UUID,
+		Type:       "info",
+		Msg:        "waiting",
+	}
+	d.boardMsg(ctx, data)
+
+	// 从开始等待的时间计算截止时间，恢复的 job 使用最后更新时间
+	start := time.Now()
+	if inflight {
+		start = job.UpdatedAt
+	} else {
+		d.addJobAttempt(job)
+	}
+
+	w := &nodeWait{}
+	w.finish = func(err error, returnValue any) {
+		w.once.Do(func() {
+			w.lock.Lock()
+			if w.timer != nil {
+				w.timer.Stop()
+			}
+			if w.stopCtx != nil {
+				w.stopCtx()
+			}
+			w.lock.Unlock()
+			d.waits.remove(w)
+
+			if err == nil {
+				err = d.saveWaitResult(ctx, job, returnValue)
+			}
+
+			if !d.isInterrupted() {
+				d.recordAttempt(ctx, job, start, err)
+			}
+			d.finishNode(ctx, data, job, err)
+			done(err)
+		})
+	}
+
+	if err := d.parsePreNodeParam(ctx, node); err != nil {
+		w.finish(err, nil)
+		return
+	}
+
+	// 参数解析后再记录 running，保存解析后的输入
+	if !inflight {
+		d.startAttempt(ctx, node, job)
+	}
+
+	var timeout time.Duration
+	timeoutErr := error(code.JobTimeoutErr)
+	switch node.Type {
+	case model.WorkflowTimer:
+		param, err := parseTimerParam(node)
+		if err != nil {
+			w.finish(err, nil)
+			return
+		}
+
+		// 延时节点到期即完成
+		timeout = time.Duration(param.DurationSecond * float64(time.Second))
+		timeoutErr = nil
+	default:
+		param, err := parseWaitParam(node)
+		if err != nil {
+			w.finish(err, nil)
+			return
+		}
+
+		w.param = param
+		timeout = d.nodeTimeout(node)
+		if param.TimeoutSecond > 0 {
+			timeout = time.Duration(param.TimeoutSecond * float64(time.Second))
+		}
+	}
+
+	w.lock.Lock()
+	w.timer = time.AfterFunc(time.Until(start.Add(timeout)), func() {
+		w.finish(timeoutErr, nil)
+	})
+	w.stopCtx = context.AfterFunc(ctx, func() {
+		w.finish(code.JobCanceled, nil)
+	})
+	w.lock.Unlock()
+
+	if w.param == nil {
+		return
+	}
+
+	// 先注册再读取当前数据，避免错过两者之间的更新
+	d.waits.add(w)
+	d.checkCurrentData(ctx, w)
+}
+
+// 读取物料当前数据，已满足条件时直接完成
+func (d *dagEngine) checkCurrentData(ctx context.Context, w *nodeWait) {
+	nodes := make([]*model.MaterialNode, 0, 1)
+	if err := d.workflowStore.FindDatas(ctx, &nodes, map[string]any{
+		"lab_id": d.job.LabData.ID,
+		"name":   w.param.DeviceName,
+	}, "data"); err != nil {
+		logger.Errorf(ctx, "engine dag checkCurrentData device: %s, err: %+v", w.param.DeviceName, err)
+		return
+	}
+
+	for _, n := range nodes {
+		if w.param.match(n.Data) {
+			w.finish(nil, waitReturnValue(n.Data))
+			return
+		}
+	}
+}
+
+func (d *dagEngine) saveWaitResult(ctx context.Context, job *model.WorkflowNodeJob, returnValue any) error {
+	job.ReturnInfo = datatypes.NewJSONType(model.ReturnInfo{
+		Suc:         true,
+		ReturnValue: returnValue,
+	})
+	job.UpdatedAt = time.Now()
+
+	return d.workflowStore.UpdateData(context.Background(), job, map[string]any{
+		"id": job.ID,
+	}, "return_info", "updated_at")
+}
+
+func waitReturnValue(data datatypes.JSON) any {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+
+	return value
+}
+
+// 设备属性更新后检查等待该设备的节点
+func (d *dagEngine) OnMaterialData(_ context.Context, data *engine.MaterialData) {
+	if data == nil {
+		return
+	}
+
+	for _, w := range d.waits.find(data.DeviceName) {
+		if w.param.match(data.Data) {
+			w.finish(nil, waitReturnValue(data.Data))
+		}
+	}
+}
+
+func (g *waitGate) add(w *nodeWait) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if w.removed {
+		return
+	}
+
+	if g.waits == nil {
+		g.waits = make(map[*nodeWait]struct{})
+	}
+	g.waits[w] = struct{}{}
+}
+
+func (g *waitGate) remove(w *nodeWait) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	w.removed = true
+	delete(g.waits, w)
+}
+
+func (g *waitGate) find(deviceName string) []*nodeWait {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	waits := make([]*nodeWait, 0, len(g.waits))
+	for w := range g.waits {
+		if w.param.DeviceName == deviceName {
+			waits = append(waits, w)
+		}
+	}
+
+	return waits
+}
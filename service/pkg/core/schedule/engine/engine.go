@@ -14,9 +14,16 @@ import (
 type Task interface {
 	Run(ctx context.Context, job *WorkflowInfo) error
 	Stop(ctx context.Context) error
-	GetStatus(ctx context.Context) error
+	Release()                                           // 释放任务占用的协程池等资源，未运行的任务也需要调用
+	GetStatus(ctx context.Context) (*TaskStatus, error) // 读取任务运行时状态
 	OnJobUpdate(ctx context.Context, data *JobData) error
-	ID(ctx context.Context) uuid.UUID // 获取当前任务 id
+	OnManualDecision(ctx context.Context, data *ManualDecision) error // 人工节点确认或拒绝
+	OnMaterialData(ctx context.Context, data *MaterialData)           // 设备属性上报后更新的物料数据
+	ID(ctx context.Context) uuid.UUID                                 // 获取当前任务 id
+
+	// 运行控制
+	Pause(ctx context.Context) error    // 暂停下发新节点，已下发的动作继续运行
+	Continue(ctx context.Context) error // 继续运行暂停的任务
 
 	// 状态控制
 	GetDeviceActionStatus(ctx context.Context, key ActionKey) (ActionValue, bool)
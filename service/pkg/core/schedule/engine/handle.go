@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/model"
+)
+
+// ready 句柄只表示执行顺序，不传递数据
+const ReadyHandleKey = "ready"
+
+// 注册表中常见的数据类型别名
+var handleTypeAlias = map[string]string{
+	"str":     "string",
+	"string":  "string",
+	"text":    "string",
+	"int":     "number",
+	"integer": "number",
+	"float":   "number",
+	"double":  "number",
+	"number":  "number",
+	"bool":    "boolean",
+	"boolean": "boolean",
+	"list":    "array",
+	"tuple":   "array",
+	"array":   "array",
+	"dict":    "object",
+	"map":     "object",
+	"object":  "object",
+}
+
+func normalizeHandleType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if alias, ok := handleTypeAlias[t]; ok {
+		return alias
+	}
+
+	return t
+}
+
+// 检查边两端的句柄能否相连
+//   - 连到 ready 句柄只表示执行顺序，不限制
+//   - 配置了表达式的边由表达式转换数据，不比较类型
+//   - ready 句柄不能连到数据句柄
+//   - 类型为空或 any 时不限制，其余按别名归一后比较
+func CheckHandleType(source, target *model.WorkflowHandleTemplate, expression string) error {
+	if target.HandleKey == ReadyHandleKey || expression != "" {
+		return nil
+	}
+
+	if source.HandleKey == ReadyHandleKey {
+		return code.HandleTypeMismatchErr.WithMsgf("ready handle can not connect to data handle %s", target.HandleKey)
+	}
+
+	sourceType, targetType := normalizeHandleType(source.Type), normalizeHandleType(target.Type)
+	if sourceType == "" || targetType == "" || sourceType == "any" || targetType == "any" {
+		return nil
+	}
+
+	if sourceType != targetType {
+		return code.HandleTypeMismatchErr.WithMsgf("handle %s type %s can not connect to handle %s type %s",
+			source.HandleKey, source.Type, target.HandleKey, target.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+// notlint:revive
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHandleType(t *testing.T) {
+	handle := func(key, typ string) *model.WorkflowHandleTemplate {
+		return &model.WorkflowHandleTemplate{HandleKey: key, Type: typ}
+	}
+
+	cases := []struct {
+		name       string
+		source     *model.WorkflowHandleTemplate
+		target     *model.WorkflowHandleTemplate
+		expression string
+		ok         bool
+	}{
+		{name: "same type", source: handle("out", "string"), target: handle("in", "string"), ok: true},
+		{name: "mismatch", source: handle("out", "string"), target: handle("in", "number"), ok: false},
+		{name: "alias str", source: handle("out", "str"), target: handle("in", "String"), ok: true},
+		{name: "alias int and float", source: handle("out", "int"), target: handle("in", "float"), ok: true},
+		{name: "alias bool", source: handle("out", "bool"), target: handle("in", "boolean"), ok: true},
+		{name: "alias list and tuple", source: handle("out", "list"), target: handle("in", "tuple"), ok: true},
+		{name: "alias dict and object", source: handle("out", " dict "), target: handle("in", "object"), ok: true},
+		{name: "alias mismatch", source: handle("out", "list"), target: handle("in", "dict"), ok: false},
+		{name: "unknown types equal", source: handle("out", "Resource"), target: handle("in", "resource"), ok: true},
+		{name: "unknown types differ", source: handle("out", "Resource"), target: handle("in", "Container"), ok: false},
+		{name: "source any", source: handle("out", "any"), target: handle("in", "number"), ok: true},
+		{name: "target any", source: handle("out", "dict"), target: handle("in", "ANY"), ok: true},
+		{name: "empty type", source: handle("out", ""), target: handle("in", "number"), ok: true},
+		{name: "to ready", source: handle("out", "string"), target: handle(ReadyHandleKey, "number"), ok: true},
+		{name: "ready to ready", source: handle(ReadyHandleKey, ""), target: handle(ReadyHandleKey, ""), ok: true},
+		{name: "ready to data", source: handle(ReadyHandleKey, ""), target: handle("in", "number"), ok: false},
+		{name: "ready to any data", source: handle(ReadyHandleKey, "any"), target: handle("in", "any"), ok: false},
+		{name: "expression bypass", source: handle("out", "string"), target: handle("in", "number"), expression: "float(value)", ok: true},
+		{name: "expression from ready", source: handle(ReadyHandleKey, ""), target: handle("in", "number"), expression: "1", ok: true},
+	}
+
+	for _, c := range cases {
+		err := CheckHandleType(c.source, c.target, c.expression)
+		if c.ok {
+			assert.NoError(t, err, c.name)
+			continue
+		}
+
+		var errWithMsg code.ErrCodeWithMsg
+		assert.True(t, errors.As(err, &errWithMsg), c.name)
+		assert.Equal(t, code.HandleTypeMismatchErr, errWithMsg.ErrCode, c.name)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/olahol/melody"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/expr"
 	"github.com/scienceol/studio/service/pkg/common/uuid"
 	"github.com/scienceol/studio/service/pkg/core/notify"
 	"github.com/scienceol/studio/service/pkg/model"
@@ -18,8 +20,14 @@ type ActionParam struct {
 	WorkflowInfo *WorkflowInfo
 }
 
+// 向 edge 下发消息的会话，真实运行为 websocket 连接，仿真运行为 mock edge
+type Session interface {
+	Write(msg []byte) error
+	IsClosed() bool
+}
+
 type TaskParam struct {
-	Session    *melody.Session
+	Session    Session
 	Cancle     context.CancelFunc
 	Sandbox    repo.Sandbox
 	BoardEvent notify.MsgCenter
@@ -28,11 +36,13 @@ type TaskParam struct {
 type WorkflowInfo struct {
 	Action WorkflowAction `json:"action"`
 
-	TaskUUID     uuid.UUID `json:"task_uuid"`
-	WorkflowUUID uuid.UUID `json:"workflow_id"` // 任务 id
-	LabUUID      uuid.UUID `json:"lab_uuid"`
-	UserID       string    `json:"user_id"` // 提交用户 id
-	Data         any       `json:"data"`    // FIXME: 修复，暂时给物料添加使用
+	TaskUUID     uuid.UUID       `json:"task_uuid"`
+	WorkflowUUID uuid.UUID       `json:"workflow_id"` // 任务 id
+	LabUUID      uuid.UUID       `json:"lab_uuid"`
+	UserID       string          `json:"user_id"`          // 提交用户 id
+	Data         any             `json:"data"`             // FIXME: 修复，暂时给物料添加使用
+	RequestUUID  uuid.UUID       `json:"request_uuid"`     // 状态查询请求 id，回复时原样带回
+	Manual       *ManualDecision `json:"manual,omitempty"` // 人工节点的处理结果
 
 	LabData *model.Laboratory `json:"-"`
 	TaskID  int64             `json:"-"`
@@ -42,8 +52,13 @@ type WorkflowAction string
 
 const (
 	StartJob       WorkflowAction = "start_job"
+	ResumeJob      WorkflowAction = "resume_job" // 恢复中断的任务
 	StopJob        WorkflowAction = "stop_job"
+	PauseJob       WorkflowAction = "pause_job"
+	ContinueJob    WorkflowAction = "continue_job" // 继续运行暂停的任务
 	StatusJob      WorkflowAction = "status_job"
+	SimulateJob    WorkflowAction = "simulate_job" // 使用 mock edge 仿真运行
+	ManualJob      WorkflowAction = "manual_job"   // 人工节点确认或拒绝
 	StartAction    WorkflowAction = "start_action"
 	AddMaterial    WorkflowAction = "add_material"
 	UpdateMaterial WorkflowAction = "update_material"
@@ -66,15 +81,21 @@ type SendActionData struct {
 }
 
 type BoardMsg struct {
-	NodeUUID    uuid.UUID                            `json:"node_uuid"`    // 节点 uuid
-	TaskStatus  string                               `json:"task_status"`  // 工作流状态
-	JobStatus   string                               `json:"job_status"`   // 节点状态
-	Header      string                               `json:"header"`       // action 名
-	Type        string                               `json:"type"`         // 日志级别
-	Msg         string                               `json:"msg"`          // 消息体
-	StackTrace  []string                             `json:"stack_trace"`  // 错误堆栈信息
-	ReturnInfos datatypes.JSONType[model.ReturnInfo] `json:"return_infos"` // 返回结果
-	Timestamp   time.Time                            `json:"timestamp"`    // 日志时间戳
+	NodeUUID        uuid.UUID                            `json:"node_uuid"`         // 节点 uuid
+	TaskStatus      string                               `json:"task_status"`       // 工作流状态
+	JobStatus       string                               `json:"job_status"`        // 节点状态
+	Header          string                               `json:"header"`            // action 名
+	Type            string                               `json:"type"`              // 日志级别
+	Msg             string                               `json:"msg"`               // 消息体
+	StackTrace      []string                             `json:"stack_trace"`       // 错误堆栈信息
+	ReturnInfos     datatypes.JSONType[model.ReturnInfo] `json:"return_infos"`      // 返回结果
+	Attempt         int                                  `json:"attempt"`           // 节点尝试次数
+	MapNodeUUID     uuid.UUID                            `json:"map_node_uuid"`     // 所属 map 节点 uuid
+	Iteration       int                                  `json:"iteration"`         // map 节点迭代序号
+	SubflowNodeUUID uuid.UUID                            `json:"subflow_node_uuid"` // 所属子工作流节点 uuid
+	SubTaskUUID     uuid.UUID                            `json:"sub_task_uuid"`     // 子工作流任务 uuid
+	Manual          *ManualRequest                       `json:"manual,omitempty"`  // 人工节点等待处理的请求
+	Timestamp       time.Time                            `json:"timestamp"`         // 日志时间戳
 }
 
 type CancelTask struct {
@@ -98,6 +119,24 @@ type HandlePair struct {
 	SourceHandle *model.WorkflowHandleTemplate
 	TargetHandle *model.WorkflowHandleTemplate
 	SourceNode   *model.WorkflowNode
+	Edge         *model.WorkflowEdge
+	Expression   *expr.Program // 边上的数据转换表达式，为空直接传递
+}
+
+// 边表达式可以引用的变量
+//   - value: 源 handle 对应的数据，源 handle 没有 data key 时为上游完整返回值
+//   - source: 上游节点完整返回值
+//   - param: 目标节点当前参数
+//   - upstream: 所有生效的上游节点返回值，按节点名和 uuid 索引
+var EdgeExpressionVars = []string{"value", "source", "param", "upstream"}
+
+// 运行前校验发现的问题
+type Diagnostic struct {
+	NodeUUID uuid.UUID    `json:"node_uuid"` // 为空表示工作流级别的问题
+	NodeName string       `json:"node_name"`
+	EdgeUUID uuid.UUID    `json:"edge_uuid"` // 问题出在边上时不为空
+	Code     code.ErrCode `json:"code"`
+	Msg      string       `json:"msg"`
 }
 
 type StatusType string
@@ -121,6 +160,61 @@ type ActionValue struct {
 	Timestamp time.Time     `json:"timestamp"`
 }
 
+// 任务运行时状态，由运行任务的调度实例从引擎内存中读取
+type TaskStatus struct {
+	TaskUUID     uuid.UUID      `json:"task_uuid"`
+	Running      bool           `json:"running"`       // 任务是否在调度实例中运行
+	Paused       bool           `json:"paused"`        // 是否暂停下发新节点
+	ReadyNodes   []*NodeStatus  `json:"ready_nodes"`   // 依赖已满足、等待下发的节点
+	RunningNodes []*NodeStatus  `json:"running_nodes"` // 已下发未结束的节点
+	WaitingNodes []*NodeStatus  `json:"waiting_nodes"` // 等待上游节点完成的节点
+	Actions      []*ActionState `json:"actions"`       // 等待 edge 回复的动作
+	Timestamp    time.Time      `json:"timestamp"`     // 状态读取时间
+}
+
+type NodeStatus struct {
+	NodeUUID       uuid.UUID               `json:"node_uuid"`
+	JobUUID        uuid.UUID               `json:"job_uuid"` // 未下发的节点为空
+	Name           string                  `json:"name"`
+	ActionName     string                  `json:"action_name"`
+	Status         model.WorkflowJobStatus `json:"status"`
+	Attempt        int                     `json:"attempt"`         // 已尝试次数
+	MaxAttempts    int                     `json:"max_attempts"`    // 最大尝试次数，小于等于 1 不重试
+	PendingParents int                     `json:"pending_parents"` // 未完成的上游节点数
+}
+
+type ActionState struct {
+	ActionKey
+	Free     bool      `json:"free"`
+	Deadline time.Time `json:"deadline"` // 超过该时间未回复视为超时
+}
+
+// 人工节点等待处理的请求，推送给前端
+type ManualRequest struct {
+	UUID      uuid.UUID                  `json:"uuid"`
+	Title     string                     `json:"title"`
+	Message   string                     `json:"message"`
+	Form      []model.ManualField        `json:"form"`
+	Role      model.LaboratoryMemberRole `json:"role"`
+	Deadline  *time.Time                 `json:"deadline"`
+	OnTimeout model.ManualTimeoutPolicy  `json:"on_timeout"`
+}
+
+// 人工节点的处理结果
+type ManualDecision struct {
+	ApprovalUUID uuid.UUID      `json:"approval_uuid"`
+	Confirm      bool           `json:"confirm"`
+	Input        map[string]any `json:"input"`
+	Comment      string         `json:"comment"`
+	UserID       string         `json:"user_id"`
+}
+
+// 设备属性上报后物料节点的最新数据
+type MaterialData struct {
+	DeviceName string         `json:"device_name"`
+	Data       datatypes.JSON `json:"data"`
+}
+
 type MaterialUpdate struct {
 	UUID          uuid.UUID `json:"uuid"`
 	DeviceOldUUID uuid.UUID `json:"device_old_uuid"`
@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/notify"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+)
+
+// 动作状态表：下发前登记等待的 key，edge 回复时通知等待方立即继续，超时由定时器触发
+
+type actionEntry struct {
+	value  ActionValue
+	notify chan struct{} // 状态变化时关闭，唤醒所有等待方
+}
+
+type ActionStatus struct {
+	lock    sync.Mutex
+	entries map[ActionKey]*actionEntry
+}
+
+func NewActionStatus() *ActionStatus {
+	return &ActionStatus{
+		entries: make(map[ActionKey]*actionEntry),
+	}
+}
+
+func (s *ActionStatus) Init(key ActionKey, deadline time.Time, free bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		close(entry.notify)
+	}
+
+	s.entries[key] = &actionEntry{
+		value: ActionValue{
+			Free:      free,
+			Timestamp: deadline,
+		},
+		notify: make(chan struct{}),
+	}
+}
+
+func (s *ActionStatus) Get(key ActionKey) (ActionValue, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return ActionValue{}, false
+	}
+
+	return entry.value, true
+}
+
+// 更新状态并通知等待方，needMore 延长超时时间，key 不存在返回 false
+func (s *ActionStatus) Set(key ActionKey, free bool, needMore time.Duration) (ActionValue, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return ActionValue{}, false
+	}
+
+	entry.value.Free = free
+	entry.value.Timestamp = entry.value.Timestamp.Add(needMore)
+	close(entry.notify)
+	entry.notify = make(chan struct{})
+
+	return entry.value, true
+}
+
+func (s *ActionStatus) Del(key ActionKey) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		close(entry.notify)
+		delete(s.entries, key)
+	}
+}
+
+// 阻塞直到动作空闲，超时返回 JobTimeoutErr，ctx 结束返回 JobCanceled，key 被删除返回 notExistErr
+func (s *ActionStatus) Wait(ctx context.Context, key ActionKey, notExistErr error) error {
+	for {
+		s.lock.Lock()
+		entry, ok := s.entries[key]
+		if !ok {
+			s.lock.Unlock()
+			return notExistErr
+		}
+
+		if entry.value.Free {
+			delete(s.entries, key)
+			s.lock.Unlock()
+			return nil
+		}
+
+		remain := time.Until(entry.value.Timestamp)
+		notify := entry.notify
+		s.lock.Unlock()
+
+		if remain <= 0 {
+			return code.JobTimeoutErr
+		}
+
+		timer := time.NewTimer(remain)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return code.JobCanceled
+		case <-notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// 当前登记的所有动作状态
+func (s *ActionStatus) Snapshot() []*ActionState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	states := make([]*ActionState, 0, len(s.entries))
+	for key, entry := range s.entries {
+		states = append(states, &ActionState{
+			ActionKey: key,
+			Free:      entry.value.Free,
+			Deadline:  entry.value.Timestamp,
+		})
+	}
+
+	return states
+}
+
+// 通过广播回复任务状态查询，task 为空表示任务不在本实例运行
+func ReplyStatus(ctx context.Context, boardEvent notify.MsgCenter, info *WorkflowInfo, task Task) {
+	status := &TaskStatus{
+		TaskUUID:  info.TaskUUID,
+		Timestamp: time.Now(),
+	}
+	if task != nil {
+		var err error
+		if status, err = task.GetStatus(ctx); err != nil {
+			logger.Errorf(ctx, "ReplyStatus get task status uuid: %s, err: %+v", info.TaskUUID, err)
+			return
+		}
+	}
+
+	if err := boardEvent.Broadcast(ctx, &notify.SendMsg{
+		Channel:  notify.WorkflowStatus,
+		TaskUUID: info.TaskUUID,
+		LabUUID:  info.LabUUID,
+		UUID:     info.RequestUUID,
+		Data:     status,
+	}); err != nil {
+		logger.Errorf(ctx, "ReplyStatus board msg uuid: %s, err: %+v", info.TaskUUID, err)
+	}
+}
@@ -0,0 +1,283 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	r "github.com/redis/go-redis/v9"
+	"github.com/scienceol/studio/service/internal/config"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/lock"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/middleware/redis"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+const (
+	// 排队者等待释放通知，租约到期或排队者退出没有通知，按该间隔兜底重试
+	waitInterval = 2 * time.Second
+	// 排队者超过该时长未重试视为已退出
+	waiterTTL = 3 * waitInterval
+)
+
+var (
+	// 排队按先到先得：持有者相同则续期；未被占用且队列为空或自己在队首则获取并出队，返回 1；
+	// 否则加入队列并刷新存活时间，返回当前持有者信息，设备空闲但前面有排队者时返回空
+	acquireScript = r.NewScript(`
+        local lock_key = KEYS[1]
+        local lock_set = KEYS[2]
+        local queue_key = KEYS[3]
+        local waiter_key = KEYS[4]
+        local holder_id = ARGV[1]
+        local holder = ARGV[2]
+        local lease_ms = tonumber(ARGV[3])
+        local device_name = ARGV[4]
+        local now_ms = tonumber(ARGV[5])
+        local waiter_ttl_ms = tonumber(ARGV[6])
+
+        local current = redis.call('HGET', lock_key, 'holder_id')
+        if current == holder_id then
+            redis.call('PEXPIRE', lock_key, lease_ms)
+            return 1
+        end
+
+        -- 移出队首已退出的排队者
+        local head = redis.call('ZRANGE', queue_key, 0, 0)[1]
+        while head and head ~= holder_id do
+            local alive = tonumber(redis.call('HGET', waiter_key, head) or '0')
+            if alive >= now_ms then
+                break
+            end
+            redis.call('ZREM', queue_key, head)
+            redis.call('HDEL', waiter_key, head)
+            head = redis.call('ZRANGE', queue_key, 0, 0)[1]
+        end
+
+        if not current and (not head or head == holder_id) then
+            redis.call('ZREM', queue_key, holder_id)
+            redis.call('HDEL', waiter_key, holder_id)
+            redis.call('HSET', lock_key, 'holder_id', holder_id, 'holder', holder)
+            redis.call('PEXPIRE', lock_key, lease_ms)
+            redis.call('SADD', lock_set, device_name)
+            return 1
+        end
+
+        redis.call('ZADD', queue_key, 'NX', now_ms, holder_id)
+        redis.call('HSET', waiter_key, holder_id, now_ms + waiter_ttl_ms)
+        redis.call('PEXPIRE', queue_key, waiter_ttl_ms)
+        redis.call('PEXPIRE', waiter_key, waiter_ttl_ms)
+        if current then
+            return redis.call('HGET', lock_key, 'holder')
+        end
+        return ''
+    `)
+
+	// 排队者放弃等待，移出队列后通知其他排队者
+	leaveScript = r.NewScript(`
+        redis.call('ZREM', KEYS[1], ARGV[1])
+        redis.call('HDEL', KEYS[2], ARGV[1])
+        redis.call('PUBLISH', KEYS[3], ARGV[1])
+        return 1
+    `)
+
+	// 持有者相同则续期
+	renewScript = r.NewScript(`
+        if redis.call('HGET', KEYS[1], 'holder_id') == ARGV[1] then
+            return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+        end
+        return 0
+    `)
+
+	// 持有者相同则释放，并通知排队者
+	releaseScript = r.NewScript(`
+        if redis.call('HGET', KEYS[1], 'holder_id') == ARGV[1] then
+            redis.call('DEL', KEYS[1])
+            redis.call('SREM', KEYS[2], ARGV[2])
+            redis.call('PUBLISH', KEYS[3], ARGV[2])
+            return 1
+        end
+        return 0
+    `)
+)
+
+type deviceLock struct {
+	rClient *r.Client
+	lease   time.Duration
+}
+
+func New() lock.DeviceLock {
+	return &deviceLock{
+		rClient: redis.GetClient(),
+		lease:   time.Duration(config.Global().Job.DeviceLockLeaseSecond) * time.Second,
+	}
+}
+
+func (l *deviceLock) Acquire(ctx context.Context, holder *lock.Holder, onWait lock.WaitFunc) (context.Context, lock.ReleaseFunc, error) {
+	lockKey := utils.LabDeviceLockName(holder.LabUUID, holder.DeviceName)
+	setKey := utils.LabDeviceLockSetName(holder.LabUUID)
+	queueKey := utils.LabDeviceLockQueueName(holder.LabUUID, holder.DeviceName)
+	waiterKey := utils.LabDeviceWaiterName(holder.LabUUID, holder.DeviceName)
+	notifyKey := utils.LabDeviceLockNotifyName(holder.LabUUID, holder.DeviceName)
+	acquireKeys := []string{lockKey, setKey, queueKey, waiterKey}
+	queueKeys := []string{queueKey, waiterKey, notifyKey}
+
+	holder.AcquiredAt = time.Now()
+	holderB, _ := json.Marshal(holder)
+
+	var sub *r.PubSub
+	defer func() {
+		if sub != nil {
+			_ = sub.Close()
+		}
+	}()
+
+	waiting := false
+	for {
+		res, err := acquireScript.Run(ctx, l.rClient, acquireKeys,
+			holder.HolderID.String(), string(holderB), l.lease.Milliseconds(), holder.DeviceName,
+			time.Now().UnixMilli(), waiterTTL.Milliseconds()).Result()
+		if err != nil {
+			if waiting {
+				l.leaveQueue(ctx, queueKeys, holder)
+			}
+			if ctx.Err() != nil {
+				return nil, nil, code.JobCanceled
+			}
+			logger.Errorf(ctx, "deviceLock.Acquire device: %s, err: %+v", holder.DeviceName, err)
+			return nil, nil, code.DeviceLockErr.WithErr(err)
+		}
+
+		if acquired, ok := res.(int64); ok && acquired == 1 {
+			break
+		}
+
+		if !waiting && onWait != nil {
+			current := &lock.Holder{}
+			if s, ok := res.(string); ok && s != "" {
+				_ = json.Unmarshal([]byte(s), current)
+			}
+			onWait(current)
+		}
+		waiting = true
+
+		// 开始排队时订阅释放通知，订阅后立即重试一次，避免错过订阅前的释放
+		if sub == nil {
+			sub = l.rClient.Subscribe(ctx, notifyKey)
+			if _, err := sub.Receive(ctx); err != nil {
+				logger.Warnf(ctx, "deviceLock.Acquire subscribe device: %s, err: %+v", holder.DeviceName, err)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			l.leaveQueue(ctx, queueKeys, holder)
+			return nil, nil, code.JobCanceled
+		case <-sub.Channel():
+		case <-time.After(waitInterval):
+		}
+	}
+
+	leaseCtx, release := l.keepAlive(ctx, []string{lockKey, setKey, notifyKey}, holder)
+	return leaseCtx, release, nil
+}
+
+// 放弃等待时移出队列，不阻塞后面的排队者
+func (l *deviceLock) leaveQueue(ctx context.Context, keys []string, holder *lock.Holder) {
+	if err := leaveScript.Run(context.Background(), l.rClient, keys,
+		holder.HolderID.String()).Err(); err != nil {
+		logger.Errorf(ctx, "deviceLock.leaveQueue device: %s, err: %+v", holder.DeviceName, err)
+	}
+}
+
+// 持有期间定时续期，租约被其他持有者占用或超过租约时长未能续期时取消返回的 ctx
+func (l *deviceLock) keepAlive(ctx context.Context, keys []string, holder *lock.Holder) (context.Context, lock.ReleaseFunc) {
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	lost := func() {
+		logger.Warnf(ctx, "deviceLock.keepAlive lease lost device: %s, holder: %s", holder.DeviceName, holder.HolderID)
+		cancel(code.DeviceLeaseLostErr.WithMsgf("device: %s", holder.DeviceName))
+	}
+
+	done := make(chan struct{})
+	utils.SafelyGo(func() {
+		ticker := time.NewTicker(l.lease / 3)
+		defer ticker.Stop()
+		renewAt := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				res, err := renewScript.Run(context.Background(), l.rClient, keys[:1],
+					holder.HolderID.String(), l.lease.Milliseconds()).Int64()
+				if err != nil {
+					logger.Errorf(ctx, "deviceLock.keepAlive device: %s, err: %+v", holder.DeviceName, err)
+					// 超过租约时长未续期，租约已到期
+					if time.Since(renewAt) >= l.lease {
+						lost()
+						return
+					}
+					continue
+				}
+
+				if res == 0 {
+					lost()
+					return
+				}
+				renewAt = time.Now()
+			}
+		}
+	}, func(err error) {
+		logger.Errorf(ctx, "deviceLock.keepAlive SafelyGo err: %+v", err)
+	})
+
+	var once sync.Once
+	return leaseCtx, func() {
+		once.Do(func() {
+			close(done)
+			cancel(nil)
+			if err := releaseScript.Run(context.Background(), l.rClient, keys,
+				holder.HolderID.String(), holder.DeviceName).Err(); err != nil {
+				logger.Errorf(ctx, "deviceLock.release device: %s, err: %+v", holder.DeviceName, err)
+			}
+		})
+	}
+}
+
+func (l *deviceLock) Holders(ctx context.Context, labUUID uuid.UUID) ([]*lock.Holder, error) {
+	setName := utils.LabDeviceLockSetName(labUUID)
+	devices, err := l.rClient.SMembers(ctx, setName).Result()
+	if err != nil {
+		return nil, code.DeviceLockErr.WithErr(err)
+	}
+
+	holders := make([]*lock.Holder, 0, len(devices))
+	for _, deviceName := range devices {
+		lockName := utils.LabDeviceLockName(labUUID, deviceName)
+		holderStr, err := l.rClient.HGet(ctx, lockName, "holder").Result()
+		if err == r.Nil {
+			// 租约已到期，清理集合
+			l.rClient.SRem(ctx, setName, deviceName)
+			continue
+		}
+		if err != nil {
+			return nil, code.DeviceLockErr.WithErr(err)
+		}
+
+		holder := &lock.Holder{}
+		if err := json.Unmarshal([]byte(holderStr), holder); err != nil {
+			logger.Warnf(ctx, "deviceLock.Holders unmarshal device: %s, err: %+v", deviceName, err)
+			continue
+		}
+
+		if ttl, err := l.rClient.PTTL(ctx, lockName).Result(); err == nil && ttl > 0 {
+			holder.ExpireAt = time.Now().Add(ttl)
+		}
+		holders = append(holders, holder)
+	}
+
+	return holders, nil
+}
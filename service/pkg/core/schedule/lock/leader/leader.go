@@ -0,0 +1,70 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	r "github.com/redis/go-redis/v9"
+	"github.com/scienceol/studio/service/pkg/core/schedule/lock"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/middleware/redis"
+)
+
+const leaderPrefix = "schedule_leader_%s"
+
+var (
+	// 持有者相同则续期，无持有者则获取，返回 1 表示当前实例为 leader
+	campaignScript = r.NewScript(`
+        local current = redis.call('GET', KEYS[1])
+        if current == ARGV[1] then
+            redis.call('PEXPIRE', KEYS[1], ARGV[2])
+            return 1
+        end
+        if not current then
+            redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+            return 1
+        end
+        return 0
+    `)
+
+	// 持有者相同则释放
+	resignScript = r.NewScript(`
+        if redis.call('GET', KEYS[1]) == ARGV[1] then
+            return redis.call('DEL', KEYS[1])
+        end
+        return 0
+    `)
+)
+
+type leader struct {
+	rClient *r.Client
+	key     string
+	id      string
+	lease   time.Duration
+}
+
+// name 为选主的业务名，id 为当前调度实例名
+func New(name, id string, lease time.Duration) lock.Leader {
+	return &leader{
+		rClient: redis.GetClient(),
+		key:     fmt.Sprintf(leaderPrefix, name),
+		id:      id,
+		lease:   lease,
+	}
+}
+
+func (l *leader) Campaign(ctx context.Context) (bool, error) {
+	res, err := campaignScript.Run(ctx, l.rClient, []string{l.key}, l.id, l.lease.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+
+	return res == 1, nil
+}
+
+func (l *leader) Resign(ctx context.Context) {
+	if err := resignScript.Run(ctx, l.rClient, []string{l.key}, l.id).Err(); err != nil {
+		logger.Errorf(ctx, "leader.Resign key: %s, err: %+v", l.key, err)
+	}
+}
@@ -0,0 +1,56 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+)
+
+/*
+	实验室设备锁，基于 redis 租约实现，多个调度 pod 之间共享
+1. 工作流 ILab 节点和手动执行的动作下发前获取设备租约，设备被占用时按先到先得排队，释放时通知排队者
+2. 持有期间自动续期，完成、取消或超时后释放，进程异常退出时租约到期自动释放
+*/
+
+type Source string
+
+const (
+	SourceWorkflow Source = "workflow" // 工作流节点
+	SourceAction   Source = "action"   // 手动执行动作
+)
+
+type Holder struct {
+	LabUUID    uuid.UUID `json:"lab_uuid"`
+	DeviceName string    `json:"device_name"`
+	HolderID   uuid.UUID `json:"holder_id"` // 工作流为 job uuid，手动动作为任务 uuid
+	TaskUUID   uuid.UUID `json:"task_uuid"`
+	NodeUUID   uuid.UUID `json:"node_uuid"`
+	ActionName string    `json:"action_name"`
+	Source     Source    `json:"source"`
+	UserID     string    `json:"user_id"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpireAt   time.Time `json:"expire_at"`
+}
+
+// 设备被占用开始排队时回调，current 为当前持有者
+type WaitFunc func(current *Holder)
+
+// 释放租约，可重复调用
+type ReleaseFunc func()
+
+type DeviceLock interface {
+	// 阻塞直到获取设备租约或 ctx 结束，相同 HolderID 可重入
+	// 返回的 ctx 在租约丢失时取消，cause 为 DeviceLeaseLostErr，持有期间的操作应使用该 ctx
+	Acquire(ctx context.Context, holder *Holder, onWait WaitFunc) (context.Context, ReleaseFunc, error)
+	// 实验室当前所有设备锁的持有者
+	Holders(ctx context.Context, labUUID uuid.UUID) ([]*Holder, error)
+}
+
+// 调度实例选主，基于 redis 租约，同一时刻只有一个实例持有，持有者退出后租约到期由其他实例接管
+type Leader interface {
+	// 获取或续期领导权，需在租约时长内周期调用，返回当前实例是否为 leader
+	Campaign(ctx context.Context) (bool, error)
+	// 主动放弃领导权
+	Resign(ctx context.Context)
+}
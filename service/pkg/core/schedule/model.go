@@ -20,6 +20,7 @@ const (
 	QueryActionStatus ActionType = "query_action_state" // 查询动作是否能执行
 	Pong              ActionType = "pong"               // 心跳
 	CancelTask        ActionType = "cancel_task"        // 取消任务
+	QueryJobStatus    ActionType = "query_job_status"   // 查询在途动作状态，edge 通过 job_status 回复，协议见 engine/dag/recover.go
 
 	// edge 上行数据
 	JobStatus         ActionType = "job_status"          // 任务状态回调
@@ -5,6 +5,7 @@ import (
 
 	"github.com/scienceol/studio/service/pkg/common"
 	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
 	"github.com/scienceol/studio/service/pkg/model"
 	"gorm.io/datatypes"
 )
@@ -116,8 +117,14 @@ const (
 	WorkflowUpdate      ActionType = "workflow_update"
 	RunWorkflow         ActionType = "run_workflow"
 	StopWorkflow        ActionType = "stop_workflow"
+	PauseWorkflow       ActionType = "pause_workflow"
+	ResumeWorkflow      ActionType = "resume_workflow"
+	RerunWorkflow       ActionType = "rerun_workflow"
+	SimulateWorkflow    ActionType = "simulate_workflow"
 	FetchWorkflowStatus ActionType = "fetch_workflow_task"
 	Dumplicate          ActionType = "duplicate"
+	ManualDecision      ActionType = "manual_decision"   // 人工节点确认或拒绝
+	ValidateWorkflow    ActionType = "validate_workflow" // 运行前校验
 )
 
 type WSNodeHandle struct {
@@ -143,23 +150,26 @@ type WSGroupRes struct {
 }
 
 type WSNode struct {
-	UUID         uuid.UUID                      `json:"uuid"`
-	Name         string                         `json:"name"`
-	TemplateUUID uuid.UUID                      `json:"template_uuid"`
-	ParentUUID   uuid.UUID                      `json:"parent_uuid"`
-	UserID       string                         `json:"user_id"`
-	Status       string                         `json:"status"`
-	Type         model.WorkflowNodeType         `json:"type"`
-	Icon         string                         `json:"icon"`
-	Pose         datatypes.JSONType[model.Pose] `json:"pose" swaggertype:"object"`
-	Param        datatypes.JSON                 `json:"param" swaggertype:"object"`
-	Schema       datatypes.JSON                 `json:"schema" swaggertype:"object"`
-	Handles      []*WSNodeHandle                `json:"handles"`
-	Footer       string                         `json:"footer"`
-	DeviceName   *string                        `json:"device_name,omitempty"`
-	Disabled     bool                           `json:"disabled"`
-	Minimized    bool                           `json:"minimized"`
-	LabNodeType  string                         `json:"lab_node_type"`
+	UUID          uuid.UUID                      `json:"uuid"`
+	Name          string                         `json:"name"`
+	TemplateUUID  uuid.UUID                      `json:"template_uuid"`
+	ParentUUID    uuid.UUID                      `json:"parent_uuid"`
+	UserID        string                         `json:"user_id"`
+	Status        string                         `json:"status"`
+	Type          model.WorkflowNodeType         `json:"type"`
+	Icon          string                         `json:"icon"`
+	Pose          datatypes.JSONType[model.Pose] `json:"pose" swaggertype:"object"`
+	Param         datatypes.JSON                 `json:"param" swaggertype:"object"`
+	Schema        datatypes.JSON                 `json:"schema" swaggertype:"object"`
+	Handles       []*WSNodeHandle                `json:"handles"`
+	Footer        string                         `json:"footer"`
+	DeviceName    *string                        `json:"device_name,omitempty"`
+	Disabled      bool                           `json:"disabled"`
+	Minimized     bool                           `json:"minimized"`
+	LabNodeType   string                         `json:"lab_node_type"`
+	RetryPolicy   model.RetryPolicy              `json:"retry_policy"`
+	TimeoutSecond int                            `json:"timeout_second"`
+	FailurePolicy model.FailurePolicy            `json:"failure_policy"`
 }
 
 type WSEdge struct {
@@ -168,6 +178,7 @@ type WSEdge struct {
 	TargetNodeUUID   uuid.UUID `json:"target_node_uuid"`
 	SourceHandleUUID uuid.UUID `json:"source_handle_uuid"`
 	TargetHandleUUID uuid.UUID `json:"target_handle_uuid"`
+	Expression       string    `json:"expression"` // 数据转换表达式
 }
 
 type WSGraph struct {
@@ -214,18 +225,21 @@ type WSCreateNode struct {
 }
 
 type WSUpdateNode struct {
-	UUID       uuid.UUID                       `json:"uuid"`
-	ParentUUID *uuid.UUID                      `json:"parent_uuid,omitempty"`
-	Status     *string                         `json:"status,omitempty"`
-	Type       *model.WorkflowNodeType         `json:"type,omitempty"`
-	Icon       *string                         `json:"icon,omitempty"`
-	Pose       *datatypes.JSONType[model.Pose] `json:"pose,omitempty" swaggertype:"object"`
-	Param      *datatypes.JSON                 `json:"param,omitempty" swaggertype:"object"`
-	Footer     *string                         `json:"footer,omitempty"`
-	Name       *string                         `json:"name,omitempty"`
-	Disabled   *bool                           `json:"disabled,omitempty"`
-	Minimized  *bool                           `json:"minimized,omitempty"`
-	DeviceName *string                         `json:"device_name,omitempty"`
+	UUID          uuid.UUID                       `json:"uuid"`
+	ParentUUID    *uuid.UUID                      `json:"parent_uuid,omitempty"`
+	Status        *string                         `json:"status,omitempty"`
+	Type          *model.WorkflowNodeType         `json:"type,omitempty"`
+	Icon          *string                         `json:"icon,omitempty"`
+	Pose          *datatypes.JSONType[model.Pose] `json:"pose,omitempty" swaggertype:"object"`
+	Param         *datatypes.JSON                 `json:"param,omitempty" swaggertype:"object"`
+	Footer        *string                         `json:"footer,omitempty"`
+	Name          *string                         `json:"name,omitempty"`
+	Disabled      *bool                           `json:"disabled,omitempty"`
+	Minimized     *bool                           `json:"minimized,omitempty"`
+	DeviceName    *string                         `json:"device_name,omitempty"`
+	RetryPolicy   *model.RetryPolicy              `json:"retry_policy,omitempty"`
+	TimeoutSecond *int                            `json:"timeout_second,omitempty"`
+	FailurePolicy *model.FailurePolicy            `json:"failure_policy,omitempty"`
 }
 
 type WSDelNodes struct {
@@ -257,12 +271,13 @@ type ListResult struct {
 
 // 工作流详情响应
 type DetailResp struct {
-	UUID        uuid.UUID `json:"uuid"`
-	Name        string    `json:"name"`
-	Description *string   `json:"description,omitempty"`
-	UserID      string    `json:"user_id"`
-	Nodes       []*WSNode `json:"nodes"`
-	Edges       []*WSEdge `json:"edges"`
+	UUID        uuid.UUID             `json:"uuid"`
+	Name        string                `json:"name"`
+	Description *string               `json:"description,omitempty"`
+	UserID      string                `json:"user_id"`
+	Nodes       []*WSNode             `json:"nodes"`
+	Edges       []*WSEdge             `json:"edges"`
+	Params      []model.WorkflowParam `json:"params"`
 }
 
 // 获取任务列表
@@ -271,6 +286,11 @@ type TaskReq struct {
 	common.PageReq
 }
 
+// 暂停、继续 task
+type TaskControlReq struct {
+	UUID uuid.UUID `json:"uuid" uri:"uuid" form:"uuid" binding:"required"`
+}
+
 // 下载 task
 type TaskDownloadReq struct {
 	UUID uuid.UUID `json:"uuid" uri:"uuid" form:"uuid" binding:"required"`
@@ -279,15 +299,54 @@ type TaskDownloadReq struct {
 type TaskResp struct {
 	UUID       uuid.UUID                `json:"uuid"`
 	Status     model.WorkflowTaskStatus `json:"status"`
+	Simulated  bool                     `json:"simulated"`
+	CreatedAt  time.Time                `json:"created_at"`
+	FinishedAt time.Time                `json:"finished_at"`
+}
+
+// 任务状态，运行中的任务附带调度实例返回的实时状态
+type TaskStatusResp struct {
+	UUID   uuid.UUID                `json:"uuid"`
+	Status model.WorkflowTaskStatus `json:"status"`
+	Live   *engine.TaskStatus       `json:"live"` // 任务未运行时为空
+}
+
+// 任务详情，包含运行时的工作流快照和每个 job 的输入
+type TaskDetailResp struct {
+	UUID       uuid.UUID                `json:"uuid"`
+	Status     model.WorkflowTaskStatus `json:"status"`
+	Simulated  bool                     `json:"simulated"`
+	Params     map[string]any           `json:"params"`
+	Snapshot   *model.WorkflowSnapshot  `json:"snapshot"` // 快照功能上线前的任务为空
 	CreatedAt  time.Time                `json:"created_at"`
 	FinishedAt time.Time                `json:"finished_at"`
+	Jobs       []*TaskJobResp           `json:"jobs"`
+}
+
+type TaskJobResp struct {
+	UUID         uuid.UUID               `json:"uuid"`
+	NodeUUID     uuid.UUID               `json:"node_uuid"`
+	ParentJobID  int64                   `json:"parent_job_id"`
+	Iteration    int                     `json:"iteration"`
+	Status       model.WorkflowJobStatus `json:"status"`
+	Inputs       datatypes.JSON          `json:"inputs"`
+	ReturnInfo   model.ReturnInfo        `json:"return_info"`
+	Attempt      int                     `json:"attempt"`
+	Attempts     []model.JobAttempt      `json:"attempts"`
+	QueuedAt     *time.Time              `json:"queued_at"`
+	DispatchedAt *time.Time              `json:"dispatched_at"`
+	StartedAt    *time.Time              `json:"started_at"`
+	FinishedAt   *time.Time              `json:"finished_at"`
 }
 
 type UpdateReq struct {
-	UUID        uuid.UUID `json:"uuid" binding:"required"`
-	Name        *string   `json:"name"`
-	Published   *bool     `json:"published"`
-	Description *string   `json:"description"`
+	UUID          uuid.UUID              `json:"uuid" binding:"required"`
+	Name          *string                `json:"name"`
+	Published     *bool                  `json:"published"`
+	Description   *string                `json:"description"`
+	TimeoutSecond *int                   `json:"timeout_second"`
+	Params        *[]model.WorkflowParam `json:"params"` // 运行参数声明，整体覆盖
+	FailurePolicy *model.FailurePolicy   `json:"failure_policy"`
 }
 
 type DelReq struct {
@@ -374,6 +433,7 @@ type ExportEdge struct {
 	SourceHandleIO  string `json:"source_handle_io"`
 	TargetHandleKey string `json:"target_handle_key"`
 	TargetHandleIO  string `json:"target_handle_io"`
+	Expression      string `json:"expression"`
 }
 
 type ExportData struct {
@@ -391,5 +451,172 @@ type ImportReq struct {
 }
 
 type RunReq struct {
-	WorkflowUUID uuid.UUID `json:"workflow_uuid" binding:"required"`
+	WorkflowUUID    uuid.UUID             `json:"workflow_uuid" binding:"required"`
+	SourceTaskUUID  uuid.UUID             `json:"source_task_uuid"`  // 从该任务的失败处重跑，为空则完整运行
+	RestartNodeUUID uuid.UUID             `json:"restart_node_uuid"` // 重跑起点节点，为空则从失败节点开始
+	Simulate        *model.SimulateConfig `json:"simulate"`          // 不为空时使用 mock edge 仿真运行
+	Params          map[string]any        `json:"params"`            // 运行参数值，未传入的使用默认值
+}
+
+// websocket 运行工作流
+type WSRunReq struct {
+	Params map[string]any `json:"params"` // 运行参数值，未传入的使用默认值
+}
+
+// 运行前校验工作流
+type ValidateReq struct {
+	WorkflowUUID uuid.UUID      `json:"workflow_uuid" binding:"required"`
+	Params       map[string]any `json:"params"` // 运行参数值，未传入的使用默认值
+}
+
+type ValidateResp struct {
+	Valid       bool                 `json:"valid"`
+	Diagnostics []*engine.Diagnostic `json:"diagnostics"`
+}
+
+// websocket 仿真运行工作流
+type WSSimulateReq struct {
+	model.SimulateConfig
+	Params map[string]any `json:"params"`
+}
+
+// 从失败处重跑
+type RerunReq struct {
+	TaskUUID uuid.UUID `json:"task_uuid"` // 源任务 uuid
+	NodeUUID uuid.UUID `json:"node_uuid"` // 重跑起点节点，为空则从失败节点开始
+}
+
+// 定时触发
+type CreateTriggerReq struct {
+	WorkflowUUID   uuid.UUID               `json:"workflow_uuid" binding:"required"`
+	Name           string                  `json:"name"`
+	Type           model.TriggerType       `json:"type" binding:"required"`
+	CronExpr       string                  `json:"cron_expr"`       // type 为 cron 时必填
+	IntervalSecond int64                   `json:"interval_second"` // type 为 interval 时必填
+	Timezone       string                  `json:"timezone"`        // cron 表达式所在时区，默认 UTC
+	EndTime        *time.Time              `json:"end_time"`
+	Enabled        *bool                   `json:"enabled"` // 默认启用
+	MissPolicy     model.TriggerMissPolicy `json:"miss_policy"`
+	Params         map[string]any          `json:"params"`
+}
+
+type UpdateTriggerReq struct {
+	UUID           uuid.UUID                `json:"uuid" uri:"uuid" binding:"required"`
+	Name           *string                  `json:"name"`
+	Type           *model.TriggerType       `json:"type"`
+	CronExpr       *string                  `json:"cron_expr"`
+	IntervalSecond *int64                   `json:"interval_second"`
+	Timezone       *string                  `json:"timezone"`
+	EndTime        *time.Time               `json:"end_time"`
+	ClearEndTime   bool                     `json:"clear_end_time"` // 清除截止时间
+	Enabled        *bool                    `json:"enabled"`
+	MissPolicy     *model.TriggerMissPolicy `json:"miss_policy"`
+	Params         *map[string]any          `json:"params"`
+}
+
+type TriggerReq struct {
+	UUID uuid.UUID `json:"uuid" uri:"uuid" binding:"required"`
+}
+
+type TriggerListReq struct {
+	WorkflowUUID uuid.UUID `json:"workflow_uuid" uri:"uuid" binding:"required"`
+}
+
+type TriggerRecordReq struct {
+	UUID uuid.UUID `json:"uuid" uri:"uuid" form:"uuid" binding:"required"`
+	common.PageReq
+}
+
+type TriggerResp struct {
+	UUID           uuid.UUID               `json:"uuid"`
+	WorkflowUUID   uuid.UUID               `json:"workflow_uuid"`
+	Name           string                  `json:"name"`
+	Type           model.TriggerType       `json:"type"`
+	CronExpr       string                  `json:"cron_expr"`
+	IntervalSecond int64                   `json:"interval_second"`
+	Timezone       string                  `json:"timezone"`
+	EndTime        *time.Time              `json:"end_time"`
+	Enabled        bool                    `json:"enabled"`
+	MissPolicy     model.TriggerMissPolicy `json:"miss_policy"`
+	Params         map[string]any          `json:"params"`
+	NextRunTime    *time.Time              `json:"next_run_time"`
+	LastRunTime    *time.Time              `json:"last_run_time"`
+	Queued         bool                    `json:"queued"`
+	CreatedAt      time.Time               `json:"created_at"`
+}
+
+type TriggerRecordResp struct {
+	UUID      uuid.UUID                 `json:"uuid"`
+	TaskUUID  uuid.UUID                 `json:"task_uuid"`
+	FireTime  time.Time                 `json:"fire_time"`
+	Status    model.TriggerRecordStatus `json:"status"`
+	Message   string                    `json:"message"`
+	CreatedAt time.Time                 `json:"created_at"`
+}
+
+// webhook 触发鉴权信息，由请求头和原始请求体构造
+type WebhookAuth struct {
+	Token      string
+	Signature  string
+	Body       []byte
+	RemoteAddr string
+}
+
+type CreateTokenReq struct {
+	WorkflowUUID     uuid.UUID         `json:"workflow_uuid" binding:"required"`
+	Name             string            `json:"name"`
+	RequireSignature bool              `json:"require_signature"` // 为 true 时必须携带签名
+	ParamMapping     map[string]string `json:"param_mapping"`     // 参数名到请求体 gjson 路径，为空时请求体即为参数
+}
+
+// token 和 secret 只在创建时返回
+type CreateTokenResp struct {
+	UUID   uuid.UUID `json:"uuid"`
+	Token  string    `json:"token"`
+	Secret string    `json:"secret"`
+}
+
+type TokenReq struct {
+	UUID uuid.UUID `json:"uuid" uri:"uuid" binding:"required"`
+}
+
+type TokenListReq struct {
+	WorkflowUUID uuid.UUID `json:"workflow_uuid" uri:"uuid" binding:"required"`
+}
+
+type TokenResp struct {
+	UUID             uuid.UUID      `json:"uuid"`
+	Name             string         `json:"name"`
+	Prefix           string         `json:"prefix"`
+	RequireSignature bool           `json:"require_signature"`
+	ParamMapping     map[string]any `json:"param_mapping"`
+	Revoked          bool           `json:"revoked"`
+	RevokedAt        *time.Time     `json:"revoked_at"`
+	LastUsedAt       *time.Time     `json:"last_used_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+}
+
+// 人工节点确认或拒绝，确认时提交的表单作为节点输出
+type ManualDecisionReq struct {
+	UUID    uuid.UUID      `json:"uuid" uri:"uuid" binding:"required"` // 人工处理记录 uuid
+	Confirm bool           `json:"confirm"`
+	Input   map[string]any `json:"input"`
+	Comment string         `json:"comment"`
+}
+
+type ManualResp struct {
+	UUID       uuid.UUID                  `json:"uuid"`
+	NodeUUID   uuid.UUID                  `json:"node_uuid"`
+	Title      string                     `json:"title"`
+	Message    string                     `json:"message"`
+	Form       []model.ManualField        `json:"form"`
+	Role       model.LaboratoryMemberRole `json:"role"`
+	Deadline   *time.Time                 `json:"deadline"`
+	OnTimeout  model.ManualTimeoutPolicy  `json:"on_timeout"`
+	Status     model.ManualStatus         `json:"status"`
+	Input      map[string]any             `json:"input"`
+	Comment    string                     `json:"comment"`
+	OperatorID string                     `json:"operator_id"`
+	DecidedAt  *time.Time                 `json:"decided_at"`
+	CreatedAt  time.Time                  `json:"created_at"`
 }
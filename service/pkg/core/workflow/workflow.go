@@ -31,5 +31,22 @@ type Service interface {
 	DuplicateWorkflow(ctx context.Context, req *DuplicateReq) (*DuplicateRes, error)
 	ExportWorkflow(ctx context.Context, req *ExportReq) (*ExportData, error)
 	ImportWorkflow(ctx context.Context, req *ImportReq) (*CreateResp, error)
-	HttpRunWorkflow(ctx context.Context, req *RunReq) (uuid.UUID, error)
+	HttpRunWorkflow(ctx context.Context, auth *WebhookAuth, req *RunReq) (uuid.UUID, error)
+	PauseWorkflowTask(ctx context.Context, req *TaskControlReq) error
+	ResumeWorkflowTask(ctx context.Context, req *TaskControlReq) error
+	CreateTrigger(ctx context.Context, req *CreateTriggerReq) (*TriggerResp, error)
+	UpdateTrigger(ctx context.Context, req *UpdateTriggerReq) error
+	DelTrigger(ctx context.Context, req *TriggerReq) error
+	TriggerList(ctx context.Context, req *TriggerListReq) ([]*TriggerResp, error)
+	TriggerRecords(ctx context.Context, req *TriggerRecordReq) (*common.PageMoreResp[[]*TriggerRecordResp], error)
+	CreateTriggerToken(ctx context.Context, req *CreateTokenReq) (*CreateTokenResp, error)
+	RevokeTriggerToken(ctx context.Context, req *TokenReq) error
+	TriggerTokenList(ctx context.Context, req *TokenListReq) ([]*TokenResp, error)
+	WebhookTrigger(ctx context.Context, auth *WebhookAuth) (uuid.UUID, error)
+	WebhookTask(ctx context.Context, auth *WebhookAuth, req *TaskControlReq) (*TaskResp, error)
+	TaskStatus(ctx context.Context, req *TaskControlReq) (*TaskStatusResp, error)
+	TaskDetail(ctx context.Context, req *TaskControlReq) (*TaskDetailResp, error)
+	ManualDecision(ctx context.Context, req *ManualDecisionReq) error
+	ManualList(ctx context.Context, req *TaskControlReq) ([]*ManualResp, error)
+	ValidateWorkflow(ctx context.Context, req *ValidateReq) (*ValidateResp, error)
 }
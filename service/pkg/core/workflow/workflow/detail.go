@@ -0,0 +1,95 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/workflow"
+	"github.com/scienceol/studio/service/pkg/middleware/auth"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+// 任务详情，返回运行时的工作流快照和每个 job 实际下发的输入
+func (w *workflowImpl) TaskDetail(ctx context.Context, req *workflow.TaskControlReq) (*workflow.TaskDetailResp, error) {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin
+	}
+
+	task := &model.WorkflowTask{}
+	if err := w.workflowStore.GetData(ctx, task, map[string]any{
+		"uuid": req.UUID,
+	}); err != nil {
+		return nil, code.WorkflowTaskNotFoundErr
+	}
+
+	if err := w.checkLabMember(ctx, task.LabID, userInfo.ID); err != nil {
+		return nil, err
+	}
+
+	resp := &workflow.TaskDetailResp{
+		UUID:       task.UUID,
+		Status:     task.Status,
+		Simulated:  task.Simulated,
+		Params:     task.Params,
+		CreatedAt:  task.CreatedAt,
+		FinishedAt: task.FinishedTime,
+	}
+
+	if len(task.Snapshot) > 0 && string(task.Snapshot) != "null" {
+		resp.Snapshot = &model.WorkflowSnapshot{}
+		if err := json.Unmarshal(task.Snapshot, resp.Snapshot); err != nil {
+			logger.Errorf(ctx, "TaskDetail unmarshal snapshot task uuid: %s, err: %+v", task.UUID, err)
+			return nil, code.WorkflowSnapshotErr.WithErr(err)
+		}
+	}
+
+	jobs := make([]*model.WorkflowNodeJob, 0, 10)
+	if err := w.workflowStore.FindDatas(ctx, &jobs, map[string]any{
+		"workflow_task_id": task.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	// 优先使用快照中的节点，工作流修改后仍能对应到运行时的节点
+	nodeUUIDMap := make(map[int64]uuid.UUID)
+	if resp.Snapshot != nil {
+		for _, node := range resp.Snapshot.Nodes {
+			nodeUUIDMap[node.ID] = node.UUID
+		}
+	}
+
+	missIDs := utils.FilterUniqSlice(jobs, func(job *model.WorkflowNodeJob) (int64, bool) {
+		_, ok := nodeUUIDMap[job.NodeID]
+		return job.NodeID, !ok
+	})
+	if len(missIDs) > 0 {
+		for id, nodeUUID := range w.workflowStore.ID2UUID(ctx, &model.WorkflowNode{}, missIDs...) {
+			nodeUUIDMap[id] = nodeUUID
+		}
+	}
+
+	resp.Jobs = utils.FilterSlice(jobs, func(job *model.WorkflowNodeJob) (*workflow.TaskJobResp, bool) {
+		return &workflow.TaskJobResp{
+			UUID:         job.UUID,
+			NodeUUID:     nodeUUIDMap[job.NodeID],
+			ParentJobID:  job.ParentJobID,
+			Iteration:    job.Iteration,
+			Status:       job.Status,
+			Inputs:       job.Inputs,
+			ReturnInfo:   job.ReturnInfo.Data(),
+			Attempt:      job.Attempt,
+			Attempts:     job.Attempts,
+			QueuedAt:     job.QueuedAt,
+			DispatchedAt: job.DispatchedAt,
+			StartedAt:    job.StartedAt,
+			FinishedAt:   job.FinishedAt,
+		}, true
+	})
+
+	return resp, nil
+}
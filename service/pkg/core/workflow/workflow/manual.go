@@ -0,0 +1,210 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/scienceol/studio/service/internal/config"
+	"github.com/scienceol/studio/service/pkg/common"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/core/workflow"
+	"github.com/scienceol/studio/service/pkg/middleware/auth"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+/*
+	人工节点处理
+1. 引擎运行到人工节点时创建待处理记录，并通过工作流广播通知前端
+2. 实验室成员确认或拒绝后，经全局工作流队列投递到运行根任务的调度实例
+3. 处理记录的最终状态由引擎写入，接口只做校验和投递
+*/
+
+func (w *workflowImpl) ManualDecision(ctx context.Context, req *workflow.ManualDecisionReq) error {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return code.UnLogin
+	}
+
+	approval := &model.WorkflowManualApproval{}
+	if err := w.workflowStore.GetData(ctx, approval, map[string]any{
+		"uuid": req.UUID,
+	}); err != nil {
+		return code.ManualApprovalNotFoundErr
+	}
+
+	if approval.Status != model.ManualWaiting ||
+		(approval.Deadline != nil && approval.Deadline.Before(time.Now())) {
+		return code.ManualApprovalFinishedErr
+	}
+
+	if err := w.checkManualOperator(ctx, approval, userInfo.ID); err != nil {
+		return err
+	}
+
+	input := map[string]any{}
+	if req.Confirm {
+		var err error
+		if input, err = manualInput(approval.Form, req.Input); err != nil {
+			return err
+		}
+	}
+
+	task := &model.WorkflowTask{}
+	if err := w.workflowStore.GetData(ctx, task, map[string]any{
+		"id": approval.TaskID,
+	}, "uuid", "status"); err != nil {
+		return code.WorkflowTaskNotFoundErr
+	}
+
+	switch task.Status {
+	case model.WorkflowTaskStatusRunnig, model.WorkflowTaskStatusPaused:
+	case model.WorkflowTaskStatusCanceled, model.WorkflowTaskStatusFailed,
+		model.WorkflowTaskStatusSuccessed, model.WorkflowTaskStatusTimeout:
+		return code.WorkflowTaskFinished
+	default:
+		return code.WorkflowTaskStatusErr
+	}
+
+	labUUID, ok := w.workflowStore.ID2UUID(ctx, &model.Laboratory{}, approval.LabID)[approval.LabID]
+	if !ok {
+		return code.ParamErr.WithMsg("can not get lab info")
+	}
+
+	dataB, _ := json.Marshal(&engine.WorkflowInfo{
+		Action:   engine.ManualJob,
+		TaskUUID: task.UUID,
+		LabUUID:  labUUID,
+		UserID:   userInfo.ID,
+		Manual: &engine.ManualDecision{
+			ApprovalUUID: approval.UUID,
+			Confirm:      req.Confirm,
+			Input:        input,
+			Comment:      req.Comment,
+			UserID:       userInfo.ID,
+		},
+	})
+	if err := w.rClient.LPush(ctx, config.Global().Job.JobQueueName, dataB).Err(); err != nil {
+		return code.ParamErr.WithMsgf("push workflow redis msg err: %+v", err)
+	}
+
+	return nil
+}
+
+// 工作流 websocket 中处理人工节点
+func (w *workflowImpl) manualDecision(ctx context.Context, b []byte) (any, error) {
+	req := &common.WSData[workflow.ManualDecisionReq]{}
+	if err := json.Unmarshal(b, req); err != nil || req.Data.UUID.IsNil() {
+		return nil, code.ParamErr
+	}
+
+	if err := w.ManualDecision(ctx, &req.Data); err != nil {
+		return nil, err
+	}
+
+	return req.Data.UUID, nil
+}
+
+// 任务的人工处理记录，子工作流中的记录归属根任务
+func (w *workflowImpl) ManualList(ctx context.Context, req *workflow.TaskControlReq) ([]*workflow.ManualResp, error) {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin
+	}
+
+	task := &model.WorkflowTask{}
+	if err := w.workflowStore.GetData(ctx, task, map[string]any{
+		"uuid": req.UUID,
+	}, "id", "lab_id"); err != nil {
+		return nil, code.WorkflowTaskNotFoundErr
+	}
+
+	if err := w.checkLabMember(ctx, task.LabID, userInfo.ID); err != nil {
+		return nil, err
+	}
+
+	approvals := make([]*model.WorkflowManualApproval, 0, 1)
+	if err := w.workflowStore.FindDatas(ctx, &approvals, map[string]any{
+		"task_id": task.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	return utils.FilterSlice(approvals, func(approval *model.WorkflowManualApproval) (*workflow.ManualResp, bool) {
+		return &workflow.ManualResp{
+			UUID:       approval.UUID,
+			NodeUUID:   approval.NodeUUID,
+			Title:      approval.Title,
+			Message:    approval.Message,
+			Form:       approval.Form,
+			Role:       approval.Role,
+			Deadline:   approval.Deadline,
+			OnTimeout:  approval.OnTimeout,
+			Status:     approval.Status,
+			Input:      approval.Input,
+			Comment:    approval.Comment,
+			OperatorID: approval.OperatorID,
+			DecidedAt:  approval.DecidedAt,
+			CreatedAt:  approval.CreatedAt,
+		}, true
+	}), nil
+}
+
+// 指定管理员角色时只有实验室管理员可以处理
+func (w *workflowImpl) checkManualOperator(ctx context.Context, approval *model.WorkflowManualApproval, userID string) error {
+	if approval.Role == model.LaboratoryMemberAdmin {
+		return w.checkLabAdmin(ctx, approval.LabID, userID)
+	}
+
+	return w.checkLabMember(ctx, approval.LabID, userID)
+}
+
+func (w *workflowImpl) checkLabMember(ctx context.Context, labID int64, userID string) error {
+	count, err := w.workflowStore.Count(ctx, &model.LaboratoryMember{}, map[string]any{
+		"lab_id":  labID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return code.NoPermission
+	}
+
+	return nil
+}
+
+// 按表单校验提交的输入，只保留表单中定义的字段
+func manualInput(form []model.ManualField, input map[string]any) (map[string]any, error) {
+	values := make(map[string]any, len(form))
+	for _, field := range form {
+		value, ok := input[field.Name]
+		if !ok || value == nil {
+			if field.Required {
+				return nil, code.ManualInputErr.WithMsgf("field %s is required", field.Name)
+			}
+			continue
+		}
+
+		switch field.Type {
+		case "string":
+			_, ok = value.(string)
+		case "number":
+			_, ok = value.(float64)
+		case "boolean":
+			_, ok = value.(bool)
+		default:
+			ok = false
+		}
+
+		if !ok {
+			return nil, code.ManualInputErr.WithMsgf("field %s type must be %s", field.Name, field.Type)
+		}
+		values[field.Name] = value
+	}
+
+	return values, nil
+}
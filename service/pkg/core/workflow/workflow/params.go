@@ -0,0 +1,117 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+	"gorm.io/datatypes"
+)
+
+// 工作流运行参数：声明时校验 schema、默认值和绑定节点，运行时解析传入值并保存到任务
+
+func (w *workflowImpl) checkParamDecls(ctx context.Context, wk *model.Workflow, params []model.WorkflowParam) error {
+	names := make(map[string]struct{}, len(params))
+	nodeUUIDs := make([]uuid.UUID, 0, len(params))
+	for _, param := range params {
+		if param.Name == "" {
+			return code.WorkflowParamErr.WithMsg("param name is empty")
+		}
+
+		if _, ok := names[param.Name]; ok {
+			return code.WorkflowParamErr.WithMsgf("duplicate param name: %s", param.Name)
+		}
+		names[param.Name] = struct{}{}
+
+		schema, err := paramSchema(&param)
+		if err != nil {
+			return err
+		}
+
+		if param.Default != nil {
+			if err := utils.ValidateSchema(schema, param.Default, param.Name); err != nil {
+				return code.WorkflowParamErr.WithMsgf("invalid default value: %s", err.Error())
+			}
+		}
+
+		for _, binding := range param.Bindings {
+			if binding.NodeUUID.IsNil() || binding.Path == "" {
+				return code.WorkflowParamErr.WithMsgf("param %s binding node or path is empty", param.Name)
+			}
+			nodeUUIDs = utils.AppendUniqSlice(nodeUUIDs, binding.NodeUUID)
+		}
+	}
+
+	if len(nodeUUIDs) == 0 {
+		return nil
+	}
+
+	nodes, err := w.workflowStore.GetWorkflowNodes(ctx, map[string]any{
+		"workflow_id": wk.ID,
+		"uuid":        nodeUUIDs,
+	}, "uuid")
+	if err != nil {
+		return err
+	}
+
+	if len(nodes) != len(nodeUUIDs) {
+		return code.WorkflowParamErr.WithMsg("param binding node not in workflow")
+	}
+
+	return nil
+}
+
+// 解析运行参数，未传入的使用默认值，按声明的 schema 校验
+func resolveParams(wk *model.Workflow, values map[string]any) (datatypes.JSONMap, error) {
+	declMap := make(map[string]*model.WorkflowParam, len(wk.Params))
+	for i := range wk.Params {
+		declMap[wk.Params[i].Name] = &wk.Params[i]
+	}
+
+	for name := range values {
+		if _, ok := declMap[name]; !ok {
+			return nil, code.WorkflowParamErr.WithMsgf("unknown param: %s", name)
+		}
+	}
+
+	resolved := make(datatypes.JSONMap, len(wk.Params))
+	for _, param := range wk.Params {
+		value, ok := values[param.Name]
+		if !ok {
+			value = param.Default
+		}
+
+		if value == nil {
+			return nil, code.WorkflowParamErr.WithMsgf("param %s is required", param.Name)
+		}
+
+		schema, err := paramSchema(&param)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := utils.ValidateSchema(schema, value, param.Name); err != nil {
+			return nil, code.WorkflowParamErr.WithMsg(err.Error())
+		}
+
+		resolved[param.Name] = value
+	}
+
+	return resolved, nil
+}
+
+func paramSchema(param *model.WorkflowParam) (map[string]any, error) {
+	schema := map[string]any{}
+	if len(param.Schema) == 0 {
+		return schema, nil
+	}
+
+	if err := json.Unmarshal(param.Schema, &schema); err != nil {
+		return nil, code.WorkflowParamErr.WithMsgf("param %s schema invalid", param.Name)
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,115 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/scienceol/studio/service/internal/config"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/core/workflow"
+	"github.com/scienceol/studio/service/pkg/middleware/auth"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+)
+
+/*
+	任务实时状态查询
+1. 向全局工作流队列投递状态查询消息，由运行任务的调度实例从引擎内存读取状态
+2. 调度实例通过广播回复，携带请求 uuid，发起查询的 api 实例据此唤醒等待方
+3. 已结束的任务直接返回数据库中的状态
+*/
+
+// 等待调度实例回复的超时时间
+const taskStatusTimeout = 5 * time.Second
+
+type statusReply struct {
+	UUID uuid.UUID          `json:"uuid"`
+	Data *engine.TaskStatus `json:"data"`
+}
+
+func (w *workflowImpl) TaskStatus(ctx context.Context, req *workflow.TaskControlReq) (*workflow.TaskStatusResp, error) {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin
+	}
+
+	task := &model.WorkflowTask{}
+	if err := w.workflowStore.GetData(ctx, task, map[string]any{
+		"uuid": req.UUID,
+	}, "uuid", "status", "lab_id"); err != nil {
+		return nil, code.WorkflowTaskNotFoundErr
+	}
+
+	if err := w.checkLabMember(ctx, task.LabID, userInfo.ID); err != nil {
+		return nil, err
+	}
+
+	resp := &workflow.TaskStatusResp{
+		UUID:   task.UUID,
+		Status: task.Status,
+	}
+
+	switch task.Status {
+	case model.WorkflowTaskStatusPending, model.WorkflowTaskStatusRunnig, model.WorkflowTaskStatusPaused:
+	default:
+		return resp, nil
+	}
+
+	labUUID, ok := w.workflowStore.ID2UUID(ctx, &model.Laboratory{}, task.LabID)[task.LabID]
+	if !ok {
+		return nil, code.ParamErr.WithMsg("can not get lab info")
+	}
+
+	requestUUID := uuid.NewV4()
+	replyCh := make(chan *engine.TaskStatus, 1)
+	w.statusWaits.Store(requestUUID, replyCh)
+	defer w.statusWaits.Delete(requestUUID)
+
+	dataB, _ := json.Marshal(&engine.WorkflowInfo{
+		Action:      engine.StatusJob,
+		TaskUUID:    task.UUID,
+		LabUUID:     labUUID,
+		UserID:      userInfo.ID,
+		RequestUUID: requestUUID,
+	})
+	if err := w.rClient.LPush(ctx, config.Global().Job.JobQueueName, dataB).Err(); err != nil {
+		return nil, code.ParamErr.WithMsgf("push workflow redis msg err: %+v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, code.QueryTaskStatusTimeoutErr
+	case <-time.After(taskStatusTimeout):
+		return nil, code.QueryTaskStatusTimeoutErr
+	case live := <-replyCh:
+		if live != nil && live.Running {
+			resp.Live = live
+		}
+	}
+
+	return resp, nil
+}
+
+// 调度实例回复的状态，只处理本实例发起的查询
+func (w *workflowImpl) HandleStatusNotify(ctx context.Context, msg string) error {
+	reply := &statusReply{}
+	if err := json.Unmarshal([]byte(msg), reply); err != nil {
+		logger.Errorf(ctx, "HandleStatusNotify unmarshal data err: %+v", err)
+		return err
+	}
+
+	value, ok := w.statusWaits.Load(reply.UUID)
+	if !ok {
+		return nil
+	}
+
+	select {
+	case value.(chan *engine.TaskStatus) <- reply.Data:
+	default:
+	}
+
+	return nil
+}
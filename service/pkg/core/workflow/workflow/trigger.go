@@ -0,0 +1,335 @@
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/core/workflow"
+	"github.com/scienceol/studio/service/pkg/middleware/auth"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+// 定时触发配置，触发由调度服务的 leader 实例执行
+
+// 固定间隔触发的最小间隔
+const minTriggerInterval = 60
+
+func (w *workflowImpl) CreateTrigger(ctx context.Context, req *workflow.CreateTriggerReq) (*workflow.TriggerResp, error) {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin
+	}
+
+	wk, err := w.workflowStore.GetWorkflowByUUID(ctx, req.WorkflowUUID)
+	if err != nil {
+		return nil, code.CanNotGetworkflowErr
+	}
+
+	if err := w.checkLabMember(ctx, wk.LabID, userInfo.ID); err != nil {
+		return nil, err
+	}
+
+	params, err := resolveParams(wk, req.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	trigger := &model.WorkflowTrigger{
+		LabID:          wk.LabID,
+		WorkflowID:     wk.ID,
+		UserID:         userInfo.ID,
+		Name:           req.Name,
+		Type:           req.Type,
+		CronExpr:       req.CronExpr,
+		IntervalSecond: req.IntervalSecond,
+		Timezone:       utils.Or(req.Timezone, "UTC"),
+		EndTime:        req.EndTime,
+		Enabled:        utils.SafeValue(func() bool { return *req.Enabled }, true),
+		MissPolicy:     utils.Or(req.MissPolicy, model.TriggerMissSkip),
+		Params:         params,
+	}
+
+	if err := checkTrigger(trigger); err != nil {
+		return nil, err
+	}
+
+	if err := w.workflowStore.CreateData(ctx, trigger); err != nil {
+		return nil, err
+	}
+
+	return triggerResp(trigger, wk), nil
+}
+
+func (w *workflowImpl) UpdateTrigger(ctx context.Context, req *workflow.UpdateTriggerReq) error {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return code.UnLogin
+	}
+
+	trigger := &model.WorkflowTrigger{}
+	if err := w.workflowStore.GetData(ctx, trigger, map[string]any{
+		"uuid": req.UUID,
+	}); err != nil {
+		return code.WorkflowTriggerNotFoundErr
+	}
+
+	if err := w.checkTriggerOperator(ctx, trigger, userInfo.ID); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, 10)
+	if req.Name != nil {
+		trigger.Name = *req.Name
+		keys = append(keys, "name")
+	}
+
+	if req.Type != nil {
+		trigger.Type = *req.Type
+		keys = append(keys, "type")
+	}
+
+	if req.CronExpr != nil {
+		trigger.CronExpr = *req.CronExpr
+		keys = append(keys, "cron_expr")
+	}
+
+	if req.IntervalSecond != nil {
+		trigger.IntervalSecond = *req.IntervalSecond
+		keys = append(keys, "interval_second")
+	}
+
+	if req.Timezone != nil {
+		trigger.Timezone = utils.Or(*req.Timezone, "UTC")
+		keys = append(keys, "timezone")
+	}
+
+	if req.EndTime != nil || req.ClearEndTime {
+		trigger.EndTime = req.EndTime
+		keys = append(keys, "end_time")
+	}
+
+	if req.Enabled != nil {
+		trigger.Enabled = *req.Enabled
+		keys = append(keys, "enabled")
+	}
+
+	if req.MissPolicy != nil {
+		trigger.MissPolicy = *req.MissPolicy
+		keys = append(keys, "miss_policy")
+	}
+
+	if req.Params != nil {
+		wk, err := w.workflowStore.GetWorkflowByUUID(ctx, w.workflowStore.ID2UUID(ctx, &model.Workflow{}, trigger.WorkflowID)[trigger.WorkflowID])
+		if err != nil {
+			return code.CanNotGetworkflowErr
+		}
+
+		params, err := resolveParams(wk, *req.Params)
+		if err != nil {
+			return err
+		}
+		trigger.Params = params
+		keys = append(keys, "params")
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// 触发时间相关配置变化后重新计算下次触发时间
+	trigger.NextRunTime = time.Time{}
+	if err := checkTrigger(trigger); err != nil {
+		return err
+	}
+
+	// 停用或不再排队时丢弃等待中的触发
+	if !trigger.Enabled || trigger.MissPolicy != model.TriggerMissQueue {
+		trigger.Queued = false
+	}
+
+	return w.workflowStore.UpdateData(ctx, trigger, map[string]any{
+		"id": trigger.ID,
+	}, append(keys, "next_run_time", "queued", "updated_at")...)
+}
+
+func (w *workflowImpl) DelTrigger(ctx context.Context, req *workflow.TriggerReq) error {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return code.UnLogin
+	}
+
+	trigger := &model.WorkflowTrigger{}
+	if err := w.workflowStore.GetData(ctx, trigger, map[string]any{
+		"uuid": req.UUID,
+	}, "id", "lab_id", "user_id"); err != nil {
+		return code.WorkflowTriggerNotFoundErr
+	}
+
+	if err := w.checkTriggerOperator(ctx, trigger, userInfo.ID); err != nil {
+		return err
+	}
+
+	return w.workflowStore.ExecTx(ctx, func(txCtx context.Context) error {
+		if err := w.workflowStore.DelData(txCtx, &model.WorkflowTriggerRecord{}, map[string]any{
+			"trigger_id": trigger.ID,
+		}); err != nil {
+			return err
+		}
+
+		return w.workflowStore.DelData(txCtx, &model.WorkflowTrigger{}, map[string]any{
+			"id": trigger.ID,
+		})
+	})
+}
+
+func (w *workflowImpl) TriggerList(ctx context.Context, req *workflow.TriggerListReq) ([]*workflow.TriggerResp, error) {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin
+	}
+
+	wk, err := w.workflowStore.GetWorkflowByUUID(ctx, req.WorkflowUUID)
+	if err != nil {
+		return nil, code.CanNotGetworkflowErr
+	}
+
+	if err := w.checkLabMember(ctx, wk.LabID, userInfo.ID); err != nil {
+		return nil, err
+	}
+
+	triggers := make([]*model.WorkflowTrigger, 0, 1)
+	if err := w.workflowStore.FindDatas(ctx, &triggers, map[string]any{
+		"workflow_id": wk.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	return utils.FilterSlice(triggers, func(trigger *model.WorkflowTrigger) (*workflow.TriggerResp, bool) {
+		return triggerResp(trigger, wk), true
+	}), nil
+}
+
+func (w *workflowImpl) TriggerRecords(ctx context.Context,
+	req *workflow.TriggerRecordReq) (*common.PageMoreResp[[]*workflow.TriggerRecordResp],
+	error,
+) {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin
+	}
+
+	trigger := &model.WorkflowTrigger{}
+	if err := w.workflowStore.GetData(ctx, trigger, map[string]any{
+		"uuid": req.UUID,
+	}, "id", "lab_id"); err != nil {
+		return nil, code.WorkflowTriggerNotFoundErr
+	}
+
+	if err := w.checkLabMember(ctx, trigger.LabID, userInfo.ID); err != nil {
+		return nil, err
+	}
+
+	resp, err := w.workflowStore.GetTriggerRecords(ctx, &common.PageReqT[int64]{
+		PageReq: req.PageReq,
+		Data:    trigger.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	taskIDs := utils.FilterUniqSlice(resp.Data, func(record *model.WorkflowTriggerRecord) (int64, bool) {
+		return record.TaskID, record.TaskID > 0
+	})
+	taskMap := w.workflowStore.ID2UUID(ctx, &model.WorkflowTask{}, taskIDs...)
+
+	return &common.PageMoreResp[[]*workflow.TriggerRecordResp]{
+		HasMore:  resp.HasMore,
+		Page:     resp.Page,
+		PageSize: resp.PageSize,
+		Data: utils.FilterSlice(resp.Data, func(record *model.WorkflowTriggerRecord) (*workflow.TriggerRecordResp, bool) {
+			return &workflow.TriggerRecordResp{
+				UUID:      record.UUID,
+				TaskUUID:  taskMap[record.TaskID],
+				FireTime:  record.FireTime,
+				Status:    record.Status,
+				Message:   record.Message,
+				CreatedAt: record.CreatedAt,
+			}, true
+		}),
+	}, nil
+}
+
+// 创建者或实验室管理员可以修改、删除触发配置
+func (w *workflowImpl) checkTriggerOperator(ctx context.Context, trigger *model.WorkflowTrigger, userID string) error {
+	if trigger.UserID == userID {
+		return w.checkLabMember(ctx, trigger.LabID, userID)
+	}
+
+	return w.checkLabAdmin(ctx, trigger.LabID, userID)
+}
+
+// 校验触发配置并计算下次触发时间
+func checkTrigger(trigger *model.WorkflowTrigger) error {
+	switch trigger.Type {
+	case model.TriggerCron:
+		if _, err := utils.ParseCron(trigger.CronExpr); err != nil {
+			return code.WorkflowTriggerErr.WithMsg(err.Error())
+		}
+	case model.TriggerInterval:
+		if trigger.IntervalSecond < minTriggerInterval {
+			return code.WorkflowTriggerErr.WithMsgf("interval second must be at least %d", minTriggerInterval)
+		}
+	default:
+		return code.WorkflowTriggerErr.WithMsgf("unknown trigger type: %s", trigger.Type)
+	}
+
+	if _, err := time.LoadLocation(trigger.Timezone); err != nil {
+		return code.WorkflowTriggerErr.WithMsgf("invalid timezone: %s", trigger.Timezone)
+	}
+
+	switch trigger.MissPolicy {
+	case model.TriggerMissSkip, model.TriggerMissQueue:
+	default:
+		return code.WorkflowTriggerErr.WithMsgf("unknown miss policy: %s", trigger.MissPolicy)
+	}
+
+	next, err := trigger.NextRunAfter(time.Now())
+	if err != nil {
+		return code.WorkflowTriggerErr.WithMsg(err.Error())
+	}
+
+	if next.IsZero() && trigger.Enabled {
+		return code.WorkflowTriggerErr.WithMsg("trigger will never fire before end time")
+	}
+	trigger.NextRunTime = next
+
+	return nil
+}
+
+func triggerResp(trigger *model.WorkflowTrigger, wk *model.Workflow) *workflow.TriggerResp {
+	resp := &workflow.TriggerResp{
+		UUID:           trigger.UUID,
+		WorkflowUUID:   wk.UUID,
+		Name:           trigger.Name,
+		Type:           trigger.Type,
+		CronExpr:       trigger.CronExpr,
+		IntervalSecond: trigger.IntervalSecond,
+		Timezone:       trigger.Timezone,
+		EndTime:        trigger.EndTime,
+		Enabled:        trigger.Enabled,
+		MissPolicy:     trigger.MissPolicy,
+		Params:         trigger.Params,
+		LastRunTime:    trigger.LastRunTime,
+		Queued:         trigger.Queued,
+		CreatedAt:      trigger.CreatedAt,
+	}
+
+	if trigger.Enabled && !trigger.NextRunTime.IsZero() {
+		resp.NextRunTime = &trigger.NextRunTime
+	}
+
+	return resp
+}
@@ -0,0 +1,161 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/olahol/melody"
+	"github.com/scienceol/studio/service/pkg/common"
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
+	"github.com/scienceol/studio/service/pkg/core/schedule/engine/dag"
+	"github.com/scienceol/studio/service/pkg/core/workflow"
+	"github.com/scienceol/studio/service/pkg/middleware/auth"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+	"github.com/tidwall/gjson"
+)
+
+// 运行前校验工作流，返回每个节点的问题
+func (w *workflowImpl) ValidateWorkflow(ctx context.Context, req *workflow.ValidateReq) (*workflow.ValidateResp, error) {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin
+	}
+
+	wk, err := w.workflowStore.GetWorkflowByUUID(ctx, req.WorkflowUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.checkLabMember(ctx, wk.LabID, userInfo.ID); err != nil {
+		return nil, err
+	}
+
+	return w.checkWorkflow(ctx, wk, req.Params)
+}
+
+func (w *workflowImpl) validateWorkflow(ctx context.Context, s *melody.Session, b []byte) (any, error) {
+	req := &common.WSData[workflow.WSRunReq]{}
+	if err := json.Unmarshal(b, req); err != nil {
+		return nil, code.ParamErr.WithMsg(err.Error())
+	}
+
+	wk, err := w.getWorkflow(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.checkWorkflow(ctx, wk, req.Data.Params)
+}
+
+// 执行与运行时相同的检查，并检查实验室是否在线
+func (w *workflowImpl) checkWorkflow(ctx context.Context, wk *model.Workflow, values map[string]any) (*workflow.ValidateResp, error) {
+	diagnostics := make([]*engine.Diagnostic, 0, 4)
+
+	lab, err := w.labStore.GetLabByID(ctx, wk.LabID, "id", "is_online")
+	if err != nil {
+		return nil, err
+	}
+	if !lab.IsOnline {
+		diagnostics = append(diagnostics, &engine.Diagnostic{
+			Code: code.LabOfflineErr,
+			Msg:  code.LabOfflineErr.String(),
+		})
+	}
+
+	params, err := resolveParams(wk, values)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeDiagnostics, err := dag.Validate(ctx, wk.UUID, params)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics = append(diagnostics, nodeDiagnostics...)
+
+	return &workflow.ValidateResp{
+		Valid:       len(diagnostics) == 0,
+		Diagnostics: diagnostics,
+	}, nil
+}
+
+// 子工作流节点只能引用同一实验室的工作流
+func (w *workflowImpl) checkSubflowRef(ctx context.Context, s *melody.Session, reqData *workflow.WSUpdateNode) error {
+	var nodeType model.WorkflowNodeType
+	if reqData.Type != nil {
+		nodeType = *reqData.Type
+	} else {
+		node := &model.WorkflowNode{}
+		if err := w.workflowStore.GetData(ctx, node, map[string]any{
+			"uuid": reqData.UUID,
+		}, "id", "type"); err != nil {
+			return err
+		}
+		nodeType = node.Type
+	}
+
+	if nodeType != model.WorkflowSubflow {
+		return nil
+	}
+
+	workflowUUID, err := uuid.FromString(gjson.GetBytes(*reqData.Param, "workflow_uuid").String())
+	if err != nil || workflowUUID.IsNil() {
+		return code.WorkflowNodeSubflowConfigErr.WithMsg("empty workflow uuid")
+	}
+
+	wk, err := w.getWorkflow(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	count, err := w.workflowStore.Count(ctx, &model.Workflow{}, map[string]any{
+		"uuid":   workflowUUID,
+		"lab_id": wk.LabID,
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return code.WorkflowSubflowLabErr.WithMsgf("sub workflow uuid: %s", workflowUUID)
+	}
+
+	return nil
+}
+
+// 检查边两端的句柄存在且类型兼容
+func (w *workflowImpl) checkEdgeHandles(ctx context.Context, edges []*workflow.WSEdge) error {
+	if len(edges) == 0 {
+		return nil
+	}
+
+	handleUUIDs := make([]uuid.UUID, 0, 2*len(edges))
+	for _, edge := range edges {
+		handleUUIDs = utils.AppendUniqSlice(handleUUIDs, edge.SourceHandleUUID, edge.TargetHandleUUID)
+	}
+
+	handles := make([]*model.WorkflowHandleTemplate, 0, len(handleUUIDs))
+	if err := w.workflowStore.FindDatas(ctx, &handles, map[string]any{
+		"uuid": handleUUIDs,
+	}); err != nil {
+		return err
+	}
+	if len(handles) != len(handleUUIDs) {
+		return code.ParamErr.WithMsg("handle not exist")
+	}
+
+	handleMap := utils.Slice2Map(handles, func(h *model.WorkflowHandleTemplate) (uuid.UUID, *model.WorkflowHandleTemplate) {
+		return h.UUID, h
+	})
+
+	for _, edge := range edges {
+		if err := engine.CheckHandleType(handleMap[edge.SourceHandleUUID],
+			handleMap[edge.TargetHandleUUID], edge.Expression); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,341 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"github.com/scienceol/studio/service/pkg/core/workflow"
+	"github.com/scienceol/studio/service/pkg/middleware/auth"
+	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/utils"
+	"github.com/tidwall/gjson"
+	"gorm.io/datatypes"
+)
+
+/*
+	webhook 触发
+1. 实验室管理员为工作流创建触发 token，token 只保存 sha256，明文和签名密钥只在创建时返回
+2. 触发请求需携带 token，可选携带请求体的 HMAC-SHA256 签名，token 要求签名时必须携带
+3. 请求体按 token 的参数映射转换为运行参数，任务以 token 创建者运行，每次触发记录来源
+*/
+
+const (
+	triggerTokenPrefix = "wft_"
+	signaturePrefix    = "sha256="
+)
+
+func (w *workflowImpl) CreateTriggerToken(ctx context.Context, req *workflow.CreateTokenReq) (*workflow.CreateTokenResp, error) {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin
+	}
+
+	wk, err := w.workflowStore.GetWorkflowByUUID(ctx, req.WorkflowUUID)
+	if err != nil {
+		return nil, code.CanNotGetworkflowErr
+	}
+
+	if err := w.checkLabAdmin(ctx, wk.LabID, userInfo.ID); err != nil {
+		return nil, err
+	}
+
+	declMap := utils.Slice2Map(wk.Params, func(param model.WorkflowParam) (string, struct{}) {
+		return param.Name, struct{}{}
+	})
+	mapping := make(datatypes.JSONMap, len(req.ParamMapping))
+	for name, path := range req.ParamMapping {
+		if _, ok := declMap[name]; !ok {
+			return nil, code.WorkflowParamErr.WithMsgf("unknown param: %s", name)
+		}
+		if path == "" {
+			return nil, code.WorkflowParamErr.WithMsgf("param %s mapping path is empty", name)
+		}
+		mapping[name] = path
+	}
+
+	token := triggerTokenPrefix + randomHex(24)
+	secret := randomHex(32)
+	data := &model.WorkflowTriggerToken{
+		LabID:            wk.LabID,
+		WorkflowID:       wk.ID,
+		UserID:           userInfo.ID,
+		Name:             req.Name,
+		TokenHash:        hashToken(token),
+		Prefix:           token[:len(triggerTokenPrefix)+8],
+		Secret:           secret,
+		RequireSignature: req.RequireSignature,
+		ParamMapping:     mapping,
+	}
+	if err := w.workflowStore.CreateData(ctx, data); err != nil {
+		return nil, err
+	}
+
+	return &workflow.CreateTokenResp{
+		UUID:   data.UUID,
+		Token:  token,
+		Secret: secret,
+	}, nil
+}
+
+func (w *workflowImpl) RevokeTriggerToken(ctx context.Context, req *workflow.TokenReq) error {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return code.UnLogin
+	}
+
+	token := &model.WorkflowTriggerToken{}
+	if err := w.workflowStore.GetData(ctx, token, map[string]any{
+		"uuid": req.UUID,
+	}, "id", "lab_id", "revoked"); err != nil {
+		return code.TriggerTokenErr.WithMsg("can not get trigger token")
+	}
+
+	if err := w.checkLabAdmin(ctx, token.LabID, userInfo.ID); err != nil {
+		return err
+	}
+
+	if token.Revoked {
+		return nil
+	}
+
+	now := time.Now()
+	token.Revoked = true
+	token.RevokedAt = &now
+	return w.workflowStore.UpdateData(ctx, token, map[string]any{
+		"id": token.ID,
+	}, "revoked", "revoked_at", "updated_at")
+}
+
+func (w *workflowImpl) TriggerTokenList(ctx context.Context, req *workflow.TokenListReq) ([]*workflow.TokenResp, error) {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin
+	}
+
+	wk, err := w.workflowStore.GetWorkflowByUUID(ctx, req.WorkflowUUID)
+	if err != nil {
+		return nil, code.CanNotGetworkflowErr
+	}
+
+	if err := w.checkLabAdmin(ctx, wk.LabID, userInfo.ID); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]*model.WorkflowTriggerToken, 0, 1)
+	if err := w.workflowStore.FindDatas(ctx, &tokens, map[string]any{
+		"workflow_id": wk.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	return utils.FilterSlice(tokens, func(token *model.WorkflowTriggerToken) (*workflow.TokenResp, bool) {
+		return &workflow.TokenResp{
+			UUID:             token.UUID,
+			Name:             token.Name,
+			Prefix:           token.Prefix,
+			RequireSignature: token.RequireSignature,
+			ParamMapping:     token.ParamMapping,
+			Revoked:          token.Revoked,
+			RevokedAt:        token.RevokedAt,
+			LastUsedAt:       token.LastUsedAt,
+			CreatedAt:        token.CreatedAt,
+		}, true
+	}), nil
+}
+
+// 通过 token 触发工作流，请求体按参数映射转换为运行参数
+func (w *workflowImpl) WebhookTrigger(ctx context.Context, webhookAuth *workflow.WebhookAuth) (uuid.UUID, error) {
+	token, err := w.verifyWebhook(ctx, webhookAuth)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	taskUUID, err := w.webhookRunTask(ctx, token, webhookAuth.Body)
+	w.recordWebhook(ctx, token, webhookAuth, taskUUID, err)
+
+	return taskUUID, err
+}
+
+func (w *workflowImpl) webhookRunTask(ctx context.Context, token *model.WorkflowTriggerToken, body []byte) (uuid.UUID, error) {
+	wk := &model.Workflow{}
+	if err := w.workflowStore.GetData(ctx, wk, map[string]any{
+		"id": token.WorkflowID,
+	}); err != nil {
+		return uuid.UUID{}, code.CanNotGetworkflowErr
+	}
+
+	values, err := webhookParams(token.ParamMapping, body)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	params, err := resolveParams(wk, values)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return w.createRunTask(ctx, wk, token.UserID, params)
+}
+
+// 查询 token 所属工作流触发的任务状态
+func (w *workflowImpl) WebhookTask(ctx context.Context, webhookAuth *workflow.WebhookAuth, req *workflow.TaskControlReq) (*workflow.TaskResp, error) {
+	token, err := w.verifyWebhook(ctx, webhookAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	task := &model.WorkflowTask{}
+	if err := w.workflowStore.GetData(ctx, task, map[string]any{
+		"uuid":        req.UUID,
+		"workflow_id": token.WorkflowID,
+	}, "uuid", "status", "simulated", "created_at", "finished_time"); err != nil {
+		return nil, code.WorkflowTaskNotFoundErr
+	}
+
+	return &workflow.TaskResp{
+		UUID:       task.UUID,
+		Status:     task.Status,
+		Simulated:  task.Simulated,
+		CreatedAt:  task.CreatedAt,
+		FinishedAt: task.FinishedTime,
+	}, nil
+}
+
+// 校验 token 和签名，token 要求签名或请求携带签名时校验签名
+func (w *workflowImpl) verifyWebhook(ctx context.Context, webhookAuth *workflow.WebhookAuth) (*model.WorkflowTriggerToken, error) {
+	if webhookAuth == nil || webhookAuth.Token == "" {
+		return nil, code.TriggerTokenErr.WithMsg("trigger token is empty")
+	}
+
+	token := &model.WorkflowTriggerToken{}
+	if err := w.workflowStore.GetData(ctx, token, map[string]any{
+		"token_hash": hashToken(webhookAuth.Token),
+		"revoked":    false,
+	}); err != nil {
+		return nil, code.TriggerTokenErr
+	}
+
+	if verifySignature(token, webhookAuth) {
+		return token, nil
+	}
+
+	w.recordWebhook(ctx, token, webhookAuth, uuid.UUID{}, code.TriggerSignatureErr)
+	return nil, code.TriggerSignatureErr
+}
+
+// 签名为请求体的 HMAC-SHA256 十六进制值，可带 sha256= 前缀
+// token 未要求签名且请求未携带签名时通过，携带签名时始终校验
+func verifySignature(token *model.WorkflowTriggerToken, webhookAuth *workflow.WebhookAuth) bool {
+	if webhookAuth.Signature == "" {
+		return !token.RequireSignature
+	}
+
+	signature, err := hex.DecodeString(strings.TrimPrefix(webhookAuth.Signature, signaturePrefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(token.Secret))
+	mac.Write(webhookAuth.Body)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// 记录 webhook 触发结果并更新 token 使用时间
+func (w *workflowImpl) recordWebhook(ctx context.Context, token *model.WorkflowTriggerToken,
+	webhookAuth *workflow.WebhookAuth, taskUUID uuid.UUID, runErr error,
+) {
+	now := time.Now()
+	record := &model.WorkflowTriggerRecord{
+		Source:     model.TriggerSourceWebhook,
+		TokenID:    token.ID,
+		FireTime:   now,
+		Status:     model.TriggerRecordTriggered,
+		RemoteAddr: webhookAuth.RemoteAddr,
+	}
+	if runErr != nil {
+		record.Status = model.TriggerRecordFailed
+		record.Message = runErr.Error()
+	} else {
+		record.TaskID = w.workflowStore.UUID2ID(ctx, &model.WorkflowTask{}, taskUUID)[taskUUID]
+	}
+
+	if err := w.workflowStore.CreateData(ctx, record); err != nil {
+		logger.Errorf(ctx, "recordWebhook token uuid: %s, err: %+v", token.UUID, err)
+	}
+
+	token.LastUsedAt = &now
+	if err := w.workflowStore.UpdateData(ctx, token, map[string]any{
+		"id": token.ID,
+	}, "last_used_at"); err != nil {
+		logger.Errorf(ctx, "recordWebhook update token uuid: %s, err: %+v", token.UUID, err)
+	}
+}
+
+func (w *workflowImpl) checkLabAdmin(ctx context.Context, labID int64, userID string) error {
+	count, err := w.workflowStore.Count(ctx, &model.LaboratoryMember{}, map[string]any{
+		"lab_id":  labID,
+		"user_id": userID,
+		"role":    model.LaboratoryMemberAdmin,
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return code.NoPermission
+	}
+
+	return nil
+}
+
+// 请求体转换为运行参数值，未映射到的参数使用默认值
+func webhookParams(mapping datatypes.JSONMap, body []byte) (map[string]any, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return map[string]any{}, nil
+	}
+
+	if len(mapping) == 0 {
+		values := map[string]any{}
+		if err := json.Unmarshal(body, &values); err != nil {
+			return nil, code.WorkflowParamErr.WithMsg("payload must be a json object")
+		}
+		return values, nil
+	}
+
+	if !gjson.ValidBytes(body) {
+		return nil, code.WorkflowParamErr.WithMsg("payload is not valid json")
+	}
+
+	values := make(map[string]any, len(mapping))
+	for name, path := range mapping {
+		p, _ := path.(string)
+		res := gjson.GetBytes(body, p)
+		if !res.Exists() {
+			continue
+		}
+		values[name] = res.Value()
+	}
+
+	return values, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,103 @@
+// notlint:revive
+package workflow
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/scienceol/studio/service/pkg/core/workflow"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"sample": "A1"}`)
+	valid := sign("secret", body)
+
+	cases := []struct {
+		name      string
+		require   bool
+		signature string
+		body      []byte
+		want      bool
+	}{
+		{name: "no signature not required", require: false, signature: "", body: body, want: true},
+		{name: "no signature required", require: true, signature: "", body: body, want: false},
+		{name: "valid", require: true, signature: valid, body: body, want: true},
+		{name: "valid with prefix", require: true, signature: "sha256=" + valid, body: body, want: true},
+		{name: "valid not required", require: false, signature: valid, body: body, want: true},
+		{name: "bad signature not required", require: false, signature: sign("other", body), body: body, want: false},
+		{name: "other secret", require: true, signature: sign("other", body), body: body, want: false},
+		{name: "body changed", require: true, signature: valid, body: []byte(`{"sample": "A2"}`), want: false},
+		{name: "bad hex", require: true, signature: "sha256=zz" + valid[2:], body: body, want: false},
+		{name: "prefix only", require: true, signature: "sha256=", body: body, want: false},
+		{name: "other prefix", require: true, signature: "sha1=" + valid, body: body, want: false},
+		{name: "truncated", require: true, signature: valid[:32], body: body, want: false},
+	}
+
+	for _, c := range cases {
+		token := &model.WorkflowTriggerToken{
+			Secret:           "secret",
+			RequireSignature: c.require,
+		}
+		got := verifySignature(token, &workflow.WebhookAuth{
+			Signature: c.signature,
+			Body:      c.body,
+		})
+		assert.Equal(t, c.want, got, c.name)
+	}
+}
+
+func TestWebhookParams(t *testing.T) {
+	cases := []struct {
+		name    string
+		mapping datatypes.JSONMap
+		body    string
+		want    map[string]any
+		wantErr bool
+	}{
+		{name: "empty body", body: "  ", want: map[string]any{}},
+		{name: "no mapping uses body", body: `{"volume": 1.5, "name": "A"}`, want: map[string]any{"volume": 1.5, "name": "A"}},
+		{name: "no mapping not object", body: `[1, 2]`, wantErr: true},
+		{name: "no mapping invalid json", body: `{"volume":`, wantErr: true},
+		{
+			name:    "mapping paths",
+			mapping: datatypes.JSONMap{"volume": "data.volume", "first": "data.samples.0", "count": "data.samples.#"},
+			body:    `{"data": {"volume": 2, "samples": ["A1", "B1"]}}`,
+			want:    map[string]any{"volume": 2.0, "first": "A1", "count": 2.0},
+		},
+		{
+			name:    "mapping missing path uses default",
+			mapping: datatypes.JSONMap{"volume": "data.volume", "name": "data.name"},
+			body:    `{"data": {"volume": 2}}`,
+			want:    map[string]any{"volume": 2.0},
+		},
+		{
+			name:    "mapping keeps object",
+			mapping: datatypes.JSONMap{"sample": "sample"},
+			body:    `{"sample": {"id": "A1", "tags": ["x"]}}`,
+			want:    map[string]any{"sample": map[string]any{"id": "A1", "tags": []any{"x"}}},
+		},
+		{name: "mapping invalid json", mapping: datatypes.JSONMap{"volume": "volume"}, body: `{"volume":`, wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := webhookParams(c.mapping, []byte(c.body))
+		if c.wantErr {
+			assert.Error(t, err, c.name)
+			continue
+		}
+
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.want, got, c.name)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/olahol/melody"
@@ -17,6 +18,7 @@ import (
 	"github.com/scienceol/studio/service/internal/config"
 	"github.com/scienceol/studio/service/pkg/common"
 	"github.com/scienceol/studio/service/pkg/common/code"
+	"github.com/scienceol/studio/service/pkg/common/expr"
 	"github.com/scienceol/studio/service/pkg/common/uuid"
 	"github.com/scienceol/studio/service/pkg/core/notify"
 	"github.com/scienceol/studio/service/pkg/core/notify/events"
@@ -42,6 +44,7 @@ type workflowImpl struct {
 	tagsStore     repo.Tags
 	rClient       *r.Client
 	wsClient      *melody.Melody
+	statusWaits   sync.Map // 等待调度回复的状态查询，key 为请求 uuid
 	*schemaHelper
 }
 
@@ -60,6 +63,9 @@ func New(ctx context.Context, wsClient *melody.Melody) workflow.Service {
 	if err := events.NewEvents().Registry(ctx, notify.WorkflowRun, w.HandleNotify); err != nil {
 		logger.Errorf(ctx, "worflow Registry WorkflowRun fail err: %+v", err)
 	}
+	if err := events.NewEvents().Registry(ctx, notify.WorkflowStatus, w.HandleStatusNotify); err != nil {
+		logger.Errorf(ctx, "worflow Registry WorkflowStatus fail err: %+v", err)
+	}
 	return w
 }
 
@@ -281,10 +287,22 @@ func (w *workflowImpl) OnWSMsg(ctx context.Context, s *melody.Session, b []byte)
 		data, err = w.runWorkflow(ctx, s, b)
 	case workflow.StopWorkflow:
 		data, err = w.stopWorkflow(ctx, s, b)
+	case workflow.PauseWorkflow:
+		data, err = w.controlWorkflow(ctx, s, b, engine.PauseJob)
+	case workflow.ResumeWorkflow:
+		data, err = w.controlWorkflow(ctx, s, b, engine.ContinueJob)
+	case workflow.RerunWorkflow:
+		data, err = w.rerunWorkflow(ctx, s, b)
+	case workflow.SimulateWorkflow:
+		data, err = w.simulateWorkflow(ctx, s, b)
 	case workflow.FetchWorkflowStatus:
 		data, err = w.fetchWorkflowTask(ctx, s)
 	case workflow.Dumplicate:
 		data, err = w.duplicateNode(ctx, b)
+	case workflow.ManualDecision:
+		data, err = w.manualDecision(ctx, b)
+	case workflow.ValidateWorkflow:
+		data, err = w.validateWorkflow(ctx, s, b)
 
 	default:
 		return common.ReplyWSErr(s, msgType.Action, msgType.MsgUUID, code.UnknownWSActionErr)
@@ -327,19 +345,22 @@ func (w *workflowImpl) fetchGraph(ctx context.Context, s *melody.Session) (any,
 
 	nodes := utils.FilterSlice(resp.Nodes, func(node *repo.WorkflowNodeInfo) (*workflow.WSNode, bool) {
 		data := &workflow.WSNode{
-			UUID:        node.Node.UUID,
-			ParentUUID:  nodeIDUUIDMap[node.Node.ParentID],
-			UserID:      node.Node.UserID,
-			Status:      node.Node.Status,
-			Type:        node.Node.Type,
-			Icon:        node.Node.Icon,
-			Pose:        node.Node.Pose,
-			Footer:      utils.Or(node.Node.Footer, ""),
-			Param:       node.Node.Param,
-			DeviceName:  node.Node.DeviceName,
-			LabNodeType: node.Node.LabNodeType,
-			Disabled:    node.Node.Disabled,
-			Minimized:   node.Node.Minimized,
+			UUID:          node.Node.UUID,
+			ParentUUID:    nodeIDUUIDMap[node.Node.ParentID],
+			UserID:        node.Node.UserID,
+			Status:        node.Node.Status,
+			Type:          node.Node.Type,
+			Icon:          node.Node.Icon,
+			Pose:          node.Node.Pose,
+			Footer:        utils.Or(node.Node.Footer, ""),
+			Param:         node.Node.Param,
+			DeviceName:    node.Node.DeviceName,
+			LabNodeType:   node.Node.LabNodeType,
+			Disabled:      node.Node.Disabled,
+			Minimized:     node.Node.Minimized,
+			RetryPolicy:   node.Node.RetryPolicy.Data(),
+			TimeoutSecond: node.Node.TimeoutSecond,
+			FailurePolicy: node.Node.FailurePolicy,
 			Handles: utils.FilterSlice(node.Handles, func(h *model.WorkflowHandleTemplate) (*workflow.WSNodeHandle, bool) {
 				return &workflow.WSNodeHandle{
 					UUID:        h.UUID,
@@ -380,6 +401,7 @@ func (w *workflowImpl) fetchGraph(ctx context.Context, s *melody.Session) (any,
 			TargetNodeUUID:   edge.TargetNodeUUID,
 			SourceHandleUUID: edge.SourceHandleUUID,
 			TargetHandleUUID: edge.TargetHandleUUID,
+			Expression:       edge.Expression,
 		}, true
 	})
 
@@ -690,7 +712,7 @@ func (w *workflowImpl) createNode(ctx context.Context, s *melody.Session, b []by
 }
 
 // 更新工作流节点
-func (w *workflowImpl) upateNode(ctx context.Context, _ *melody.Session, b []byte) (any, error) {
+func (w *workflowImpl) upateNode(ctx context.Context, s *melody.Session, b []byte) (any, error) {
 	req := &common.WSData[*workflow.WSUpdateNode]{}
 	if err := json.Unmarshal(b, req); err != nil {
 		return nil, code.ParamErr.WithMsg(err.Error())
@@ -732,6 +754,9 @@ func (w *workflowImpl) upateNode(ctx context.Context, _ *melody.Session, b []byt
 	}
 
 	if reqData.Param != nil {
+		if err := w.checkSubflowRef(ctx, s, reqData); err != nil {
+			return nil, err
+		}
 		d.Param = *reqData.Param
 		keys = append(keys, "param")
 	}
@@ -761,6 +786,32 @@ func (w *workflowImpl) upateNode(ctx context.Context, _ *melody.Session, b []byt
 		keys = append(keys, "device_name")
 	}
 
+	if reqData.RetryPolicy != nil {
+		policy := reqData.RetryPolicy
+		if policy.MaxAttempts < 0 || policy.BackoffSecond < 0 ||
+			policy.BackoffFactor < 0 || policy.MaxBackoffSecond < 0 {
+			return nil, code.ParamErr.WithMsg("invalid retry policy")
+		}
+		d.RetryPolicy = datatypes.NewJSONType(*policy)
+		keys = append(keys, "retry_policy")
+	}
+
+	if reqData.TimeoutSecond != nil {
+		if *reqData.TimeoutSecond < 0 {
+			return nil, code.ParamErr.WithMsg("invalid timeout second")
+		}
+		d.TimeoutSecond = *reqData.TimeoutSecond
+		keys = append(keys, "timeout_second")
+	}
+
+	if reqData.FailurePolicy != nil {
+		if !validFailurePolicy(*reqData.FailurePolicy, true) {
+			return nil, code.ParamErr.WithMsg("invalid failure policy")
+		}
+		d.FailurePolicy = *reqData.FailurePolicy
+		keys = append(keys, "failure_policy")
+	}
+
 	if len(keys) == 0 {
 		return nil, nil
 	}
@@ -805,7 +856,6 @@ func (w *workflowImpl) batchCreateEdge(ctx context.Context, _ *melody.Session, b
 	}
 
 	nodeUUIDs := make([]uuid.UUID, 0, 2*len(req.Data))
-	handleUUIDs := make([]uuid.UUID, 0, 2*len(req.Data))
 	for _, edge := range req.Data {
 		if edge.SourceHandleUUID.IsNil() ||
 			edge.TargetHandleUUID.IsNil() ||
@@ -815,8 +865,11 @@ func (w *workflowImpl) batchCreateEdge(ctx context.Context, _ *melody.Session, b
 			return nil, code.ParamErr.WithMsg("uuid is empty")
 		}
 
+		if err := checkEdgeExpression(edge); err != nil {
+			return nil, err
+		}
+
 		nodeUUIDs = utils.AppendUniqSlice(nodeUUIDs, edge.SourceNodeUUID, edge.TargetNodeUUID)
-		handleUUIDs = utils.AppendUniqSlice(handleUUIDs, edge.SourceHandleUUID, edge.TargetHandleUUID)
 	}
 
 	count, err := w.workflowStore.Count(ctx, &model.WorkflowNode{}, map[string]any{"uuid": nodeUUIDs})
@@ -824,11 +877,9 @@ func (w *workflowImpl) batchCreateEdge(ctx context.Context, _ *melody.Session, b
 		return nil, code.ParamErr.WithMsg("node uuid not exist")
 	}
 
-	// count, err = w.workflowStore.Count(ctx, &model.WorkflowHandleTemplate{}, map[string]any{"uuid": handleUUIDs})
-	// if err != nil || count != int64(len(handleUUIDs)) {
-	// 	common.ReplyWSErr(s, string(workflow.BatchCreateEdge), req.MsgUUID, code.ParamErr.WithMsg("handle templet uuid not exist"))
-	// 	return code.ParamErr.WithMsg("handle templet not exist")
-	// }
+	if err := w.checkEdgeHandles(ctx, req.Data); err != nil {
+		return nil, err
+	}
 
 	edgeDatas := utils.FilterSlice(req.Data, func(edge *workflow.WSEdge) (*model.WorkflowEdge, bool) {
 		return &model.WorkflowEdge{
@@ -836,6 +887,7 @@ func (w *workflowImpl) batchCreateEdge(ctx context.Context, _ *melody.Session, b
 			TargetNodeUUID:   edge.TargetNodeUUID,
 			SourceHandleUUID: edge.SourceHandleUUID,
 			TargetHandleUUID: edge.TargetHandleUUID,
+			Expression:       edge.Expression,
 		}, true
 	})
 
@@ -850,12 +902,26 @@ func (w *workflowImpl) batchCreateEdge(ctx context.Context, _ *melody.Session, b
 			TargetNodeUUID:   data.TargetNodeUUID,
 			SourceHandleUUID: data.SourceHandleUUID,
 			TargetHandleUUID: data.TargetHandleUUID,
+			Expression:       data.Expression,
 		}, true
 	})
 
 	return respDatas, nil
 }
 
+// 保存时编译边上的表达式，提前暴露语法错误和未知变量
+func checkEdgeExpression(edge *workflow.WSEdge) error {
+	if edge.Expression == "" {
+		return nil
+	}
+
+	if _, err := expr.Compile(edge.Expression, engine.EdgeExpressionVars...); err != nil {
+		return code.EdgeExpressionErr.WithMsgf("expression: %s, err: %v", edge.Expression, err)
+	}
+
+	return nil
+}
+
 // 批量删除边
 func (w *workflowImpl) batchDelEdge(ctx context.Context, _ *melody.Session, b []byte) (any, error) {
 	req := &common.WSData[[]uuid.UUID]{}
@@ -899,7 +965,7 @@ func (w *workflowImpl) batchSave(ctx context.Context, _ *melody.Session, b []byt
 }
 
 func (w *workflowImpl) runWorkflow(ctx context.Context, s *melody.Session, b []byte) (any, error) {
-	req := &common.WSData[any]{}
+	req := &common.WSData[workflow.WSRunReq]{}
 	if err := json.Unmarshal(b, req); err != nil {
 		return nil, code.ParamErr.WithMsg(err.Error())
 	}
@@ -924,6 +990,11 @@ func (w *workflowImpl) runWorkflow(ctx context.Context, s *melody.Session, b []b
 	//
 	// utils.Range(nodes)
 
+	params, err := resolveParams(wk, req.Data.Params)
+	if err != nil {
+		return nil, err
+	}
+
 	labMap := w.workflowStore.ID2UUID(ctx, &model.Laboratory{}, wk.LabID)
 
 	labUUID, ok := labMap[wk.LabID]
@@ -938,6 +1009,7 @@ func (w *workflowImpl) runWorkflow(ctx context.Context, s *melody.Session, b []b
 			LabID:      wk.LabID,
 			WorkflowID: wk.ID,
 			UserID:     userInfo.ID,
+			Params:     params,
 		}
 		if err := w.workflowStore.CreateWorkflowTask(txCtx, task); err != nil {
 			return err
@@ -970,20 +1042,54 @@ func (w *workflowImpl) runWorkflow(ctx context.Context, s *melody.Session, b []b
 	return taskUUID, nil
 }
 
-// HttpRunWorkflow 通过 HTTP 启动工作流（无鉴权）
-func (w *workflowImpl) HttpRunWorkflow(ctx context.Context, req *workflow.RunReq) (uuid.UUID, error) {
+// HttpRunWorkflow 通过 HTTP 启动工作流，需携带该工作流的触发 token
+func (w *workflowImpl) HttpRunWorkflow(ctx context.Context, auth *workflow.WebhookAuth, req *workflow.RunReq) (uuid.UUID, error) {
 	if req == nil || req.WorkflowUUID.IsNil() {
 		return uuid.UUID{}, code.ParamErr.WithMsg("workflow uuid is empty")
 	}
 
+	token, err := w.verifyWebhook(ctx, auth)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
 	wk, err := w.workflowStore.GetWorkflowByUUID(ctx, req.WorkflowUUID)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
 
-	// 基于工作流记录的创建者作为 user_id（无 token 情况）
-	userID := wk.UserID
+	if wk.ID != token.WorkflowID {
+		return uuid.UUID{}, code.TriggerTokenErr.WithMsg("token not belong to workflow")
+	}
+
+	taskUUID, err := w.httpRunTask(ctx, wk, token.UserID, req)
+	w.recordWebhook(ctx, token, auth, taskUUID, err)
+
+	return taskUUID, err
+}
 
+func (w *workflowImpl) httpRunTask(ctx context.Context, wk *model.Workflow, userID string, req *workflow.RunReq) (uuid.UUID, error) {
+	if !req.SourceTaskUUID.IsNil() {
+		return w.createRerunTask(ctx, wk, userID, &workflow.RerunReq{
+			TaskUUID: req.SourceTaskUUID,
+			NodeUUID: req.RestartNodeUUID,
+		})
+	}
+
+	params, err := resolveParams(wk, req.Params)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	if req.Simulate != nil {
+		return w.createSimulateTask(ctx, wk, userID, req.Simulate, params)
+	}
+
+	return w.createRunTask(ctx, wk, userID, params)
+}
+
+// 创建任务并投递到全局工作流队列
+func (w *workflowImpl) createRunTask(ctx context.Context, wk *model.Workflow, userID string, params datatypes.JSONMap) (uuid.UUID, error) {
 	// 获取 lab uuid
 	labMap := w.workflowStore.ID2UUID(ctx, &model.Laboratory{}, wk.LabID)
 	labUUID, ok := labMap[wk.LabID]
@@ -992,8 +1098,8 @@ func (w *workflowImpl) HttpRunWorkflow(ctx context.Context, req *workflow.RunReq
 	}
 
 	var taskUUID uuid.UUID
-	err = w.workflowStore.ExecTx(ctx, func(txCtx context.Context) error {
-		task := &model.WorkflowTask{LabID: wk.LabID, WorkflowID: wk.ID, UserID: userID}
+	err := w.workflowStore.ExecTx(ctx, func(txCtx context.Context) error {
+		task := &model.WorkflowTask{LabID: wk.LabID, WorkflowID: wk.ID, UserID: userID, Params: params}
 		if err := w.workflowStore.CreateWorkflowTask(txCtx, task); err != nil {
 			return err
 		}
@@ -1021,6 +1127,203 @@ func (w *workflowImpl) HttpRunWorkflow(ctx context.Context, req *workflow.RunReq
 	return taskUUID, nil
 }
 
+func (w *workflowImpl) rerunWorkflow(ctx context.Context, s *melody.Session, b []byte) (any, error) {
+	req := &common.WSData[workflow.RerunReq]{}
+	if err := json.Unmarshal(b, req); err != nil || req.Data.TaskUUID.IsNil() {
+		return nil, code.ParamErr
+	}
+
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin.WithMsg("can not get user info")
+	}
+
+	wk, err := w.getWorkflow(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.createRerunTask(ctx, wk, userInfo.ID, &req.Data)
+}
+
+// 创建关联源任务的新任务，调度引擎复制源任务成功节点的结果后只运行失败节点及其下游
+func (w *workflowImpl) createRerunTask(ctx context.Context, wk *model.Workflow, userID string, req *workflow.RerunReq) (uuid.UUID, error) {
+	source := &model.WorkflowTask{}
+	if err := w.workflowStore.GetData(ctx, source, map[string]any{
+		"uuid":        req.TaskUUID,
+		"workflow_id": wk.ID,
+	}, "id", "status", "simulated", "params", "snapshot"); err != nil {
+		return uuid.UUID{}, code.WorkflowTaskNotFoundErr.WithMsg("can not get source workflow task")
+	}
+
+	// 重跑按源任务的快照运行；快照功能上线前的任务没有快照，退回按工作流当前数据运行
+	snapshot := &model.WorkflowSnapshot{}
+	if len(source.Snapshot) == 0 || string(source.Snapshot) == "null" {
+		logger.Warnf(ctx, "createRerunTask source task uuid: %s has no snapshot, use current workflow", req.TaskUUID)
+		nodes, err := w.workflowStore.GetWorkflowNodes(ctx, map[string]any{
+			"workflow_id": wk.ID,
+		}, "id", "uuid")
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+		snapshot.Nodes = nodes
+	} else if err := json.Unmarshal(source.Snapshot, snapshot); err != nil {
+		return uuid.UUID{}, code.WorkflowSnapshotErr.WithErr(err)
+	}
+
+	if source.Simulated {
+		return uuid.UUID{}, code.WorkflowTaskStatusErr.WithMsg("can not rerun simulated task")
+	}
+
+	switch source.Status {
+	case model.WorkflowTaskStatusPending, model.WorkflowTaskStatusRunnig, model.WorkflowTaskStatusPaused:
+		return uuid.UUID{}, code.WorkflowTaskStatusErr.WithMsg("source task not finished")
+	case model.WorkflowTaskStatusSuccessed:
+		if req.NodeUUID.IsNil() {
+			return uuid.UUID{}, code.WorkflowTaskStatusErr.WithMsg("source task successed, restart node is required")
+		}
+	}
+
+	var restartNodeID int64
+	if !req.NodeUUID.IsNil() {
+		for _, node := range snapshot.Nodes {
+			if node.UUID == req.NodeUUID {
+				restartNodeID = node.ID
+				break
+			}
+		}
+
+		if restartNodeID == 0 {
+			return uuid.UUID{}, code.WorkflowNodeNotFoundErr.WithMsgf("restart node uuid: %s", req.NodeUUID)
+		}
+	}
+
+	labUUID, ok := w.workflowStore.ID2UUID(ctx, &model.Laboratory{}, wk.LabID)[wk.LabID]
+	if !ok {
+		return uuid.UUID{}, code.ParamErr.WithMsg("can not get lab uuid")
+	}
+
+	var taskUUID uuid.UUID
+	err := w.workflowStore.ExecTx(ctx, func(txCtx context.Context) error {
+		task := &model.WorkflowTask{
+			LabID:         wk.LabID,
+			WorkflowID:    wk.ID,
+			UserID:        userID,
+			SourceTaskID:  source.ID,
+			RestartNodeID: restartNodeID,
+			Params:        source.Params,
+		}
+		if err := w.workflowStore.CreateWorkflowTask(txCtx, task); err != nil {
+			return err
+		}
+		taskUUID = task.UUID
+
+		data := engine.WorkflowInfo{
+			Action:       engine.StartJob,
+			TaskUUID:     task.UUID,
+			WorkflowUUID: wk.UUID,
+			LabUUID:      labUUID,
+			UserID:       userID,
+		}
+		dataB, _ := json.Marshal(data)
+		if err := w.rClient.LPush(ctx, config.Global().Job.JobQueueName, dataB).Err(); err != nil {
+			return code.ParamErr.WithMsgf("push workflow redis msg err: %+v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return taskUUID, nil
+}
+
+func (w *workflowImpl) simulateWorkflow(ctx context.Context, s *melody.Session, b []byte) (any, error) {
+	req := &common.WSData[workflow.WSSimulateReq]{}
+	if err := json.Unmarshal(b, req); err != nil {
+		return nil, code.ParamErr.WithMsg(err.Error())
+	}
+
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin.WithMsg("can not get user info")
+	}
+
+	wk, err := w.getWorkflow(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := resolveParams(wk, req.Data.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.createSimulateTask(ctx, wk, userInfo.ID, &req.Data.SimulateConfig, params)
+}
+
+// 创建仿真任务，调度服务使用 mock edge 运行，结果按普通任务保存
+func (w *workflowImpl) createSimulateTask(ctx context.Context, wk *model.Workflow, userID string,
+	conf *model.SimulateConfig, params datatypes.JSONMap,
+) (uuid.UUID, error) {
+	if conf.DelayMs < 0 || conf.JitterMs < 0 || conf.FailureRate < 0 || conf.FailureRate > 1 {
+		return uuid.UUID{}, code.ParamErr.WithMsg("invalid simulate config")
+	}
+
+	labUUID, ok := w.workflowStore.ID2UUID(ctx, &model.Laboratory{}, wk.LabID)[wk.LabID]
+	if !ok {
+		return uuid.UUID{}, code.ParamErr.WithMsg("can not get lab uuid")
+	}
+
+	var taskUUID uuid.UUID
+	err := w.workflowStore.ExecTx(ctx, func(txCtx context.Context) error {
+		task := &model.WorkflowTask{
+			LabID:      wk.LabID,
+			WorkflowID: wk.ID,
+			UserID:     userID,
+			Simulated:  true,
+			Simulate:   datatypes.NewJSONType(*conf),
+			Params:     params,
+		}
+		if err := w.workflowStore.CreateWorkflowTask(txCtx, task); err != nil {
+			return err
+		}
+		taskUUID = task.UUID
+
+		data := engine.WorkflowInfo{
+			Action:       engine.SimulateJob,
+			TaskUUID:     task.UUID,
+			WorkflowUUID: wk.UUID,
+			LabUUID:      labUUID,
+			UserID:       userID,
+		}
+		dataB, _ := json.Marshal(data)
+		if err := w.rClient.LPush(ctx, config.Global().Job.JobQueueName, dataB).Err(); err != nil {
+			return code.ParamErr.WithMsgf("push workflow redis msg err: %+v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return taskUUID, nil
+}
+
+// 节点可以不配置策略，使用工作流的策略
+func validFailurePolicy(policy model.FailurePolicy, allowInherit bool) bool {
+	switch policy {
+	case model.FailureFailFast, model.FailureContinueBranches, model.FailureIgnoreError:
+		return true
+	case model.FailureInherit:
+		return allowInherit
+	default:
+		return false
+	}
+}
+
 func (w *workflowImpl) stopWorkflow(ctx context.Context, s *melody.Session, b []byte) (any, error) {
 	req := &common.WSData[uuid.UUID]{}
 	if err := json.Unmarshal(b, req); err != nil || req.Data.IsNil() {
@@ -1053,7 +1356,7 @@ func (w *workflowImpl) stopWorkflow(ctx context.Context, s *melody.Session, b []
 
 	task := tasks[0]
 	switch task.Status {
-	case model.WorkflowTaskStatusPending, model.WorkflowTaskStatusRunnig:
+	case model.WorkflowTaskStatusPending, model.WorkflowTaskStatusRunnig, model.WorkflowTaskStatusPaused:
 	case model.WorkflowTaskStatusCanceled, model.WorkflowTaskStatusFailed, model.WorkflowTaskStatusSuccessed:
 		return nil, code.WorkflowTaskFinished
 	default:
@@ -1094,6 +1397,110 @@ func (w *workflowImpl) stopWorkflow(ctx context.Context, s *melody.Session, b []
 	return data.TaskUUID, nil
 }
 
+// 暂停、继续运行工作流任务
+func (w *workflowImpl) controlWorkflow(ctx context.Context, s *melody.Session, b []byte, action engine.WorkflowAction) (any, error) {
+	req := &common.WSData[uuid.UUID]{}
+	if err := json.Unmarshal(b, req); err != nil || req.Data.IsNil() {
+		return nil, code.ParamErr
+	}
+
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return nil, code.UnLogin.WithMsg("can not get user info")
+	}
+
+	wk, err := w.getWorkflow(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.pushTaskControl(ctx, wk, req.Data, action); err != nil {
+		return nil, err
+	}
+
+	return req.Data, nil
+}
+
+func (w *workflowImpl) PauseWorkflowTask(ctx context.Context, req *workflow.TaskControlReq) error {
+	return w.httpControlTask(ctx, req, engine.PauseJob)
+}
+
+func (w *workflowImpl) ResumeWorkflowTask(ctx context.Context, req *workflow.TaskControlReq) error {
+	return w.httpControlTask(ctx, req, engine.ContinueJob)
+}
+
+func (w *workflowImpl) httpControlTask(ctx context.Context, req *workflow.TaskControlReq, action engine.WorkflowAction) error {
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		return code.UnLogin
+	}
+
+	task := &model.WorkflowTask{}
+	if err := w.workflowStore.GetData(ctx, task, map[string]any{
+		"uuid": req.UUID,
+	}, "workflow_id"); err != nil {
+		return code.WorkflowTaskNotFoundErr.WithMsg("can not get workflow task")
+	}
+
+	wk := &model.Workflow{}
+	if err := w.workflowStore.GetData(ctx, wk, map[string]any{
+		"id": task.WorkflowID,
+	}, "id", "uuid", "lab_id", "user_id"); err != nil {
+		return code.CanNotGetworkflowErr
+	}
+
+	if err := w.checkLabMember(ctx, wk.LabID, userInfo.ID); err != nil {
+		return err
+	}
+
+	return w.pushTaskControl(ctx, wk, req.UUID, action)
+}
+
+// 校验任务状态后投递控制消息，任务状态由调度引擎更新
+func (w *workflowImpl) pushTaskControl(ctx context.Context, wk *model.Workflow, taskUUID uuid.UUID, action engine.WorkflowAction) error {
+	task := &model.WorkflowTask{}
+	if err := w.workflowStore.GetData(ctx, task, map[string]any{
+		"uuid":        taskUUID,
+		"workflow_id": wk.ID,
+	}, "status"); err != nil {
+		return code.WorkflowTaskNotFoundErr.WithMsg("can not get workflow task")
+	}
+
+	expect := model.WorkflowTaskStatusRunnig
+	if action == engine.ContinueJob {
+		expect = model.WorkflowTaskStatusPaused
+	}
+
+	switch task.Status {
+	case expect:
+	case model.WorkflowTaskStatusCanceled, model.WorkflowTaskStatusFailed,
+		model.WorkflowTaskStatusSuccessed, model.WorkflowTaskStatusTimeout:
+		return code.WorkflowTaskFinished
+	default:
+		return code.WorkflowTaskStatusErr
+	}
+
+	labUUID, ok := w.workflowStore.ID2UUID(ctx, &model.Laboratory{}, wk.LabID)[wk.LabID]
+	if !ok {
+		return code.ParamErr.WithMsg("can not get lab info")
+	}
+
+	data := engine.WorkflowInfo{
+		Action:       action,
+		TaskUUID:     taskUUID,
+		WorkflowUUID: wk.UUID,
+		LabUUID:      labUUID,
+		UserID:       wk.UserID,
+	}
+
+	dataB, _ := json.Marshal(data)
+	if err := w.rClient.LPush(ctx, config.Global().Job.JobQueueName, dataB).Err(); err != nil {
+		return code.ParamErr.WithMsgf("push workflow redis msg err: %+v", err)
+	}
+
+	return nil
+}
+
 func (w *workflowImpl) fetchWorkflowTask(ctx context.Context, s *melody.Session) (any, error) {
 	userInfo := auth.GetCurrentUser(ctx)
 	if userInfo == nil {
@@ -1177,6 +1584,9 @@ func (w *workflowImpl) duplicateNode(ctx context.Context, b []byte) (any, error)
 		DeviceName:     sourceNode.DeviceName,
 		ActionName:     sourceNode.ActionName,
 		ActionType:     sourceNode.ActionType,
+		RetryPolicy:    sourceNode.RetryPolicy,
+		TimeoutSecond:  sourceNode.TimeoutSecond,
+		FailurePolicy:  sourceNode.FailurePolicy,
 		Disabled:       false,
 		Minimized:      false,
 	}
@@ -1331,6 +1741,9 @@ func (w *workflowImpl) DuplicateWorkflow(ctx context.Context, req *workflow.Dupl
 					DeviceName:     oldNode.DeviceName,
 					ActionName:     oldNode.ActionName,
 					ActionType:     oldNode.ActionType,
+					RetryPolicy:    oldNode.RetryPolicy,
+					TimeoutSecond:  oldNode.TimeoutSecond,
+					FailurePolicy:  oldNode.FailurePolicy,
 					Disabled:       oldNode.Disabled,
 					Minimized:      oldNode.Minimized,
 
@@ -1367,6 +1780,7 @@ func (w *workflowImpl) DuplicateWorkflow(ctx context.Context, req *workflow.Dupl
 				TargetNodeUUID:   targetNodeUUID,
 				SourceHandleUUID: edgeHandleUUIDMap[edge.SourceHandleUUID],
 				TargetHandleUUID: edgeHandleUUIDMap[edge.TargetHandleUUID],
+				Expression:       edge.Expression,
 			}, true
 		})
 
@@ -1613,6 +2027,10 @@ func (w *workflowImpl) batchSaveEdge(ctx context.Context, edges []*workflow.WSEd
 			return code.ParamErr.WithMsg("edge uuid is empty")
 		}
 
+		if err := checkEdgeExpression(edge); err != nil {
+			return err
+		}
+
 		nodeUUIDs = utils.AppendUniqSlice(nodeUUIDs, edge.SourceNodeUUID)
 		nodeUUIDs = utils.AppendUniqSlice(nodeUUIDs, edge.TargetNodeUUID)
 
@@ -1647,6 +2065,7 @@ func (w *workflowImpl) batchSaveEdge(ctx context.Context, edges []*workflow.WSEd
 			TargetNodeUUID:   edge.TargetNodeUUID,
 			SourceHandleUUID: edge.SourceHandleUUID,
 			TargetHandleUUID: edge.TargetHandleUUID,
+			Expression:       edge.Expression,
 		}, true
 	})
 
@@ -1813,8 +2232,10 @@ func (w *workflowImpl) GetWorkflowDetail(ctx context.Context, req *workflow.Deta
 				TargetNodeUUID:   edge.TargetNodeUUID,
 				SourceHandleUUID: edge.SourceHandleUUID,
 				TargetHandleUUID: edge.TargetHandleUUID,
+				Expression:       edge.Expression,
 			}, true
 		}),
+		Params: wf.Params,
 	}, nil
 }
 
@@ -1940,6 +2361,7 @@ func (w *workflowImpl) ExportWorkflow(ctx context.Context, req *workflow.ExportR
 			SourceHandleIO:  sh.io,
 			TargetHandleKey: th.key,
 			TargetHandleIO:  th.io,
+			Expression:      e.Expression,
 		}, true
 	})
 
@@ -2020,6 +2442,7 @@ func (w *workflowImpl) WorkflowTaskList(ctx context.Context,
 			return &workflow.TaskResp{
 				UUID:       task.UUID,
 				Status:     task.Status,
+				Simulated:  task.Simulated,
 				CreatedAt:  task.CreatedAt,
 				FinishedAt: task.FinishedTime,
 			}, true
@@ -2110,6 +2533,30 @@ func (w *workflowImpl) UpdateWorkflow(ctx context.Context, req *workflow.UpdateR
 		keys = append(keys, "description")
 	}
 
+	if req.TimeoutSecond != nil {
+		if *req.TimeoutSecond < 0 {
+			return code.ParamErr.WithMsg("invalid timeout second")
+		}
+		wk.TimeoutSecond = *req.TimeoutSecond
+		keys = append(keys, "timeout_second")
+	}
+
+	if req.FailurePolicy != nil {
+		if !validFailurePolicy(*req.FailurePolicy, false) {
+			return code.ParamErr.WithMsg("invalid failure policy")
+		}
+		wk.FailurePolicy = *req.FailurePolicy
+		keys = append(keys, "failure_policy")
+	}
+
+	if req.Params != nil {
+		if err := w.checkParamDecls(ctx, wk, *req.Params); err != nil {
+			return err
+		}
+		wk.Params = *req.Params
+		keys = append(keys, "params")
+	}
+
 	if len(keys) == 0 {
 		return nil
 	}
@@ -2373,6 +2820,9 @@ func (w *workflowImpl) ImportWorkflow(ctx context.Context, req *workflow.ImportR
 			if sNew.IsNil() || tNew.IsNil() {
 				continue
 			}
+			if err := checkEdgeExpression(&workflow.WSEdge{Expression: e.Expression}); err != nil {
+				return err
+			}
 			sTplID := newUUID2tplID[sNew]
 			tTplID := newUUID2tplID[tNew]
 			var sHandleUUID, tHandleUUID uuid.UUID
@@ -2406,7 +2856,18 @@ func (w *workflowImpl) ImportWorkflow(ctx context.Context, req *workflow.ImportR
 			if tHandleUUID.IsNil() {
 				return code.ParamErr.WithMsgf("目标节点 '%s' 的句柄匹配失败: handle_key='%s', io_type='%s'，目标实验室中可能不存在对应的句柄配置", tNodeName, e.TargetHandleKey, e.TargetHandleIO)
 			}
-			edgesToCreate = append(edgesToCreate, &model.WorkflowEdge{SourceNodeUUID: sNew, TargetNodeUUID: tNew, SourceHandleUUID: sHandleUUID, TargetHandleUUID: tHandleUUID})
+			edgesToCreate = append(edgesToCreate, &model.WorkflowEdge{SourceNodeUUID: sNew, TargetNodeUUID: tNew, SourceHandleUUID: sHandleUUID, TargetHandleUUID: tHandleUUID, Expression: e.Expression})
+		}
+
+		// 与画布连线相同，检查句柄类型兼容
+		if err := w.checkEdgeHandles(txCtx, utils.FilterSlice(edgesToCreate, func(e *model.WorkflowEdge) (*workflow.WSEdge, bool) {
+			return &workflow.WSEdge{
+				SourceHandleUUID: e.SourceHandleUUID,
+				TargetHandleUUID: e.TargetHandleUUID,
+				Expression:       e.Expression,
+			}, true
+		})); err != nil {
+			return err
 		}
 		if err := w.workflowStore.DuplicateEdge(txCtx, edgesToCreate); err != nil {
 			return err
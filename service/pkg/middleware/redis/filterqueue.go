@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -9,6 +10,22 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/scienceol/studio/service/pkg/common/code"
 	"github.com/scienceol/studio/service/pkg/middleware/logger"
+	"github.com/scienceol/studio/service/pkg/utils"
+)
+
+/*
+	多调度实例按实验室亲和消费全局队列
+1. 每个实例把连接在本实例的实验室加入自己的集合，只认领这些实验室的消息，其他实验室的消息放回队列
+2. 实验室离线时没有实例认领，消息由任意实例处理
+3. 已在某个实例运行的任务登记所属实例，后续消息只由该实例处理
+4. 长时间无实例认领的消息标记过期，由调用方转入死信队列
+*/
+
+const (
+	scheduleLabPrefix   = "lab_websocket_uuid_%s_users"
+	scheduleOwnerPrefix = "schedule_task_owner_"
+	deadLetterSuffix    = "_dead_letter"
+	deadLetterMaxLen    = 1000
 )
 
 type MessageConsumer struct {
@@ -16,90 +33,118 @@ type MessageConsumer struct {
 	luaScript           *redis.Script // lua 脚本
 	scheduleName        string        // 调度器随机名
 	scheduleUserSet     string        // 调度器用户集合名
+	routeField          string        // 消息中实验室 uuid 字段
+	ownerField          string        // 消息中任务 uuid 字段
+	heartPrefix         string        // 实验室心跳 key 前缀
 	retryCount          int
 	maxAttempts         int   // 最大重试次数
-	expireTimeoutSecond int64 // 消息过期时间，毫秒
+	expireTimeoutSecond int64 // 消息过期时间，秒
 	setExpireHours      int   // Set过期时间，小时
 }
 
+type consumeMsg struct {
+	Expired bool `json:"expired"`
+}
+
 func NewMessageConsumer(redisClient *redis.Client, scheduleID string) *MessageConsumer {
 	// 带时间检查和Set过期时间延长的Lua脚本
 	singleScript := redis.NewScript(`
         local queue_name = KEYS[1]   -- job 队列名
-        local labs_set = KEYS[2]   -- 实验用户集合
-		    local retry_count = tonumber(ARGV[1]) or 3
+        local labs_set = KEYS[2]   -- 本实例连接的实验室集合
+        local retry_count = tonumber(ARGV[1]) or 3
         local expire_timeout_second = tonumber(ARGV[2]) or 3600   -- 默认60分钟
-        local current_time_second = tonumber(ARGV[3])   -- 当前时间 
+        local current_time_second = tonumber(ARGV[3])   -- 当前时间
         local set_expire_second = tonumber(ARGV[4]) or 86400   -- 默认24小时
         local max_attempts = tonumber(ARGV[5]) or 30   -- 最大尝试次数
-        
-        local user_count = redis.call('SCARD', labs_set)
-        if user_count == 0 then
-            return nil
-        end
-        
+        local route_field = ARGV[6]   -- 消息中实验室 uuid 字段
+        local owner_field = ARGV[7]   -- 消息中任务 uuid 字段
+        local heart_prefix = ARGV[8]   -- 实验室心跳 key 前缀
+        local owner_prefix = ARGV[9]   -- 任务所属实例 key 前缀
+        local schedule_name = ARGV[10]   -- 当前实例名
+
         -- 延长Set的过期时间（只要有用户存在就延长）
-        redis.call('EXPIRE', labs_set, set_expire_second)
-        
+        if redis.call('SCARD', labs_set) > 0 then
+            redis.call('EXPIRE', labs_set, set_expire_second)
+        end
+
         local attempts = 0
-         
+
         while attempts < retry_count do
             attempts = attempts + 1
             local message = redis.call('RPOP', queue_name)
-            
+
             if not message then
                 return nil
             end
-            
+
             local success, json_data = pcall(function()
                 return cjson.decode(message)
             end)
-            
+
             -- 如果解析失败，直接返回原消息
-            if not success then
+            if not success or type(json_data) ~= 'table' then
                 return message
             end
-            
+
             -- 处理 attempt_count 字段
             json_data.attempt_count = (json_data.attempt_count or 0) + 1
-            
+
             -- 处理 enqueue_time 字段：如果没有设置则设置为当前时间
             json_data.enqueue_time = json_data.enqueue_time or current_time_second
-            
-            -- 检查过期：计算消息年龄（当前时间 - 入队时间）
+
+            -- 检查过期：计算消息年龄（当前时间 - 入队时间），过期消息标记后返回，由调用方转入死信队列
             local message_age_second = current_time_second - json_data.enqueue_time
             if message_age_second > expire_timeout_second then
+                json_data.expired = true
                 return cjson.encode(json_data)
             end
-            
+
             -- 检查最大尝试次数
             -- if json_data.attempt_count >= max_attempts then
             --     return cjson.encode(json_data)
             -- end
-            
-            -- 检查UUID
-            if not json_data.uuid or json_data.uuid == "" then
-                return cjson.encode(json_data)
+
+            -- 任务已由某个实例运行（仿真任务），只由该实例处理
+            local task_uuid = json_data[owner_field]
+            local owner = nil
+            if type(task_uuid) == 'string' and task_uuid ~= '' then
+                owner = redis.call('GET', owner_prefix .. task_uuid)
             end
-            
-            -- 检查用户是否在当前Pod
-            if redis.call('SISMEMBER', labs_set, json_data.uuid) == 1 then
+
+            local lab_uuid = json_data[route_field]
+            if owner then
+                if owner == schedule_name then
+                    return cjson.encode(json_data)
+                end
+                redis.call('LPUSH', queue_name, cjson.encode(json_data))
+            elseif type(lab_uuid) ~= 'string' or lab_uuid == '' then
+                -- 没有实验室信息，任意实例处理
+                return cjson.encode(json_data)
+            elseif redis.call('SISMEMBER', labs_set, lab_uuid) == 1 then
+                -- 实验室连接在当前实例
+                return cjson.encode(json_data)
+            elseif redis.call('EXISTS', heart_prefix .. lab_uuid) == 0 then
+                -- 实验室离线，没有实例认领，任意实例处理
                 return cjson.encode(json_data)
             else
+                -- 实验室连接在其他实例，放回队列
                 redis.call('LPUSH', queue_name, cjson.encode(json_data))
             end
         end
-        
+
         return nil
     `)
 
-	scheduleLabKey := fmt.Sprintf("lab_websocket_uuid_%s_users", scheduleID)
+	scheduleLabKey := fmt.Sprintf(scheduleLabPrefix, scheduleID)
 
 	return &MessageConsumer{
 		redisClient:         redisClient,
 		luaScript:           singleScript,
 		scheduleName:        scheduleID,
 		scheduleUserSet:     scheduleLabKey,
+		routeField:          "lab_uuid",
+		ownerField:          "task_uuid",
+		heartPrefix:         fmt.Sprintf(utils.LabHeartPrefix, ""),
 		retryCount:          3, // 每次连续读取三条
 		maxAttempts:         30,
 		expireTimeoutSecond: 60 * 60, // 1 小时
@@ -147,6 +192,26 @@ func (mc *MessageConsumer) HasUser(ctx context.Context, userUUID string) (bool,
 	return exists, nil
 }
 
+// 登记任务由当前实例运行
+func (mc *MessageConsumer) AddTask(ctx context.Context, taskUUID string) error {
+	err := mc.redisClient.Set(ctx, scheduleOwnerPrefix+taskUUID, mc.scheduleName,
+		time.Duration(mc.setExpireHours)*time.Hour).Err()
+	if err != nil {
+		return code.RedisAddSetErr.WithMsgf("failed to add task owner to redis: %v", err)
+	}
+
+	return nil
+}
+
+// 任务结束，取消登记
+func (mc *MessageConsumer) RemoveTask(ctx context.Context, taskUUID string) error {
+	if err := mc.redisClient.Del(ctx, scheduleOwnerPrefix+taskUUID).Err(); err != nil {
+		return code.RedisRemoveSetErr.WithMsgf("task %s removed from schedule %s owner", taskUUID, mc.scheduleName)
+	}
+
+	return nil
+}
+
 // 消费消息 - 修正版本
 func (mc *MessageConsumer) consumeMessage(ctx context.Context, queueName string) ([]byte, error) {
 	// 获取当前时间戳（毫秒）
@@ -155,7 +220,8 @@ func (mc *MessageConsumer) consumeMessage(ctx context.Context, queueName string)
 
 	cmd := mc.luaScript.Run(ctx, mc.redisClient,
 		[]string{queueName, mc.scheduleUserSet},
-		mc.retryCount, mc.expireTimeoutSecond, currentTimeSecond, setExpireSeconds, mc.maxAttempts)
+		mc.retryCount, mc.expireTimeoutSecond, currentTimeSecond, setExpireSeconds, mc.maxAttempts,
+		mc.routeField, mc.ownerField, mc.heartPrefix, scheduleOwnerPrefix, mc.scheduleName)
 
 	result, err := cmd.Result()
 	if err != nil {
@@ -178,17 +244,53 @@ func (mc *MessageConsumer) consumeMessage(ctx context.Context, queueName string)
 	return []byte(messageStr), nil
 }
 
-func (mc *MessageConsumer) Message(ctx context.Context, queueName string, messageHandler func([]byte)) {
+// 消费一条消息，过期消息转入死信队列后交给 expiredHandler，返回是否取到消息
+func (mc *MessageConsumer) Message(ctx context.Context, queueName string,
+	messageHandler func([]byte), expiredHandler func([]byte),
+) bool {
 	message, err := mc.consumeMessage(ctx, queueName)
 	if err != nil {
 		logger.Errorf(ctx, "Error consuming message: %v", err)
-		return
+		return false
 	}
 
 	// 如果没有消息，这是正常情况，不需要日志
-	if len(message) != 0 && messageHandler != nil {
+	if len(message) == 0 {
+		return false
+	}
+
+	msg := &consumeMsg{}
+	if err := json.Unmarshal(message, msg); err == nil && msg.Expired {
+		mc.deadLetter(ctx, queueName, message)
+		if expiredHandler != nil {
+			expiredHandler(message)
+		}
+		return true
+	}
+
+	if messageHandler != nil {
 		messageHandler(message)
 	}
+
+	return true
+}
+
+// 死信队列名
+func DeadLetterName(queueName string) string {
+	return queueName + deadLetterSuffix
+}
+
+// 过期消息转入死信队列，只保留最近的消息
+func (mc *MessageConsumer) deadLetter(ctx context.Context, queueName string, message []byte) {
+	deadName := DeadLetterName(queueName)
+	_, err := mc.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, deadName, message)
+		pipe.LTrim(ctx, deadName, 0, deadLetterMaxLen-1)
+		return nil
+	})
+	if err != nil {
+		logger.Errorf(ctx, "deadLetter queue: %s, err: %+v, msg: %s", deadName, err, message)
+	}
 }
 
 // 设置最大尝试次数
@@ -196,9 +298,9 @@ func (mc *MessageConsumer) SetMaxAttempts(attempts int) {
 	mc.maxAttempts = attempts
 }
 
-// 设置消息过期时间（分钟）
-func (mc *MessageConsumer) SetMessageExpireTimeout(minutes int) {
-	mc.expireTimeoutSecond = int64(minutes * 60 * 1000)
+// 设置消息过期时间（秒）
+func (mc *MessageConsumer) SetMessageExpireTimeout(second int) {
+	mc.expireTimeoutSecond = int64(second)
 }
 
 // 设置Set过期时间（小时）
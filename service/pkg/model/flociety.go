@@ -78,6 +78,7 @@ type WorkflowNodeTemplate struct {
 	Icon           string         `gorm:"type:text" json:"icon"`
 	Header         string         `gorm:"type:text" json:"header"`
 	Footer         string         `gorm:"type:text" json:"footer"`
+	TimeoutSecond  int            `gorm:"type:int;not null;default:0" json:"timeout_second"` // 动作默认超时时间，0 使用全局默认值
 
 	Handles datatypes.JSONType[ActionHandle] `gorm:"-"`
 }
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/common/uuid"
+	"gorm.io/datatypes"
+)
+
+type ManualStatus string
+
+const (
+	ManualWaiting   ManualStatus = "waiting"   // 等待人工处理
+	ManualConfirmed ManualStatus = "confirmed" // 已确认，节点继续
+	ManualRejected  ManualStatus = "rejected"  // 已拒绝，节点失败
+	ManualTimeout   ManualStatus = "timeout"   // 超时未处理
+	ManualCanceled  ManualStatus = "canceled"  // 任务停止
+)
+
+// 人工节点超时的处理方式
+type ManualTimeoutPolicy string
+
+const (
+	ManualTimeoutFail ManualTimeoutPolicy = "fail" // 节点超时失败
+	ManualTimeoutSkip ManualTimeoutPolicy = "skip" // 跳过节点，下游分支随之跳过
+)
+
+// 人工节点表单字段
+type ManualField struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	Type     string `json:"type"` // string、number、boolean
+	Required bool   `json:"required"`
+}
+
+// 人工节点等待处理的请求，节点每次运行生成一条
+type WorkflowManualApproval struct {
+	BaseModel
+	LabID      int64                            `gorm:"type:bigint;not null" json:"lab_id"`
+	TaskID     int64                            `gorm:"type:bigint;not null;index:idx_workflowmanualapproval_t" json:"task_id"` // 根任务 id，决定由哪个任务引擎处理
+	JobID      int64                            `gorm:"type:bigint;not null;index:idx_workflowmanualapproval_j" json:"job_id"`
+	NodeUUID   uuid.UUID                        `gorm:"type:uuid;not null" json:"node_uuid"`
+	Title      string                           `gorm:"type:text;not null;default:''" json:"title"`
+	Message    string                           `gorm:"type:text;not null;default:''" json:"message"`
+	Form       datatypes.JSONSlice[ManualField] `gorm:"type:jsonb;not null;default:'[]'" json:"form"`
+	Role       LaboratoryMemberRole             `gorm:"type:varchar(120);not null;default:''" json:"role"` // 允许处理的成员角色，为空实验室成员均可处理
+	Deadline   *time.Time                       `gorm:"column:deadline" json:"deadline"`                   // 为空不超时
+	OnTimeout  ManualTimeoutPolicy              `gorm:"type:varchar(20);not null;default:'fail'" json:"on_timeout"`
+	Status     ManualStatus                     `gorm:"type:varchar(20);not null" json:"status"`
+	Input      datatypes.JSONMap                `gorm:"type:jsonb;not null;default:'{}'" json:"input"` // 提交的表单，作为节点输出
+	Comment    string                           `gorm:"type:text;not null;default:''" json:"comment"`
+	OperatorID string                           `gorm:"type:varchar(120);not null;default:''" json:"operator_id"`
+	DecidedAt  *time.Time                       `gorm:"column:decided_at" json:"decided_at"`
+}
+
+func (*WorkflowManualApproval) TableName() string {
+	return "workflow_manual_approval"
+}
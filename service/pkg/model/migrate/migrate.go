@@ -10,6 +10,9 @@ import (
 
 func Table(_ context.Context) error {
 	return utils.IfErrReturn(func() error {
+		// workflow_node_job 唯一索引增加 map 迭代字段，删除旧索引
+		return db.DB().DBIns().Exec(`DROP INDEX IF EXISTS idx_workflownodejob_lwn;`).Error
+	}, func() error {
 		return db.DB().DBIns().AutoMigrate(
 			&model.Laboratory{},             // 实验室
 			&model.ResourceNodeTemplate{},   // 资源模板
@@ -24,6 +27,10 @@ func Table(_ context.Context) error {
 			&model.WorkflowHandleTemplate{},
 			&model.WorkflowNodeJob{},
 			&model.WorkflowTask{},
+			&model.WorkflowTrigger{},
+			&model.WorkflowTriggerRecord{},
+			&model.WorkflowTriggerToken{},
+			&model.WorkflowManualApproval{},
 			&model.Tags{},
 			&model.LaboratoryMember{},
 			&model.LaboratoryInvitation{},
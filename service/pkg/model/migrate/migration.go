@@ -130,6 +130,10 @@ func generateSchemaVersion() string {
 		&model.WorkflowHandleTemplate{},
 		&model.WorkflowNodeJob{},
 		&model.WorkflowTask{},
+		&model.WorkflowTrigger{},
+		&model.WorkflowTriggerRecord{},
+		&model.WorkflowTriggerToken{},
+		&model.WorkflowManualApproval{},
 		&model.Tags{},
 		&model.LaboratoryMember{},
 		&model.LaboratoryInvitation{},
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/scienceol/studio/service/pkg/utils"
+	"gorm.io/datatypes"
+)
+
+type TriggerType string
+
+const (
+	TriggerCron     TriggerType = "cron"     // 按 cron 表达式触发
+	TriggerInterval TriggerType = "interval" // 按固定间隔触发
+)
+
+// 触发时实验室离线或仍在忙碌的处理方式
+type TriggerMissPolicy string
+
+const (
+	TriggerMissSkip  TriggerMissPolicy = "skip"  // 跳过本次触发
+	TriggerMissQueue TriggerMissPolicy = "queue" // 排队等待实验室可用后运行，多次排队合并为一次
+)
+
+// 定时触发工作流
+type WorkflowTrigger struct {
+	BaseModel
+	LabID          int64             `gorm:"type:bigint;not null" json:"lab_id"`
+	WorkflowID     int64             `gorm:"type:bigint;not null;index:idx_workflowtrigger_w" json:"workflow_id"`
+	UserID         string            `gorm:"type:varchar(120);not null" json:"user_id"`
+	Name           string            `gorm:"type:text;not null;default:''" json:"name"`
+	Type           TriggerType       `gorm:"type:varchar(20);not null" json:"type"`
+	CronExpr       string            `gorm:"type:varchar(120);not null;default:''" json:"cron_expr"`
+	IntervalSecond int64             `gorm:"type:bigint;not null;default:0" json:"interval_second"`
+	Timezone       string            `gorm:"type:varchar(64);not null;default:'UTC'" json:"timezone"` // cron 表达式所在时区
+	EndTime        *time.Time        `gorm:"column:end_time" json:"end_time"`                         // 截止时间，之后不再触发
+	Enabled        bool              `gorm:"type:bool;not null;default:true;index:idx_workflowtrigger_en,priority:1" json:"enabled"`
+	MissPolicy     TriggerMissPolicy `gorm:"type:varchar(20);not null;default:'skip'" json:"miss_policy"`
+	Params         datatypes.JSONMap `gorm:"type:jsonb;not null;default:'{}'" json:"params"` // 校验后的运行参数值
+	NextRunTime    time.Time         `gorm:"column:next_run_time;index:idx_workflowtrigger_en,priority:2" json:"next_run_time"`
+	LastRunTime    *time.Time        `gorm:"column:last_run_time" json:"last_run_time"`
+	LastTaskID     int64             `gorm:"type:bigint;not null;default:0" json:"last_task_id"` // 最近一次触发创建的任务
+	Queued         bool              `gorm:"type:bool;not null;default:false" json:"queued"`     // 有等待实验室可用的触发
+}
+
+func (*WorkflowTrigger) TableName() string {
+	return "workflow_trigger"
+}
+
+// 计算晚于 after 的下一次触发时间，超过截止时间返回零值
+func (t *WorkflowTrigger) NextRunAfter(after time.Time) (time.Time, error) {
+	var next time.Time
+	switch t.Type {
+	case TriggerCron:
+		loc, err := time.LoadLocation(t.Timezone)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timezone %q", t.Timezone)
+		}
+
+		schedule, err := utils.ParseCron(t.CronExpr)
+		if err != nil {
+			return time.Time{}, err
+		}
+		next = schedule.Next(after.In(loc))
+	case TriggerInterval:
+		if t.IntervalSecond <= 0 {
+			return time.Time{}, fmt.Errorf("invalid interval second %d", t.IntervalSecond)
+		}
+
+		interval := time.Duration(t.IntervalSecond) * time.Second
+		next = t.NextRunTime
+		if next.IsZero() {
+			next = after.Add(interval)
+		} else if !next.After(after) {
+			// 保持间隔相位，跳过调度停止期间错过的触发
+			next = next.Add((after.Sub(next)/interval + 1) * interval)
+		}
+	default:
+		return time.Time{}, fmt.Errorf("unknown trigger type %q", t.Type)
+	}
+
+	if next.IsZero() || (t.EndTime != nil && next.After(*t.EndTime)) {
+		return time.Time{}, nil
+	}
+
+	return next, nil
+}
+
+type TriggerSource string
+
+const (
+	TriggerSourceSchedule TriggerSource = "schedule" // 定时触发
+	TriggerSourceWebhook  TriggerSource = "webhook"  // 通过 token 调用 webhook 触发
+)
+
+type TriggerRecordStatus string
+
+const (
+	TriggerRecordTriggered TriggerRecordStatus = "triggered" // 已创建任务
+	TriggerRecordSkipped   TriggerRecordStatus = "skipped"   // 实验室离线或忙碌，跳过
+	TriggerRecordQueued    TriggerRecordStatus = "queued"    // 等待实验室可用
+	TriggerRecordFailed    TriggerRecordStatus = "failed"    // 创建任务失败
+	TriggerRecordMerged    TriggerRecordStatus = "merged"    // 与排队中的触发合并为一次运行
+)
+
+// 触发历史
+type WorkflowTriggerRecord struct {
+	BaseModel
+	Source     TriggerSource       `gorm:"type:varchar(20);not null;default:'schedule'" json:"source"`
+	TriggerID  int64               `gorm:"type:bigint;not null;default:0;index:idx_workflowtriggerrecord_t" json:"trigger_id"` // 定时触发 id
+	TokenID    int64               `gorm:"type:bigint;not null;default:0;index:idx_workflowtriggerrecord_tk" json:"token_id"`  // webhook 触发使用的 token id
+	TaskID     int64               `gorm:"type:bigint;not null;default:0" json:"task_id"`
+	FireTime   time.Time           `gorm:"column:fire_time;not null" json:"fire_time"` // 计划触发时间，webhook 为请求时间
+	Status     TriggerRecordStatus `gorm:"type:varchar(20);not null" json:"status"`
+	Message    string              `gorm:"type:text;not null;default:''" json:"message"`
+	RemoteAddr string              `gorm:"type:varchar(120);not null;default:''" json:"remote_addr"` // webhook 调用方地址
+}
+
+func (*WorkflowTriggerRecord) TableName() string {
+	return "workflow_trigger_record"
+}
+
+// 工作流 webhook 触发 token，由实验室管理员创建和吊销
+type WorkflowTriggerToken struct {
+	BaseModel
+	LabID            int64             `gorm:"type:bigint;not null" json:"lab_id"`
+	WorkflowID       int64             `gorm:"type:bigint;not null;index:idx_workflowtriggertoken_w" json:"workflow_id"`
+	UserID           string            `gorm:"type:varchar(120);not null" json:"user_id"` // 创建者，触发的任务以该用户运行
+	Name             string            `gorm:"type:text;not null;default:''" json:"name"`
+	TokenHash        string            `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // token 的 sha256，token 明文只在创建时返回
+	Prefix           string            `gorm:"type:varchar(20);not null" json:"prefix"`        // token 前缀，用于识别
+	Secret           string            `gorm:"type:varchar(128);not null" json:"-"`            // HMAC 签名密钥
+	RequireSignature bool              `gorm:"type:bool;not null;default:false" json:"require_signature"`
+	ParamMapping     datatypes.JSONMap `gorm:"type:jsonb;not null;default:'{}'" json:"param_mapping"` // 参数名到请求体 gjson 路径，为空时请求体即为参数
+	Revoked          bool              `gorm:"type:bool;not null;default:false" json:"revoked"`
+	RevokedAt        *time.Time        `gorm:"column:revoked_at" json:"revoked_at"`
+	LastUsedAt       *time.Time        `gorm:"column:last_used_at" json:"last_used_at"`
+}
+
+func (*WorkflowTriggerToken) TableName() string {
+	return "workflow_trigger_token"
+}
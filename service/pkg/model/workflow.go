@@ -9,24 +9,46 @@ import (
 
 type Workflow struct {
 	BaseModel
-	UserID      string                      `gorm:"type:varchar(120);not null;index:idx_workflow_lu,priority:2" json:"user_id"`
-	LabID       int64                       `gorm:"type:bigint;not null;index:idx_workflow_lu,priority:1" json:"lab_id"`
-	Name        string                      `gorm:"type:text;not null;default:'Untitled'" json:"name"`
-	Published   bool                        `gorm:"type:bool;not null;default:false" json:"published"`
-	Tags        datatypes.JSONSlice[string] `gorm:"type:jsonb" json:"tags"`
-	Description *string                     `gorm:"type:text" json:"description"`
+	UserID        string                             `gorm:"type:varchar(120);not null;index:idx_workflow_lu,priority:2" json:"user_id"`
+	LabID         int64                              `gorm:"type:bigint;not null;index:idx_workflow_lu,priority:1" json:"lab_id"`
+	Name          string                             `gorm:"type:text;not null;default:'Untitled'" json:"name"`
+	Published     bool                               `gorm:"type:bool;not null;default:false" json:"published"`
+	Tags          datatypes.JSONSlice[string]        `gorm:"type:jsonb" json:"tags"`
+	Description   *string                            `gorm:"type:text" json:"description"`
+	TimeoutSecond int                                `gorm:"type:int;not null;default:0" json:"timeout_second"`                   // 任务整体超时时间，0 不限制
+	Params        datatypes.JSONSlice[WorkflowParam] `gorm:"type:jsonb;not null;default:'[]'" json:"params"`                      // 运行参数声明
+	FailurePolicy FailurePolicy                      `gorm:"type:varchar(30);not null;default:'fail_fast'" json:"failure_policy"` // 节点失败时的处理策略
 }
 
 func (*Workflow) TableName() string {
 	return "workflow"
 }
 
+// 工作流运行参数，运行时写入绑定的节点参数
+type WorkflowParam struct {
+	Name     string         `json:"name"`
+	Schema   datatypes.JSON `json:"schema"`   // 参数值的 json schema
+	Default  any            `json:"default"`  // 默认值，为空时运行必须传入
+	Bindings []ParamBinding `json:"bindings"` // 参数写入的节点参数路径
+}
+
+type ParamBinding struct {
+	NodeUUID uuid.UUID `json:"node_uuid"`
+	Path     string    `json:"path"` // 节点 Param 中的 sjson 路径
+}
+
 type WorkflowNodeType string
 
 const (
 	WorkflowNodeGroup WorkflowNodeType = "Group"
 	WorkflowNodeILab  WorkflowNodeType = "ILab"
 	WorkflowPyScript  WorkflowNodeType = "py_script"
+	WorkflowBranch    WorkflowNodeType = "branch"
+	WorkflowMap       WorkflowNodeType = "map"
+	WorkflowSubflow   WorkflowNodeType = "sub_workflow"
+	WorkflowManual    WorkflowNodeType = "manual"         // 人工确认节点
+	WorkflowTimer     WorkflowNodeType = "timer"          // 延时节点
+	WorkflowWait      WorkflowNodeType = "wait_condition" // 等待物料数据满足条件
 )
 
 type Ref struct {
@@ -34,26 +56,48 @@ type Ref struct {
 	Param      map[string]any
 }
 
+// 节点失败处理策略
+type FailurePolicy string
+
+const (
+	FailureInherit          FailurePolicy = ""                  // 节点未配置，使用工作流的策略
+	FailureFailFast         FailurePolicy = "fail_fast"         // 任一节点失败立即停止整个任务
+	FailureContinueBranches FailurePolicy = "continue_branches" // 跳过失败节点的下游，其他分支继续运行
+	FailureIgnoreError      FailurePolicy = "ignore_error"      // 忽略失败，下游照常运行但不接收失败节点的数据，任务结果为部分成功
+)
+
+// 节点重试策略
+type RetryPolicy struct {
+	MaxAttempts      int     `json:"max_attempts"`       // 最大尝试次数，小于等于 1 不重试
+	BackoffSecond    float64 `json:"backoff_second"`     // 首次重试间隔
+	BackoffFactor    float64 `json:"backoff_factor"`     // 退避倍数，小于 1 按 1 处理
+	MaxBackoffSecond float64 `json:"max_backoff_second"` // 最大重试间隔，0 不限制
+	RetryOn          []int   `json:"retry_on"`           // 可重试的错误码，为空使用默认错误码，设备节点默认不重试超时
+}
+
 type WorkflowNode struct {
 	BaseModel
-	WorkflowID     int64                    `gorm:"type:bigint;not null;index:idx_workflow_id" json:"workflow_id"` // 工作流 id
-	WorkflowNodeID int64                    `gorm:"type:bigint;not null" json:"workflow_node_id"`                  // 模板 id
-	ParentID       int64                    `gorm:"type:bigint;not null" json:"parent_id"`
-	Name           string                   `gorm:"type:varchar(200);not null;default:'unknow'" json:"name"`
-	UserID         string                   `gorm:"type:varchar(120);not null" json:"user_id"`
-	Status         string                   `gorm:"type:varchar(20);not null;default:'draft'" json:"status"`
-	Type           WorkflowNodeType         `gorm:"type:varchar(20);not null" json:"type"`
-	LabNodeType    string                   `gorm:"type:varchar(20);not null;default:'Device'" json:"lab_node_type"` // 节点类型，默认DEFAULT
-	Icon           string                   `gorm:"type:text" json:"icon"`
-	Pose           datatypes.JSONType[Pose] `gorm:"type:jsonb" json:"pose"`
-	Param          datatypes.JSON           `gorm:"type:jsonb" json:"param"`
-	Footer         string                   `gorm:"type:text" json:"footer"`
-	DeviceName     *string                  `gorm:"type:varchar(255)" json:"device_name"`
-	ActionName     string                   `gorm:"type:varchar(255)" json:"action_name"`
-	ActionType     string                   `gorm:"type:text" json:"action_type"`
-	Disabled       bool                     `gorm:"type:bool;not null;default:false" json:"disabled"`
-	Minimized      bool                     `gorm:"type:bool;not null;default:false" json:"minimized"`
-	Script         *string                  `gorm:"type:text" json:"script"`
+	WorkflowID     int64                           `gorm:"type:bigint;not null;index:idx_workflow_id" json:"workflow_id"` // 工作流 id
+	WorkflowNodeID int64                           `gorm:"type:bigint;not null" json:"workflow_node_id"`                  // 模板 id
+	ParentID       int64                           `gorm:"type:bigint;not null" json:"parent_id"`
+	Name           string                          `gorm:"type:varchar(200);not null;default:'unknow'" json:"name"`
+	UserID         string                          `gorm:"type:varchar(120);not null" json:"user_id"`
+	Status         string                          `gorm:"type:varchar(20);not null;default:'draft'" json:"status"`
+	Type           WorkflowNodeType                `gorm:"type:varchar(20);not null" json:"type"`
+	LabNodeType    string                          `gorm:"type:varchar(20);not null;default:'Device'" json:"lab_node_type"` // 节点类型，默认DEFAULT
+	Icon           string                          `gorm:"type:text" json:"icon"`
+	Pose           datatypes.JSONType[Pose]        `gorm:"type:jsonb" json:"pose"`
+	Param          datatypes.JSON                  `gorm:"type:jsonb" json:"param"`
+	Footer         string                          `gorm:"type:text" json:"footer"`
+	DeviceName     *string                         `gorm:"type:varchar(255)" json:"device_name"`
+	ActionName     string                          `gorm:"type:varchar(255)" json:"action_name"`
+	ActionType     string                          `gorm:"type:text" json:"action_type"`
+	Disabled       bool                            `gorm:"type:bool;not null;default:false" json:"disabled"`
+	Minimized      bool                            `gorm:"type:bool;not null;default:false" json:"minimized"`
+	Script         *string                         `gorm:"type:text" json:"script"`
+	RetryPolicy    datatypes.JSONType[RetryPolicy] `gorm:"type:jsonb;not null;default:'{}'" json:"retry_policy"`
+	TimeoutSecond  int                             `gorm:"type:int;not null;default:0" json:"timeout_second"`          // 节点超时时间，0 使用模板默认值
+	FailurePolicy  FailurePolicy                   `gorm:"type:varchar(30);not null;default:''" json:"failure_policy"` // 节点失败处理策略，为空使用工作流的策略
 
 	OldNode *WorkflowNode `gorm:"-"` // 复制的节点
 }
@@ -76,6 +120,7 @@ type WorkflowEdge struct {
 	TargetNodeUUID   uuid.UUID `gorm:"type:uuid;not null;index:idx_we_target_node;uniqueIndex:idx_we_stst,priority:2" json:"target_node_uuid"`
 	SourceHandleUUID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_we_stst,priority:3" json:"source_handle_uuid"`
 	TargetHandleUUID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_we_stst,priority:4" json:"target_handle_uuid"`
+	Expression       string    `gorm:"type:text;not null;default:''" json:"expression"` // 数据转换表达式，为空直接传递
 }
 
 func (*WorkflowEdge) TableName() string {
@@ -93,6 +138,7 @@ const (
 	WorkflowJobPending  WorkflowJobStatus = "pending"
 	WorkflowJobCanceled WorkflowJobStatus = "canceled"
 	WorkflowJobTimeout  WorkflowJobStatus = "timeout"
+	WorkflowJobPaused   WorkflowJobStatus = "paused" // 任务暂停时就绪但未下发的节点
 )
 
 type ReturnInfo struct {
@@ -101,15 +147,33 @@ type ReturnInfo struct {
 	ReturnValue any    `json:"return_value"`
 }
 
+// 节点每次尝试的运行记录
+type JobAttempt struct {
+	Attempt   int               `json:"attempt"`
+	Status    WorkflowJobStatus `json:"status"`
+	Error     string            `json:"error"`
+	StartTime time.Time         `json:"start_time"`
+	EndTime   time.Time         `json:"end_time"`
+}
+
 type WorkflowNodeJob struct {
 	BaseModel
-	LabID          int64                          `gorm:"type:bigint;not null;uniqueIndex:idx_workflownodejob_lwn,priority:1" json:"lab_id"`
-	WorkflowTaskID int64                          `gorm:"type:bigint;not null;index:idx_workflownodejob_task;uniqueIndex:idx_workflownodejob_lwn,priority:2" json:"workflow_task_id"`
-	NodeID         int64                          `gorm:"type:bigint;not null;uniqueIndex:idx_workflownodejob_lwn,priority:3" json:"node_id"`
-	Status         WorkflowJobStatus              `gorm:"type:varchar(50);not null" json:"status"`
-	FeedbackData   datatypes.JSON                 `gorm:"type:jsonb" json:"feedback_data"`
-	ReturnInfo     datatypes.JSONType[ReturnInfo] `gorm:"type:jsonb" json:"return_info"`
-	Timestamp      time.Time                      `json:"timestamp"`
+	LabID          int64                           `gorm:"type:bigint;not null;uniqueIndex:idx_workflownodejob_lwnpi,priority:1" json:"lab_id"`
+	WorkflowTaskID int64                           `gorm:"type:bigint;not null;index:idx_workflownodejob_task;uniqueIndex:idx_workflownodejob_lwnpi,priority:2" json:"workflow_task_id"`
+	NodeID         int64                           `gorm:"type:bigint;not null;uniqueIndex:idx_workflownodejob_lwnpi,priority:3" json:"node_id"`
+	ParentJobID    int64                           `gorm:"type:bigint;not null;default:0;uniqueIndex:idx_workflownodejob_lwnpi,priority:4" json:"parent_job_id"` // map 节点迭代所属的 map job id
+	Iteration      int                             `gorm:"type:int;not null;default:0;uniqueIndex:idx_workflownodejob_lwnpi,priority:5" json:"iteration"`        // map 节点迭代序号
+	Status         WorkflowJobStatus               `gorm:"type:varchar(50);not null" json:"status"`
+	FeedbackData   datatypes.JSON                  `gorm:"type:jsonb" json:"feedback_data"`
+	ReturnInfo     datatypes.JSONType[ReturnInfo]  `gorm:"type:jsonb" json:"return_info"`
+	Timestamp      time.Time                       `json:"timestamp"`
+	Attempt        int                             `gorm:"type:int;not null;default:0" json:"attempt"`       // 当前尝试次数
+	Attempts       datatypes.JSONSlice[JobAttempt] `gorm:"type:jsonb;not null;default:'[]'" json:"attempts"` // 历次尝试记录
+	Inputs         datatypes.JSON                  `gorm:"type:jsonb" json:"inputs"`                         // 下发的输入，设备节点为 action args，脚本节点为 inputs
+	QueuedAt       *time.Time                      `gorm:"column:queued_at" json:"queued_at"`                // 依赖满足进入队列的时间
+	DispatchedAt   *time.Time                      `gorm:"column:dispatched_at" json:"dispatched_at"`        // 最近一次下发的时间
+	StartedAt      *time.Time                      `gorm:"column:started_at" json:"started_at"`              // 最近一次开始执行的时间，设备节点以 edge 上报 running 为准
+	FinishedAt     *time.Time                      `gorm:"column:finished_at" json:"finished_at"`            // 结束时间
 }
 
 func (*WorkflowNodeJob) TableName() string {
@@ -125,17 +189,49 @@ const (
 	WorkflowTaskStatusFailed    WorkflowTaskStatus = "failed"
 	WorkflowTaskStatusSuccessed WorkflowTaskStatus = "successed"
 	WorkflowTaskStatusTimeout   WorkflowTaskStatus = "timeout"
+	WorkflowTaskStatusPaused    WorkflowTaskStatus = "paused"
+	WorkflowTaskStatusPartially WorkflowTaskStatus = "partially_succeeded" // 部分节点失败，其余分支运行完成
 )
 
 type WorkflowTask struct {
 	BaseModel
-	LabID        int64              `gorm:"type:bigint;not null;index:idx_workflowtask_lwu,priority:1" json:"lab_id"`
-	WorkflowID   int64              `gorm:"type:bigint;not null;index:idx_workflowtask_lwu,priority:2" json:"workflow_id"`
-	UserID       string             `gorm:"type:varchar(120);not null;index:idx_workflowtask_lwu,priority:3" json:"user_id"`
-	Status       WorkflowTaskStatus `gorm:"type:varchar(50);not null;default:'pending'" json:"status"`
-	FinishedTime time.Time          `gorm:"column:finished_time" json:"finished_at"`
+	LabID         int64                              `gorm:"type:bigint;not null;index:idx_workflowtask_lwu,priority:1" json:"lab_id"`
+	WorkflowID    int64                              `gorm:"type:bigint;not null;index:idx_workflowtask_lwu,priority:2" json:"workflow_id"`
+	UserID        string                             `gorm:"type:varchar(120);not null;index:idx_workflowtask_lwu,priority:3" json:"user_id"`
+	Status        WorkflowTaskStatus                 `gorm:"type:varchar(50);not null;default:'pending'" json:"status"`
+	FinishedTime  time.Time                          `gorm:"column:finished_time" json:"finished_at"`
+	ParentTaskID  int64                              `gorm:"type:bigint;not null;default:0" json:"parent_task_id"`                           // 子工作流所属的父任务 id
+	ParentJobID   int64                              `gorm:"type:bigint;not null;default:0;index:idx_workflowtask_pj" json:"parent_job_id"`  // 子工作流所属的父节点 job id
+	SourceTaskID  int64                              `gorm:"type:bigint;not null;default:0;index:idx_workflowtask_st" json:"source_task_id"` // 从失败处重跑时的源任务 id
+	RestartNodeID int64                              `gorm:"type:bigint;not null;default:0" json:"restart_node_id"`                          // 重跑起点节点 id，为 0 时从失败节点开始
+	Simulated     bool                               `gorm:"type:bool;not null;default:false" json:"simulated"`                              // 仿真任务，使用 mock edge 运行，不占用真实设备
+	Simulate      datatypes.JSONType[SimulateConfig] `gorm:"type:jsonb;not null;default:'{}'" json:"simulate"`                               // 仿真运行配置
+	Params        datatypes.JSONMap                  `gorm:"type:jsonb;not null;default:'{}'" json:"params"`                                 // 校验后的运行参数值
+	Snapshot      datatypes.JSON                     `gorm:"type:jsonb" json:"snapshot"`                                                     // 首次运行时的工作流快照，之后不再变化
+	RunSecond     int                                `gorm:"type:int;not null;default:0" json:"run_second"`                                  // 已运行时间，不含暂停，用于任务整体超时
+}
+
+// 任务运行的工作流快照，节点参数已写入运行参数
+type WorkflowSnapshot struct {
+	WorkflowUUID  uuid.UUID                 `json:"workflow_uuid"`
+	Name          string                    `json:"name"`
+	TimeoutSecond int                       `json:"timeout_second"`
+	FailurePolicy FailurePolicy             `json:"failure_policy"`
+	Params        []WorkflowParam           `json:"params"`
+	Nodes         []*WorkflowNode           `json:"nodes"`
+	Edges         []*WorkflowEdge           `json:"edges"`
+	Handles       []*WorkflowHandleTemplate `json:"handles"`
+	CreatedAt     time.Time                 `json:"created_at"`
 }
 
 func (*WorkflowTask) TableName() string {
 	return "workflow_task"
 }
+
+// 仿真运行配置
+type SimulateConfig struct {
+	DelayMs     int         `json:"delay_ms"`     // 每个动作的模拟耗时，毫秒
+	JitterMs    int         `json:"jitter_ms"`    // 在 DelayMs 基础上随机增加的耗时上限，毫秒
+	FailureRate float64     `json:"failure_rate"` // 动作随机失败概率，0~1
+	FailNodes   []uuid.UUID `json:"fail_nodes"`   // 必定失败的节点
+}
@@ -116,6 +116,7 @@ func (e *envImpl) UpsertWorkflowNodeTemplate(ctx context.Context, datas []*model
 			"schema",
 			"type",
 			"icon",
+			"timeout_second",
 			"updated_at", // 只更新这些字段，不包括 created_at
 		}),
 	}).Create(&datas)
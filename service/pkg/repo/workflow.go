@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/scienceol/studio/service/pkg/common"
 	"github.com/scienceol/studio/service/pkg/common/uuid"
@@ -68,6 +69,7 @@ type WorkflowRepo interface {
 	UpsertEdge(ctx context.Context, edges []*model.WorkflowEdge) error
 	DuplicateEdge(ctx context.Context, edges []*model.WorkflowEdge) error
 	CreateJobs(ctx context.Context, datas []*model.WorkflowNodeJob) error
+	MarkJobStarted(ctx context.Context, jobUUID uuid.UUID, startedAt time.Time) error
 	UpsertJobs(ctx context.Context, datas []*model.WorkflowNodeJob) error
 	GetTemplateList(ctx context.Context, req *common.PageReqT[*QueryTemplage]) (*common.PageResp[[]*model.WorkflowNodeTemplate], error)
 	GetNodeTemplateByUUID(ctx context.Context, templateUUID uuid.UUID) (*model.WorkflowNodeTemplate, error)
@@ -77,4 +79,10 @@ type WorkflowRepo interface {
 	GetWorkflow(ctx context.Context, req *common.PageReqT[*QueryWorkflow], keys ...string) (*common.PageResp[[]*model.Workflow], error)
 	GetTemplateTags(ctx context.Context, tagType model.TagType) ([]string, error)
 	GetWorkflowTagsByLab(ctx context.Context, labID int64) ([]string, error)
+	CreateData(ctx context.Context, data schema.Tabler) error
+	DelData(ctx context.Context, tableModel schema.Tabler, condition map[string]any) error
+	GetDueTriggers(ctx context.Context, now time.Time, limit int) ([]*model.WorkflowTrigger, error)
+	ClaimTrigger(ctx context.Context, trigger *model.WorkflowTrigger, nextRunTime time.Time, updatedAt time.Time, keys ...string) (bool, error)
+	ClaimTask(ctx context.Context, task *model.WorkflowTask, status model.WorkflowTaskStatus) (bool, error)
+	GetTriggerRecords(ctx context.Context, req *common.PageReqT[int64]) (*common.PageMoreResp[[]*model.WorkflowTriggerRecord], error)
 }
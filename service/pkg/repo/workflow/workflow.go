@@ -358,6 +358,7 @@ func (w *workflowImpl) UpsertWorkflowEdge(ctx context.Context, datas []*model.Wo
 			{Name: "target_handle_uuid"},
 		},
 		DoUpdates: clause.AssignmentColumns([]string{
+			"expression",
 			"updated_at",
 		}),
 	}).Create(datas)
@@ -554,6 +555,7 @@ func (w *workflowImpl) UpsertEdge(ctx context.Context, edges []*model.WorkflowEd
 				},
 			},
 			DoUpdates: clause.AssignmentColumns([]string{
+				"expression",
 				"updated_at",
 			}),
 		}).Create(edges).Error; err != nil {
@@ -574,6 +576,19 @@ func (w *workflowImpl) CreateJobs(ctx context.Context, datas []*model.WorkflowNo
 	return nil
 }
 
+// 记录 job 开始时间，重试时覆盖早于本次下发的开始时间
+func (w *workflowImpl) MarkJobStarted(ctx context.Context, jobUUID uuid.UUID, startedAt time.Time) error {
+	if err := w.DBWithContext(ctx).
+		Model(&model.WorkflowNodeJob{}).
+		Where("uuid = ? AND (started_at IS NULL OR started_at < dispatched_at)", jobUUID).
+		Update("started_at", startedAt).Error; err != nil {
+		logger.Errorf(ctx, "MarkJobStarted fail uuid: %s, err: %+v", jobUUID, err)
+		return code.UpdateDataErr.WithErr(err)
+	}
+
+	return nil
+}
+
 func (w *workflowImpl) UpsertJobs(ctx context.Context, datas []*model.WorkflowNodeJob) error {
 	if len(datas) == 0 {
 		return nil
@@ -781,3 +796,79 @@ func (w *workflowImpl) GetWorkflowTagsByLab(ctx context.Context, labID int64) ([
 	}
 	return tags, nil
 }
+
+// 获取已到触发时间或排队等待实验室可用的定时触发
+func (w *workflowImpl) GetDueTriggers(ctx context.Context, now time.Time, limit int) ([]*model.WorkflowTrigger, error) {
+	datas := make([]*model.WorkflowTrigger, 0, limit)
+	if err := w.DBWithContext(ctx).
+		Where("(enabled = true AND next_run_time <= ?) OR queued = true", now).
+		Order("next_run_time asc").
+		Limit(limit).
+		Find(&datas).Error; err != nil {
+		logger.Errorf(ctx, "GetDueTriggers fail err: %+v", err)
+		return nil, code.QueryRecordErr.WithErr(err)
+	}
+
+	return datas, nil
+}
+
+// 读取后未被修改时才更新触发配置，已被其他实例处理时返回 false
+func (w *workflowImpl) ClaimTrigger(ctx context.Context, trigger *model.WorkflowTrigger,
+	nextRunTime time.Time, updatedAt time.Time, keys ...string,
+) (bool, error) {
+	res := w.DBWithContext(ctx).
+		Where("id = ? AND next_run_time = ? AND updated_at = ?", trigger.ID, nextRunTime, updatedAt).
+		Select(keys).
+		Updates(trigger)
+	if res.Error != nil {
+		logger.Errorf(ctx, "ClaimTrigger fail id: %d, err: %+v", trigger.ID, res.Error)
+		return false, code.UpdateDataErr.WithErr(res.Error)
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+// 任务状态仍为读取时的状态才更新为运行中，已被其他实例启动时返回 false
+func (w *workflowImpl) ClaimTask(ctx context.Context, task *model.WorkflowTask, status model.WorkflowTaskStatus) (bool, error) {
+	res := w.DBWithContext(ctx).
+		Where("id = ? AND status = ?", task.ID, status).
+		Select("status", "updated_at").
+		Updates(task)
+	if res.Error != nil {
+		logger.Errorf(ctx, "ClaimTask fail id: %d, err: %+v", task.ID, res.Error)
+		return false, code.UpdateDataErr.WithErr(res.Error)
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func (w *workflowImpl) GetTriggerRecords(ctx context.Context,
+	req *common.PageReqT[int64]) (*common.PageMoreResp[[]*model.WorkflowTriggerRecord],
+	error,
+) {
+	records := make([]*model.WorkflowTriggerRecord, 0, 1)
+	total := int64(0)
+	query := w.DBWithContext(ctx).Model(&model.WorkflowTriggerRecord{}).Where("trigger_id = ?", req.Data)
+
+	req.Normalize()
+
+	if err := query.Count(&total).Error; err != nil {
+		logger.Errorf(ctx, "GetTriggerRecords count fail param: %+v, err: %+v", req, err)
+		return nil, code.QueryRecordErr.WithMsg(err.Error())
+	}
+
+	if err := query.Offset(req.Offest()).
+		Limit(req.PageSize).
+		Order("id desc").
+		Find(&records).Error; err != nil {
+		logger.Errorf(ctx, "GetTriggerRecords query fail param: %+v, err: %+v", req, err)
+		return nil, code.QueryRecordErr.WithMsg(err.Error())
+	}
+
+	return &common.PageMoreResp[[]*model.WorkflowTriggerRecord]{
+		HasMore:  total > int64(req.Page)*int64(req.PageSize),
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Data:     records,
+	}, nil
+}
@@ -0,0 +1,155 @@
+// notlint:revive
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cronNexts(t *testing.T, expr string, from time.Time, n int) []time.Time {
+	s, err := ParseCron(expr)
+	assert.NoError(t, err, expr)
+
+	res := make([]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		from = s.Next(from)
+		res = append(res, from)
+	}
+
+	return res
+}
+
+func TestParseCronErr(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"* * * foo *",
+	} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2026-01-01 为周四
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(month time.Month, d, hour, minute int) time.Time {
+		return time.Date(2026, month, d, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "step from offset",
+			expr: "5/15 10 * * *",
+			from: from,
+			want: []time.Time{day(1, 1, 10, 5), day(1, 1, 10, 20), day(1, 1, 10, 35), day(1, 1, 10, 50), day(1, 2, 10, 5)},
+		},
+		{
+			name: "star step and list",
+			expr: "*/30 8,17 * * *",
+			from: from,
+			want: []time.Time{day(1, 1, 8, 0), day(1, 1, 8, 30), day(1, 1, 17, 0), day(1, 1, 17, 30), day(1, 2, 8, 0)},
+		},
+		{
+			name: "day of month or day of week",
+			expr: "0 0 13 * fri",
+			from: from,
+			want: []time.Time{day(1, 2, 0, 0), day(1, 9, 0, 0), day(1, 13, 0, 0), day(1, 16, 0, 0)},
+		},
+		{
+			name: "day of month only when day of week is star",
+			expr: "0 0 13 * *",
+			from: from,
+			want: []time.Time{day(1, 13, 0, 0), day(2, 13, 0, 0)},
+		},
+		{
+			name: "day of week only when day of month is star",
+			expr: "0 0 * * 5",
+			from: from,
+			want: []time.Time{day(1, 2, 0, 0), day(1, 9, 0, 0)},
+		},
+		{
+			name: "month and weekday names",
+			expr: "0 9 * FEB-mar Mon-Wed",
+			from: from,
+			want: []time.Time{day(2, 2, 9, 0), day(2, 3, 9, 0), day(2, 4, 9, 0), day(2, 9, 9, 0)},
+		},
+		{
+			name: "sunday as 7",
+			expr: "0 0 * * 7",
+			from: from,
+			want: []time.Time{day(1, 4, 0, 0), day(1, 11, 0, 0)},
+		},
+		{
+			name: "weekly alias",
+			expr: "@weekly",
+			from: from,
+			want: []time.Time{day(1, 4, 0, 0), day(1, 11, 0, 0)},
+		},
+		{
+			name: "monthly alias",
+			expr: "@Monthly",
+			from: from,
+			want: []time.Time{day(2, 1, 0, 0), day(3, 1, 0, 0)},
+		},
+		{
+			name: "hourly alias skips current minute",
+			expr: "@hourly",
+			from: from,
+			want: []time.Time{day(1, 1, 1, 0), day(1, 1, 2, 0)},
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.want, cronNexts(t, c.expr, c.from, len(c.want)), c.name)
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+
+	// 2026-03-08 02:00 跳到 03:00，当天的 02:30 不存在
+	from := time.Date(2026, 3, 7, 12, 0, 0, 0, loc)
+	got := cronNexts(t, "30 2 * * *", from, 2)
+	assert.Equal(t, time.Date(2026, 3, 9, 2, 30, 0, 0, loc), got[0])
+	assert.Equal(t, time.Date(2026, 3, 10, 2, 30, 0, 0, loc), got[1])
+
+	// 跨越夏令时切换后仍按当地时间触发
+	got = cronNexts(t, "0 12 * * *", from, 2)
+	assert.Equal(t, time.Date(2026, 3, 8, 12, 0, 0, 0, loc), got[0])
+	assert.Equal(t, time.Date(2026, 3, 9, 12, 0, 0, 0, loc), got[1])
+	assert.Equal(t, 24*time.Hour-time.Hour, got[0].Sub(time.Date(2026, 3, 7, 12, 0, 0, 0, loc)))
+}
+
+func TestCronNextNever(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, expr := range []string{"0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		s, err := ParseCron(expr)
+		assert.NoError(t, err, expr)
+		assert.True(t, s.Next(from).IsZero(), expr)
+	}
+
+	// 闰年 2 月 29 日在查找范围内
+	s, err := ParseCron("0 0 29 2 *")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), s.Next(from))
+}
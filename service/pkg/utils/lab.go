@@ -12,6 +12,12 @@ const (
 	LabControlPrefix = "lab_control_queue_%s"
 	LabHeartPrefix   = "lab_heart_key_%s"
 
+	LabDeviceLockPrefix       = "lab_device_lock_%s_%s"
+	LabDeviceLockSetPrefix    = "lab_device_locks_%s"
+	LabDeviceLockQueuePrefix  = "lab_device_lock_queue_%s_%s"
+	LabDeviceWaiterPrefix     = "lab_device_lock_waiter_%s_%s"
+	LabDeviceLockNotifyPrefix = "lab_device_lock_notify_%s_%s"
+
 	LabHeartTime = 5 * time.Second
 )
 
@@ -26,3 +32,28 @@ func LabControlName(labUUID uuid.UUID) string {
 func LabHeartName(labUUID uuid.UUID) string {
 	return fmt.Sprintf(LabHeartPrefix, labUUID.String())
 }
+
+// 设备锁，value 为持有者信息的 hash
+func LabDeviceLockName(labUUID uuid.UUID, deviceName string) string {
+	return fmt.Sprintf(LabDeviceLockPrefix, labUUID.String(), deviceName)
+}
+
+// 实验室下被锁定的设备名集合
+func LabDeviceLockSetName(labUUID uuid.UUID) string {
+	return fmt.Sprintf(LabDeviceLockSetPrefix, labUUID.String())
+}
+
+// 设备锁排队的持有者 zset，score 为入队时间
+func LabDeviceLockQueueName(labUUID uuid.UUID, deviceName string) string {
+	return fmt.Sprintf(LabDeviceLockQueuePrefix, labUUID.String(), deviceName)
+}
+
+// 设备锁排队者的存活截止时间 hash，排队者退出后据此移出队列
+func LabDeviceWaiterName(labUUID uuid.UUID, deviceName string) string {
+	return fmt.Sprintf(LabDeviceWaiterPrefix, labUUID.String(), deviceName)
+}
+
+// 设备锁释放通知频道
+func LabDeviceLockNotifyName(labUUID uuid.UUID, deviceName string) string {
+	return fmt.Sprintf(LabDeviceLockNotifyPrefix, labUUID.String(), deviceName)
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// 按 json schema 校验数据，支持 type、enum、properties、required、items 以及数值和长度范围
+// value 为 json 反序列化后的数据，path 用于错误提示
+func ValidateSchema(schema map[string]any, value any, path string) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		if !slices.ContainsFunc(enum, func(item any) bool { return Compare(item, value) }) {
+			return fmt.Errorf("%s: value not in enum", path)
+		}
+	}
+
+	switch typ := schema["type"].(type) {
+	case string:
+		if err := checkSchemaType(typ, value, path); err != nil {
+			return err
+		}
+	case []any:
+		if !slices.ContainsFunc(typ, func(t any) bool {
+			name, _ := t.(string)
+			return checkSchemaType(name, value, path) == nil
+		}) {
+			return fmt.Errorf("%s: type mismatch, expect %v", path, typ)
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if minimum, ok := schema["minimum"].(float64); ok && v < minimum {
+			return fmt.Errorf("%s: less than minimum %v", path, minimum)
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && v > maximum {
+			return fmt.Errorf("%s: greater than maximum %v", path, maximum)
+		}
+	case string:
+		if minimum, ok := schema["minLength"].(float64); ok && float64(len([]rune(v))) < minimum {
+			return fmt.Errorf("%s: shorter than minLength %v", path, minimum)
+		}
+		if maximum, ok := schema["maxLength"].(float64); ok && float64(len([]rune(v))) > maximum {
+			return fmt.Errorf("%s: longer than maxLength %v", path, maximum)
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := ValidateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, key := range required {
+				name, _ := key.(string)
+				if _, ok := v[name]; !ok {
+					return fmt.Errorf("%s.%s: required", path, name)
+				}
+			}
+		}
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			for name, property := range properties {
+				propertySchema, _ := property.(map[string]any)
+				propertyValue, ok := v[name]
+				if !ok {
+					continue
+				}
+				if err := ValidateSchema(propertySchema, propertyValue, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkSchemaType(typ string, value any, path string) error {
+	ok := true
+	switch typ {
+	case "string":
+		_, ok = value.(string)
+	case "number":
+		_, ok = value.(float64)
+	case "integer":
+		v, isNumber := value.(float64)
+		ok = isNumber && v == math.Trunc(v)
+	case "boolean":
+		_, ok = value.(bool)
+	case "array":
+		_, ok = value.([]any)
+	case "object":
+		_, ok = value.(map[string]any)
+	case "null":
+		ok = value == nil
+	}
+
+	if !ok {
+		return fmt.Errorf("%s: type mismatch, expect %s", path, typ)
+	}
+
+	return nil
+}
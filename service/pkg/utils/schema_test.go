@@ -0,0 +1,95 @@
+// notlint:revive
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSchema(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema string
+		value  string
+		errMsg string // 为空表示校验通过
+	}{
+		{name: "empty schema", schema: `{}`, value: `{"any": 1}`},
+		{name: "string", schema: `{"type": "string"}`, value: `"a"`},
+		{name: "string mismatch", schema: `{"type": "string"}`, value: `1`, errMsg: "p: type mismatch, expect string"},
+		{name: "number", schema: `{"type": "number"}`, value: `1.5`},
+		{name: "integer", schema: `{"type": "integer"}`, value: `3`},
+		{name: "integer written as float", schema: `{"type": "integer"}`, value: `3.0`},
+		{name: "integer with fraction", schema: `{"type": "integer"}`, value: `3.5`, errMsg: "p: type mismatch, expect integer"},
+		{name: "integer from string", schema: `{"type": "integer"}`, value: `"3"`, errMsg: "p: type mismatch, expect integer"},
+		{name: "boolean", schema: `{"type": "boolean"}`, value: `false`},
+		{name: "null", schema: `{"type": "null"}`, value: `null`},
+		{name: "unknown type passes", schema: `{"type": "custom"}`, value: `1`},
+		{name: "type union first", schema: `{"type": ["string", "null"]}`, value: `"a"`},
+		{name: "type union second", schema: `{"type": ["string", "null"]}`, value: `null`},
+		{name: "type union mismatch", schema: `{"type": ["string", "null"]}`, value: `1`, errMsg: "p: type mismatch, expect [string null]"},
+		{name: "enum string", schema: `{"type": "string", "enum": ["a", "b"]}`, value: `"b"`},
+		{name: "enum string miss", schema: `{"type": "string", "enum": ["a", "b"]}`, value: `"c"`, errMsg: "p: value not in enum"},
+		{name: "enum number", schema: `{"enum": [1, 2]}`, value: `2`},
+		{name: "enum type differs", schema: `{"enum": [1, 2]}`, value: `"1"`, errMsg: "p: value not in enum"},
+		{name: "minimum", schema: `{"type": "number", "minimum": 0, "maximum": 10}`, value: `-1`, errMsg: "p: less than minimum 0"},
+		{name: "maximum", schema: `{"type": "number", "minimum": 0, "maximum": 10}`, value: `11`, errMsg: "p: greater than maximum 10"},
+		{name: "length in runes", schema: `{"type": "string", "maxLength": 2}`, value: `"中文"`},
+		{name: "minLength", schema: `{"type": "string", "minLength": 2}`, value: `"a"`, errMsg: "p: shorter than minLength 2"},
+		{name: "maxLength", schema: `{"type": "string", "maxLength": 2}`, value: `"abc"`, errMsg: "p: longer than maxLength 2"},
+		{
+			name:   "required",
+			schema: `{"type": "object", "required": ["a", "b"]}`,
+			value:  `{"a": 1}`,
+			errMsg: "p.b: required",
+		},
+		{
+			name:   "required with null value",
+			schema: `{"type": "object", "required": ["a"]}`,
+			value:  `{"a": null}`,
+		},
+		{
+			name:   "nested properties",
+			schema: `{"type": "object", "properties": {"sample": {"type": "object", "properties": {"volume": {"type": "number", "minimum": 0}}}}}`,
+			value:  `{"sample": {"volume": -2}}`,
+			errMsg: "p.sample.volume: less than minimum 0",
+		},
+		{
+			name:   "missing optional property",
+			schema: `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			value:  `{"b": 1}`,
+		},
+		{
+			name:   "items",
+			schema: `{"type": "array", "items": {"type": "integer"}}`,
+			value:  `[1, 2, 3]`,
+		},
+		{
+			name:   "items mismatch",
+			schema: `{"type": "array", "items": {"type": "integer"}}`,
+			value:  `[1, 2.5]`,
+			errMsg: "p[1]: type mismatch, expect integer",
+		},
+		{
+			name:   "items of objects",
+			schema: `{"type": "array", "items": {"type": "object", "required": ["id"], "properties": {"id": {"type": "string"}}}}`,
+			value:  `[{"id": "a"}, {"name": "b"}]`,
+			errMsg: "p[1].id: required",
+		},
+	}
+
+	for _, c := range cases {
+		schema := map[string]any{}
+		assert.NoError(t, json.Unmarshal([]byte(c.schema), &schema), c.name)
+		var value any
+		assert.NoError(t, json.Unmarshal([]byte(c.value), &value), c.name)
+
+		err := ValidateSchema(schema, value, "p")
+		if c.errMsg == "" {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.EqualError(t, err, c.errMsg, c.name)
+		}
+	}
+}
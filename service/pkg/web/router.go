@@ -158,6 +158,7 @@ func InstallURL(ctx context.Context, g *gin.Engine) {
 				actionRouter := labRouter.Group("/action")
 				actionRouter.POST("/run", actionHandle.RunAction)               // 手动执行设备动作
 				actionRouter.GET("/result/:uuid", actionHandle.GetActionResult) // 查询动作执行结果
+				actionRouter.GET("/locks/:lab_uuid", actionHandle.DeviceLocks)  // 查询设备锁持有者
 
 				// WebSocket 放在独立的 wsRouter 下
 				wsRouter.GET("/action/:task_uuid", actionHandle.ActionWebSocket) // WebSocket 实时状态更新
@@ -166,8 +167,15 @@ func InstallURL(ctx context.Context, g *gin.Engine) {
 			{
 				workflowHandle := workflow.NewWorkflowHandle(ctx)
 				workflowRouter := labRouter.Group("/workflow")
-				workflowRouter.GET("/task/:uuid", workflowHandle.TaskList)              // 工作流 task 列表 done
-				workflowRouter.GET("/task/download/:uuid", workflowHandle.DownloadTask) // 工作流任务下载 done
+				workflowRouter.GET("/task/:uuid", workflowHandle.TaskList)               // 工作流 task 列表 done
+				workflowRouter.GET("/task/download/:uuid", workflowHandle.DownloadTask)  // 工作流任务下载 done
+				workflowRouter.PUT("/task/pause/:uuid", workflowHandle.PauseTask)        // 暂停工作流任务
+				workflowRouter.PUT("/task/resume/:uuid", workflowHandle.ResumeTask)      // 继续运行工作流任务
+				workflowRouter.GET("/task/status/:uuid", workflowHandle.TaskStatus)      // 工作流任务实时状态
+				workflowRouter.GET("/task/detail/:uuid", workflowHandle.TaskDetail)      // 工作流任务详情和运行快照
+				workflowRouter.POST("/task/manual/:uuid", workflowHandle.ManualDecision) // 确认或拒绝人工节点
+				workflowRouter.GET("/task/manual/list/:uuid", workflowHandle.ManualList) // 任务的人工节点列表
+				workflowRouter.POST("/validate", workflowHandle.ValidateWorkflow)        // 运行前校验工作流
 
 				{
 					// 工作流模板
@@ -197,8 +205,27 @@ func InstallURL(ctx context.Context, g *gin.Engine) {
 					owner.POST("/import", workflowHandle.Import)       // 导入工作流
 					owner.PUT("/duplicate", workflowHandle.Duplicate)  // 复制工作流
 				}
+				{
+					// 定时触发
+					trigger := workflowRouter.Group("/trigger")
+					trigger.POST("", workflowHandle.CreateTrigger)              // 创建定时触发
+					trigger.PATCH("/:uuid", workflowHandle.UpdateTrigger)       // 更新定时触发
+					trigger.DELETE("/:uuid", workflowHandle.DelTrigger)         // 删除定时触发
+					trigger.GET("/list/:uuid", workflowHandle.TriggerList)      // 工作流的定时触发列表
+					trigger.GET("/record/:uuid", workflowHandle.TriggerRecords) // 触发历史
+				}
+				{
+					// webhook 触发 token，仅实验室管理员可管理
+					token := workflowRouter.Group("/token")
+					token.POST("", workflowHandle.CreateTriggerToken)         // 创建触发 token
+					token.DELETE("/:uuid", workflowHandle.RevokeTriggerToken) // 吊销触发 token
+					token.GET("/list/:uuid", workflowHandle.TriggerTokenList) // 工作流的触发 token 列表
+				}
 
+				// 使用工作流触发 token 鉴权
 				v1.PUT("/lab/run/workflow", workflowHandle.RunWorkflow)
+				v1.POST("/lab/workflow/webhook", workflowHandle.WebhookTrigger)
+				v1.GET("/lab/workflow/webhook/task/:uuid", workflowHandle.WebhookTask)
 
 				workflowRouter.GET("/ws/workflow/:uuid", workflowHandle.LabWorkflow) // TODO: websocket 放在统一的路由下
 			}
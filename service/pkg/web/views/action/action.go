@@ -20,9 +20,14 @@ import (
 	"github.com/scienceol/studio/service/pkg/core/schedule/edge"
 	"github.com/scienceol/studio/service/pkg/core/schedule/engine"
 	actionEngine "github.com/scienceol/studio/service/pkg/core/schedule/engine/action"
+	"github.com/scienceol/studio/service/pkg/core/schedule/lock"
+	"github.com/scienceol/studio/service/pkg/core/schedule/lock/device"
 	"github.com/scienceol/studio/service/pkg/middleware/auth"
 	"github.com/scienceol/studio/service/pkg/middleware/logger"
 	"github.com/scienceol/studio/service/pkg/middleware/redis"
+	"github.com/scienceol/studio/service/pkg/model"
+	"github.com/scienceol/studio/service/pkg/repo"
+	eStore "github.com/scienceol/studio/service/pkg/repo/environment"
 	"github.com/scienceol/studio/service/pkg/utils"
 )
 
@@ -34,6 +39,8 @@ type Handle struct {
 	rClient    *r.Client
 	wsClient   *melody.Melody
 	boardEvent notify.MsgCenter
+	deviceLock lock.DeviceLock
+	labStore   repo.LaboratoryRepo
 }
 
 func NewActionHandle(ctx context.Context) *Handle {
@@ -44,6 +51,8 @@ func NewActionHandle(ctx context.Context) *Handle {
 		rClient:    redis.GetClient(),
 		wsClient:   wsClient,
 		boardEvent: events.NewEvents(),
+		deviceLock: device.New(),
+		labStore:   eStore.New(),
 	}
 
 	// 注册通知处理
@@ -200,6 +209,60 @@ func (h *Handle) GetActionResult(ctx *gin.Context) {
 	common.ReplyOk(ctx, resp)
 }
 
+// @Summary 		查询设备锁
+// @Description 	查询实验室当前被占用的设备及持有的任务
+// @Tags 			Action
+// @Accept 			json
+// @Produce 		json
+// @Security 		BearerAuth
+// @Param 			lab_uuid path string true "实验室UUID"
+// @Success 		200 {object} common.Resp{data=[]lock.Holder} "查询成功"
+// @Failure 		200 {object} common.Resp{code=code.ErrCode} "查询失败"
+// @Router 			/v1/lab/action/locks/{lab_uuid} [get]
+func (h *Handle) DeviceLocks(ctx *gin.Context) {
+	labUUID, err := uuid.FromString(ctx.Param("lab_uuid"))
+	if err != nil {
+		logger.Errorf(ctx, "parse lab_uuid err: %+v", err)
+		common.ReplyErr(ctx, code.ParamErr.WithMsg("invalid lab_uuid"))
+		return
+	}
+
+	userInfo := auth.GetCurrentUser(ctx)
+	if userInfo == nil {
+		common.ReplyErr(ctx, code.UnLogin)
+		return
+	}
+
+	// 只有实验室成员可以查看设备占用
+	lab, err := h.labStore.GetLabByUUID(ctx, labUUID, "id")
+	if err != nil {
+		common.ReplyErr(ctx, err)
+		return
+	}
+
+	count, err := h.labStore.Count(ctx, &model.LaboratoryMember{}, map[string]any{
+		"lab_id":  lab.ID,
+		"user_id": userInfo.ID,
+	})
+	if err != nil {
+		common.ReplyErr(ctx, err)
+		return
+	}
+	if count == 0 {
+		common.ReplyErr(ctx, code.NoPermission)
+		return
+	}
+
+	holders, err := h.deviceLock.Holders(ctx, labUUID)
+	if err != nil {
+		logger.Errorf(ctx, "get device locks lab uuid: %s, err: %+v", labUUID, err)
+		common.ReplyErr(ctx, err)
+		return
+	}
+
+	common.ReplyOk(ctx, holders)
+}
+
 // HandleNotify 处理通知消息并通过 WebSocket 广播给前端
 func (h *Handle) HandleNotify(ctx context.Context, msg string) error {
 	notifyData := &notify.SendMsg{}
@@ -30,3 +30,11 @@ type TaskPageMore struct {
 	PageSize int            `json:"page_size"`
 	Data     []cwf.TaskResp `json:"data"`
 }
+
+// TriggerRecordPageMore 用于 Swagger 展示定时触发历史滚动分页结果
+type TriggerRecordPageMore struct {
+	HasMore  bool                    `json:"has_more"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"page_size"`
+	Data     []cwf.TriggerRecordResp `json:"data"`
+}
@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -570,22 +571,421 @@ func (w *Handle) Duplicate(ctx *gin.Context) {
 	common.Reply(ctx, err, res)
 }
 
-// @Summary 启动工作流（无鉴权）
-// @Description 通过 HTTP 启动工作流任务，返回任务 UUID
+// @Summary 启动工作流
+// @Description 通过 HTTP 启动工作流任务，需携带工作流触发 token，返回任务 UUID
 // @Tags Workflow
 // @Accept json
 // @Produce json
+// @Param X-Trigger-Token header string true "工作流触发 token"
+// @Param X-Trigger-Signature header string false "请求体 HMAC-SHA256 签名，格式 sha256=<hex>"
 // @Param workflow body workflow.RunReq true "启动请求"
 // @Success 200 {object} common.Resp{data=uuid.UUID} "启动成功"
 // @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
 // @Router /v1/lab/run/workflow [put]
 func (w *Handle) RunWorkflow(ctx *gin.Context) {
+	body, err := ctx.GetRawData()
+	if err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
 
 	req := &workflow.RunReq{}
+	if err := json.Unmarshal(body, req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+	taskUUID, err := w.wService.HttpRunWorkflow(ctx, webhookAuth(ctx, body), req)
+	common.Reply(ctx, err, taskUUID)
+}
+
+// @Summary 暂停工作流任务
+// @Description 暂停下发新节点，已下发的设备动作继续运行
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "任务UUID"
+// @Success 200 {object} common.Resp{} "暂停成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/task/pause/{uuid} [put]
+func (w *Handle) PauseTask(ctx *gin.Context) {
+	req := &workflow.TaskControlReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	err := w.wService.PauseWorkflowTask(ctx, req)
+	common.Reply(ctx, err)
+}
+
+// @Summary 继续运行工作流任务
+// @Description 继续运行已暂停的工作流任务
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "任务UUID"
+// @Success 200 {object} common.Resp{} "继续运行成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/task/resume/{uuid} [put]
+func (w *Handle) ResumeTask(ctx *gin.Context) {
+	req := &workflow.TaskControlReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	err := w.wService.ResumeWorkflowTask(ctx, req)
+	common.Reply(ctx, err)
+}
+
+// @Summary 查询工作流任务实时状态
+// @Description 运行中的任务返回调度引擎中的就绪、运行、等待节点和等待回复的动作
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "任务UUID"
+// @Success 200 {object} common.Resp{data=workflow.TaskStatusResp} "查询成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/task/status/{uuid} [get]
+func (w *Handle) TaskStatus(ctx *gin.Context) {
+	req := &workflow.TaskControlReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	if res, err := w.wService.TaskStatus(ctx, req); err != nil {
+		common.ReplyErr(ctx, err)
+	} else {
+		common.ReplyOk(ctx, res)
+	}
+}
+
+// @Summary 查询工作流任务详情
+// @Description 返回任务运行时的工作流快照，以及每个 job 实际下发的输入和排队、下发、开始、结束时间
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "任务UUID"
+// @Success 200 {object} common.Resp{data=workflow.TaskDetailResp} "查询成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/task/detail/{uuid} [get]
+func (w *Handle) TaskDetail(ctx *gin.Context) {
+	req := &workflow.TaskControlReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	if res, err := w.wService.TaskDetail(ctx, req); err != nil {
+		common.ReplyErr(ctx, err)
+	} else {
+		common.ReplyOk(ctx, res)
+	}
+}
+
+// @Summary 处理人工节点
+// @Description 实验室成员确认或拒绝等待中的人工节点，确认时提交的表单作为节点输出
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "人工处理记录UUID"
+// @Param req body workflow.ManualDecisionReq true "处理结果"
+// @Success 200 {object} common.Resp{} "处理成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/task/manual/{uuid} [post]
+func (w *Handle) ManualDecision(ctx *gin.Context) {
+	req := &workflow.ManualDecisionReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	err := w.wService.ManualDecision(ctx, req)
+	common.Reply(ctx, err)
+}
+
+// @Summary 人工节点列表
+// @Description 获取任务的人工处理记录，包含子工作流中的人工节点
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "任务UUID"
+// @Success 200 {object} common.Resp{data=[]workflow.ManualResp} "查询成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/task/manual/list/{uuid} [get]
+func (w *Handle) ManualList(ctx *gin.Context) {
+	req := &workflow.TaskControlReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	if res, err := w.wService.ManualList(ctx, req); err != nil {
+		common.ReplyErr(ctx, err)
+	} else {
+		common.ReplyOk(ctx, res)
+	}
+}
+
+// @Summary 运行前校验工作流
+// @Description 执行与运行时相同的加载和构建检查，返回每个节点的问题，不创建任务
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param req body workflow.ValidateReq true "校验请求"
+// @Success 200 {object} common.Resp{data=workflow.ValidateResp} "校验完成"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/validate [post]
+func (w *Handle) ValidateWorkflow(ctx *gin.Context) {
+	req := &workflow.ValidateReq{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	if res, err := w.wService.ValidateWorkflow(ctx, req); err != nil {
+		common.ReplyErr(ctx, err)
+	} else {
+		common.ReplyOk(ctx, res)
+	}
+}
+
+// @Summary 创建定时触发
+// @Description 为工作流创建 cron 或固定间隔的定时触发
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param req body workflow.CreateTriggerReq true "定时触发配置"
+// @Success 200 {object} common.Resp{data=workflow.TriggerResp} "创建成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/trigger [post]
+func (w *Handle) CreateTrigger(ctx *gin.Context) {
+	req := &workflow.CreateTriggerReq{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	if res, err := w.wService.CreateTrigger(ctx, req); err != nil {
+		common.ReplyErr(ctx, err)
+	} else {
+		common.ReplyOk(ctx, res)
+	}
+}
+
+// @Summary 更新定时触发
+// @Description 更新定时触发配置，启用、停用
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "触发UUID"
+// @Param req body workflow.UpdateTriggerReq true "定时触发配置"
+// @Success 200 {object} common.Resp{} "更新成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/trigger/{uuid} [patch]
+func (w *Handle) UpdateTrigger(ctx *gin.Context) {
+	req := &workflow.UpdateTriggerReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
 	if err := ctx.ShouldBindJSON(req); err != nil {
 		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
 		return
 	}
-	taskUUID, err := w.wService.HttpRunWorkflow(ctx, req)
+
+	err := w.wService.UpdateTrigger(ctx, req)
+	common.Reply(ctx, err)
+}
+
+// @Summary 删除定时触发
+// @Description 删除定时触发及其触发历史
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "触发UUID"
+// @Success 200 {object} common.Resp{} "删除成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/trigger/{uuid} [delete]
+func (w *Handle) DelTrigger(ctx *gin.Context) {
+	req := &workflow.TriggerReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	err := w.wService.DelTrigger(ctx, req)
+	common.Reply(ctx, err)
+}
+
+// @Summary 定时触发列表
+// @Description 获取工作流的定时触发列表
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "工作流UUID"
+// @Success 200 {object} common.Resp{data=[]workflow.TriggerResp} "获取成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/trigger/list/{uuid} [get]
+func (w *Handle) TriggerList(ctx *gin.Context) {
+	req := &workflow.TriggerListReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	if res, err := w.wService.TriggerList(ctx, req); err != nil {
+		common.ReplyErr(ctx, err)
+	} else {
+		common.ReplyOk(ctx, res)
+	}
+}
+
+// @Summary 定时触发历史
+// @Description 获取定时触发的触发历史
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "触发UUID"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} common.Resp{data=TriggerRecordPageMore} "获取成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/trigger/record/{uuid} [get]
+func (w *Handle) TriggerRecords(ctx *gin.Context) {
+	req := &workflow.TriggerRecordReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	if err := ctx.ShouldBindQuery(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	if res, err := w.wService.TriggerRecords(ctx, req); err != nil {
+		common.ReplyErr(ctx, err)
+	} else {
+		common.ReplyOk(ctx, res)
+	}
+}
+
+// @Summary 创建工作流触发 token
+// @Description 实验室管理员为工作流创建 webhook 触发 token，token 和签名密钥只在创建时返回
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param req body workflow.CreateTokenReq true "触发 token 配置"
+// @Success 200 {object} common.Resp{data=workflow.CreateTokenResp} "创建成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/token [post]
+func (w *Handle) CreateTriggerToken(ctx *gin.Context) {
+	req := &workflow.CreateTokenReq{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	res, err := w.wService.CreateTriggerToken(ctx, req)
+	common.Reply(ctx, err, res)
+}
+
+// @Summary 吊销工作流触发 token
+// @Description 实验室管理员吊销 webhook 触发 token
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "token UUID"
+// @Success 200 {object} common.Resp{} "吊销成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/token/{uuid} [delete]
+func (w *Handle) RevokeTriggerToken(ctx *gin.Context) {
+	req := &workflow.TokenReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	err := w.wService.RevokeTriggerToken(ctx, req)
+	common.Reply(ctx, err)
+}
+
+// @Summary 工作流触发 token 列表
+// @Description 实验室管理员获取工作流的 webhook 触发 token 列表
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param uuid path string true "工作流UUID"
+// @Success 200 {object} common.Resp{data=[]workflow.TokenResp} "获取成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/token/list/{uuid} [get]
+func (w *Handle) TriggerTokenList(ctx *gin.Context) {
+	req := &workflow.TokenListReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	res, err := w.wService.TriggerTokenList(ctx, req)
+	common.Reply(ctx, err, res)
+}
+
+// @Summary webhook 触发工作流
+// @Description 携带触发 token 启动工作流，请求体按 token 的参数映射转换为运行参数，返回任务 UUID
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param X-Trigger-Token header string true "工作流触发 token"
+// @Param X-Trigger-Signature header string false "请求体 HMAC-SHA256 签名，格式 sha256=<hex>"
+// @Param payload body object false "触发请求体"
+// @Success 200 {object} common.Resp{data=uuid.UUID} "触发成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/webhook [post]
+func (w *Handle) WebhookTrigger(ctx *gin.Context) {
+	body, err := ctx.GetRawData()
+	if err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	taskUUID, err := w.wService.WebhookTrigger(ctx, webhookAuth(ctx, body))
 	common.Reply(ctx, err, taskUUID)
 }
+
+// @Summary 查询 webhook 触发的任务
+// @Description 携带触发 token 查询该工作流任务的运行状态
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param X-Trigger-Token header string true "工作流触发 token"
+// @Param uuid path string true "任务UUID"
+// @Success 200 {object} common.Resp{data=workflow.TaskResp} "获取成功"
+// @Failure 200 {object} common.Resp{code=code.ErrCode} "请求参数错误"
+// @Router /v1/lab/workflow/webhook/task/{uuid} [get]
+func (w *Handle) WebhookTask(ctx *gin.Context) {
+	req := &workflow.TaskControlReq{}
+	if err := ctx.ShouldBindUri(req); err != nil {
+		common.ReplyErr(ctx, code.ParamErr.WithMsg(err.Error()))
+		return
+	}
+
+	res, err := w.wService.WebhookTask(ctx, webhookAuth(ctx, nil), req)
+	common.Reply(ctx, err, res)
+}
+
+func webhookAuth(ctx *gin.Context, body []byte) *workflow.WebhookAuth {
+	return &workflow.WebhookAuth{
+		Token:      ctx.GetHeader(constant.TriggerTokenHeader),
+		Signature:  ctx.GetHeader(constant.TriggerSignatureHeader),
+		Body:       body,
+		RemoteAddr: ctx.ClientIP(),
+	}
+}